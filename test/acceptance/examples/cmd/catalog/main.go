@@ -0,0 +1,137 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Command catalog prints every registered scenario along with its display name, folder,
+// prerequisites, tags and phases. The JSON output is meant to be used to generate
+// the scenario matrix in CI.
+//
+// It exits with a non zero status if the registered scenarios are out of
+// sync with the folders present under the `examples/` directory.
+//
+// Usage, from the test/acceptance/examples directory:
+//
+//	go run ./cmd/catalog [-format json|table] [-tag <tag>] [-examples-dir <dir>] [-region-pool <regions>]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/common"
+)
+
+const (
+	formatJSON  = "json"
+	formatTable = "table"
+)
+
+type catalogEntry struct {
+	Name          string                  `json:"name"`
+	DisplayName   string                  `json:"displayName"`
+	Folder        string                  `json:"folder"`
+	Prerequisites scenarios.Prerequisites `json:"prerequisites"`
+	Tags          []string                `json:"tags"`
+	Phases        []string                `json:"phases"`
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	format := fs.String("format", formatJSON, "Output format: 'json' or 'table'.")
+	tag := fs.String("tag", "", "Only print scenarios labelled with this tag.")
+	examplesDir := fs.String("examples-dir", "../../../examples", "Path to the repository's examples directory.")
+	regionPool := fs.String("region-pool", "", "Comma separated list of AWS regions that the scenarios are spread across. Defaults to "+common.DefaultRegionPool+".")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	common.SetRegionPool(common.NewRegionPool(*regionPool))
+	registry := examples.SetupScenarios()
+
+	exampleFolders, err := listExampleFolders(*examplesDir)
+	if err != nil {
+		return err
+	}
+	if err := scenarios.CheckCatalog(registry, exampleFolders); err != nil {
+		return err
+	}
+
+	entries := make([]catalogEntry, 0)
+	for _, scenario := range registry.List() {
+		if *tag != "" && !scenario.HasTag(*tag) {
+			continue
+		}
+
+		tags := scenario.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		var phases []string
+		for _, phase := range scenario.GetPhases() {
+			phases = append(phases, phase.Name)
+		}
+		displayName := scenario.DisplayName
+		if displayName == "" {
+			displayName = scenario.Name
+		}
+		entries = append(entries, catalogEntry{
+			Name:          scenario.Name,
+			DisplayName:   displayName,
+			Folder:        scenario.FolderName,
+			Prerequisites: scenario.Prerequisites,
+			Tags:          tags,
+			Phases:        phases,
+		})
+	}
+
+	switch *format {
+	case formatJSON:
+		// The JSON is written on a single line so that it
+		// can be passed around as a GitHub Actions output.
+		return json.NewEncoder(out).Encode(entries)
+	case formatTable:
+		return writeTable(out, entries)
+	default:
+		return fmt.Errorf("unsupported format %q, must be one of %s or %s", *format, formatJSON, formatTable)
+	}
+}
+
+func listExampleFolders(dir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading examples directory: %w", err)
+	}
+
+	var folders []string
+	for _, e := range dirEntries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			folders = append(folders, e.Name())
+		}
+	}
+	return folders, nil
+}
+
+func writeTable(out io.Writer, entries []catalogEntry) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFOLDER\tLAUNCH TYPE\tREGION\tENTERPRISE\tEGRESS\tENV VARS\tTAGS\tPHASES")
+	for _, e := range entries {
+		p := e.Prerequisites
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%t\t%s\t%s\t%s\n",
+			e.Name, e.Folder, p.LaunchType, p.Region, p.Enterprise, p.NetworkEgress,
+			strings.Join(p.EnvVars, ","), strings.Join(e.Tags, ","), strings.Join(e.Phases, ","))
+	}
+	return w.Flush()
+}
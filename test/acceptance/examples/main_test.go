@@ -5,101 +5,432 @@ package examples
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"text/tabwriter"
 	"time"
 
+	"github.com/gruntwork-io/terratest/modules/files"
 	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
-	apigateway "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/api-gateway"
-	clusterpeering "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/cluster-peering"
-	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/ec2"
-	ec2tproxy "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/ec2-tproxy"
-	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/fargate"
-	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/hcp"
-	localityawarerouting "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/locality-aware-routing"
-	sameness "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/service-sameness"
-	terminatinggateway "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway"
-	terminatinggatewaytls "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway-tls"
-	terminatinggatewaytproxy "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway-tproxy"
-	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/wan-federation"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/common"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/helpers"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/report"
 	"github.com/stretchr/testify/require"
 )
 
-// TestRunScenario accepts a single scenario name as an environment
-// variable and executes tests for the same. We want to run each
-// scenario as a separate GitHub Action job.
+const (
+	// repoRoot is the path to the root of this repository relative
+	// to the directory containing this test.
+	repoRoot = "../../.."
+
+	// upgradePhaseName is the name of the phase that upgrades the
+	// modules of the example from the released version.
+	upgradePhaseName = "upgrade"
+)
+
+var (
+	flagReuseState = flag.String("reuse-state", "",
+		"Path to the terraform working directory of an existing deployment of the scenario, e.g. the one logged by a run with NO_CLEANUP_ON_FAILURE set. If set, skip terraform apply and destroy and only validate the deployment recorded in its state.")
+	flagReportDir = flag.String("report-dir", "",
+		"Directory to write the JUnit XML and JSON reports of the scenarios to, along with the artifacts collected for them.")
+	flagRegionPool = flag.String("region-pool", "",
+		"Comma separated list of AWS regions that the scenarios are spread across. Defaults to "+common.DefaultRegionPool+".")
+)
+
+// TestRunScenario accepts the scenarios to run as part of the TEST_SCENARIO
+// environment variable. The variable holds a comma separated list of scenario
+// names or regular expressions matching scenario names. Every matching scenario
+// runs as a parallel subtest against its own copy of the Terraform configuration
+// so that the working directories and state files of the scenarios never overlap.
 func TestRunScenario(t *testing.T) {
+	// The scenarios get their region from the pool when they are registered.
+	common.SetRegionPool(common.NewRegionPool(*flagRegionPool))
+
 	// Setup scenario registry
-	scenarioRegistry := setupScenarios()
+	scenarioRegistry := SetupScenarios()
 
-	scenarioName := os.Getenv("TEST_SCENARIO")
-	require.NotEmpty(t, scenarioName)
+	scenarioExpr := os.Getenv("TEST_SCENARIO")
+	require.NotEmpty(t, scenarioExpr)
 
-	scenario, err := scenarioRegistry.Retrieve(scenarioName)
+	scenariosToRun, err := scenarioRegistry.Filter(scenarioExpr)
 	require.NoError(t, err)
+	if *flagReuseState != "" {
+		require.Len(t, scenariosToRun, 1, "-reuse-state holds the deployment of a single scenario, but TEST_SCENARIO selects %d", len(scenariosToRun))
+	}
 
-	initOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: fmt.Sprintf("../../../examples/%s", scenario.FolderName),
-		NoColor:      true,
+	summary := &scenarioSummary{}
+	scenarioReport := report.New("scenarios", *flagReportDir)
+	t.Cleanup(func() {
+		// Cleanup functions of the parent test run after all of
+		// its parallel subtests have completed.
+		logger.Log(t, "scenario summary\n"+summary.String())
+		if err := scenarioReport.Write(); err != nil {
+			t.Errorf("failed to write the scenario report: %s", err)
+		}
 	})
-	terraform.Init(t, initOptions)
 
-	var tfVars map[string]interface{}
-	retry.RunWith(&retry.Timer{Timeout: 2 * time.Minute, Wait: 10 * time.Second}, t, func(r *retry.R) {
+	for _, scenario := range scenariosToRun {
+		name := scenario.Name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reportCase := scenarioReport.Track(t)
+
+			start := time.Now()
+			result := &scenarioResult{name: name}
+			t.Cleanup(func() {
+				summary.record(t, result, time.Since(start))
+			})
+
+			// Retrieving the scenario verifies its prerequisites
+			// before anything gets deployed.
+			scenario, err := scenarioRegistry.Retrieve(name)
+			var prerequisiteErr *scenarios.PrerequisiteError
+			if errors.As(err, &prerequisiteErr) && os.Getenv("SKIP_MISSING_PREREQUISITES") == "true" {
+				t.Skip(prerequisiteErr.Error())
+			}
+			require.NoError(t, err)
+
+			runScenario(t, scenario, result, reportCase)
+		})
+	}
+}
+
+// runScenario deploys the scenario's example using a dedicated copy of the
+// Terraform configuration and runs the scenario's validations against it.
+//
+// The scenario's hooks are called in the following order:
+// PreApply, TerraformInputVars, PostApply, Validate and finally
+// OnFailure (only if the scenario failed) and PreDestroy during cleanup.
+//
+// Scenarios registered with multiple phases run TerraformInputVars, terraform
+// apply, PostApply and Validate once per phase against the same state. Every
+// phase runs as a subtest and the scenario stops at the first failing phase,
+// which is recorded in the result.
+//
+// When TEST_UPGRADE_FROM is set, the example is first deployed with its modules
+// pinned to the released version and validated using the scenario's first phase.
+// The modules are then pointed back to the working tree and the first phase is
+// applied again while the scenario's TrafficProbe app is continuously probed.
+//
+// Every step of the scenario, e.g. terraform init, apply and destroy and the
+// scenario's hooks, is timed and recorded in the scenario's report case along
+// with the output of terraform.
+func runScenario(t *testing.T, scenario scenarios.ScenarioRegistration, result *scenarioResult, reportCase *report.Case) {
+	if *flagReuseState != "" {
+		validateExistingDeployment(t, scenario, *flagReuseState, reportCase)
+		return
+	}
+
+	terraformDir := copyExampleToTemp(t, scenario.FolderName)
+
+	var pinnedSources *common.PinnedModuleSources
+	upgradeFrom := getUpgradeFromVersion(t)
+	if upgradeFrom != "" {
 		var err error
-		tfVars, err = scenario.TerraformInputVars()
-		require.NoError(r, err)
+		pinnedSources, err = common.PinModuleSources(terraformDir, upgradeFrom)
+		require.NoError(t, err)
+		logger.Log(t, fmt.Sprintf("pinned the example's modules to version %s", upgradeFrom))
+	}
+
+	initOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: terraformDir,
+		NoColor:      true,
 	})
+	terraformInit := func(t *testing.T, stepName string) {
+		reportCase.Step(t, stepName, func() error {
+			out, err := terraform.InitE(t, initOptions)
+			writeArtifact(t, reportCase, stepName+".log", out)
+			return err
+		})
+	}
+	terraformInit(t, "init")
 
+	// The input variables of applyOptions are replaced by every
+	// phase so that destroy uses the ones that were applied last.
 	applyOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: initOptions.TerraformDir,
-		Vars:         tfVars,
 		NoColor:      true,
 	})
 
+	// outputJSON holds the terraform outputs once apply succeeds.
+	// It is shared with the cleanup hooks below.
+	var outputJSON []byte
+
+	// Cleanup functions run in the reverse order of their registration. Each
+	// step is registered separately so that a hook failing the test does not
+	// prevent the resources from being destroyed.
 	t.Cleanup(func() {
 		if os.Getenv("NO_CLEANUP_ON_FAILURE") != "true" {
-			terraform.Destroy(t, applyOptions)
+			reportCase.Step(t, "destroy", func() error {
+				out, err := terraform.DestroyE(t, applyOptions)
+				writeArtifact(t, reportCase, "destroy.log", out)
+				return err
+			})
+		} else {
+			logger.Log(t, fmt.Sprintf("skipping resource cleanup, terraform state is present in %s, pass -reuse-state %s to validate it again", terraformDir, terraformDir))
 		}
 	})
-	terraform.Apply(t, applyOptions)
 
-	outputs := terraform.OutputAll(t, &terraform.Options{
-		TerraformDir: initOptions.TerraformDir,
-		NoColor:      true,
-		Logger:       terratestLogger.Default,
+	t.Cleanup(func() {
+		if outputJSON != nil && scenario.PreDestroy != nil && os.Getenv("NO_CLEANUP_ON_FAILURE") != "true" {
+			logger.Log(t, "running pre destroy hook for scenario")
+			reportCase.Step(t, "pre-destroy", func() error {
+				scenario.PreDestroy(t, outputJSON)
+				return nil
+			})
+		}
 	})
 
-	// Marshal the output into a json so that individual
-	// scenarios can unmarshal it into their required format.
+	t.Cleanup(func() {
+		if t.Failed() && scenario.OnFailure != nil {
+			logger.Log(t, "running failure hook for scenario")
+			reportCase.Step(t, "on-failure", func() error {
+				scenario.OnFailure(t, outputJSON)
+				return nil
+			})
+		}
+	})
+
+	if scenario.PreApply != nil {
+		logger.Log(t, "running pre apply hook for scenario")
+		reportCase.Step(t, "pre-apply", func() error {
+			scenario.PreApply(t)
+			return nil
+		})
+	}
+
+	// The steps of every phase are recorded in the scenario's report case.
+	steps := scenarios.PhaseSteps{
+		InputVars: func(t *testing.T, phase scenarios.Phase) map[string]interface{} {
+			var tfVars map[string]interface{}
+			retry.RunWith(&retry.Timer{Timeout: 2 * time.Minute, Wait: 10 * time.Second}, t, func(r *retry.R) {
+				var err error
+				tfVars, err = phase.TerraformInputVars()
+				require.NoError(r, err)
+			})
+			return tfVars
+		},
+		Apply: func(t *testing.T, stepPrefix string, tfVars map[string]interface{}) []byte {
+			applyOptions.Vars = tfVars
+
+			reportCase.Step(t, stepPrefix+"apply", func() error {
+				out, err := terraform.ApplyE(t, applyOptions)
+				writeArtifact(t, reportCase, stepPrefix+"apply.log", out)
+				return err
+			})
+
+			outputs := terraform.OutputAll(t, &terraform.Options{
+				TerraformDir: initOptions.TerraformDir,
+				NoColor:      true,
+				Logger:       terratestLogger.Default,
+			})
+
+			// Marshal the output into a json so that individual
+			// scenarios can unmarshal it into their required format.
+			var err error
+			outputJSON, err = json.Marshal(outputs)
+			require.NoError(t, err)
+			return outputJSON
+		},
+		Hook: func(t *testing.T, stepName string, hook func()) {
+			reportCase.Step(t, stepName, func() error {
+				hook()
+				return nil
+			})
+		},
+	}
+
+	phases := scenario.GetPhases()
+	if pinnedSources != nil {
+		released, upgrade := phases[0], phases[0]
+		released.Name = fmt.Sprintf("release-%s", upgradeFrom)
+		upgrade.Name = upgradePhaseName
+		steps.Setup = func(t *testing.T, phase scenarios.Phase, stepPrefix string) {
+			if phase.Name != upgradePhaseName {
+				return
+			}
+			startTrafficProbe(t, scenario, outputJSON)
+
+			logger.Log(t, "pointing the example's modules to the working tree")
+			require.NoError(t, pinnedSources.Restore())
+			terraformInit(t, stepPrefix+"init")
+		}
+		phases = append([]scenarios.Phase{released, upgrade}, phases[1:]...)
+	}
+
+	if failedPhase := scenario.RunPhases(t, phases, steps); failedPhase != "" {
+		result.failedPhase = failedPhase
+		t.FailNow()
+	}
+}
+
+// validateExistingDeployment runs the Validate hook of the scenario's last phase
+// against the deployment recorded in the terraform state of terraformDir,
+// without applying or destroying anything. It refuses to run if the state is
+// missing or if its outputs do not match the scenario's TFOutputs.
+func validateExistingDeployment(t *testing.T, scenario scenarios.ScenarioRegistration, terraformDir string, reportCase *report.Case) {
+	require.NotNil(t, scenario.TFOutputs, "scenario %s does not declare its terraform outputs and cannot reuse an existing deployment", scenario.Name)
+
+	outputs, err := helpers.ExistingStateOutputs(t, terraformDir, "")
+	require.NoError(t, err)
+
 	outputJSON, err := json.Marshal(outputs)
 	require.NoError(t, err)
+	require.NoError(t, scenarios.CheckOutputs(scenario.TFOutputs, outputJSON),
+		"the terraform state in %s does not belong to scenario %s", terraformDir, scenario.Name)
+
+	phases := scenario.GetPhases()
+	phase := phases[len(phases)-1]
 
-	logger.Log(t, "running validation for scenario")
-	scenario.Validate(t, outputJSON)
+	logger.Log(t, fmt.Sprintf("reusing the deployment in %s, running validation for phase %s", terraformDir, phase.Name))
+	reportCase.Step(t, "validate", func() error {
+		phase.Validate(t, outputJSON)
+		return nil
+	})
 	logger.Log(t, "validation successful!!")
 }
 
-func setupScenarios() scenarios.ScenarioRegistry {
-	reg := scenarios.NewScenarioRegistry()
-
-	fargate.RegisterScenario(reg)
-	ec2.RegisterScenario(reg)
-	clusterpeering.RegisterScenario(reg)
-	hcp.RegisterScenario(reg)
-	sameness.RegisterScenario(reg)
-	wan.RegisterScenario(reg)
-	localityawarerouting.RegisterScenario(reg)
-	apigateway.RegisterScenario(reg)
-	terminatinggateway.RegisterScenario(reg)
-	terminatinggatewaytls.RegisterScenario(reg)
-	terminatinggatewaytproxy.RegisterScenario(reg)
-	ec2tproxy.RegisterScenario(reg)
-
-	return reg
+// writeArtifact stores the output of a step in the scenario's report.
+func writeArtifact(t *testing.T, reportCase *report.Case, name, contents string) {
+	if err := reportCase.WriteArtifact(name, []byte(contents)); err != nil {
+		logger.Log(t, fmt.Sprintf("failed to write artifact %s: %s", name, err))
+	}
+}
+
+// getUpgradeFromVersion returns the released version of the modules that
+// upgrades are tested from. It is read from TEST_UPGRADE_FROM, where `latest`
+// refers to the most recent version in the changelog. An empty string is
+// returned if upgrades are not being tested.
+func getUpgradeFromVersion(t *testing.T) string {
+	version := os.Getenv("TEST_UPGRADE_FROM")
+	if version != "latest" {
+		return strings.TrimPrefix(version, "v")
+	}
+
+	version, err := common.LatestReleasedVersion(filepath.Join(repoRoot, "CHANGELOG.md"))
+	require.NoError(t, err)
+	return version
+}
+
+// startTrafficProbe probes the scenario's TrafficProbe app until the end of the
+// current test and fails the test if any of the requests through it fail.
+func startTrafficProbe(t *testing.T, scenario scenarios.ScenarioRegistration, outputJSON []byte) {
+	if scenario.TrafficProbe == nil {
+		logger.Log(t, "scenario does not provide an app to probe, traffic will not be verified during the upgrade")
+		return
+	}
+
+	target := scenario.TrafficProbe(t, outputJSON)
+	logger.Log(t, fmt.Sprintf("probing %s for the duration of the upgrade", target.URL))
+	prober := common.StartProber(target.URL, target.ExpectedUpstream)
+
+	t.Cleanup(func() {
+		report := prober.Stop()
+		logger.Log(t, "traffic probe report: "+report.String())
+		if len(report.Failures) > 0 {
+			t.Errorf("traffic through %s dropped during the upgrade: %s", target.URL, report)
+		}
+	})
+}
+
+// copyExampleToTemp copies the example's folder along with the modules it
+// references into a temporary directory and returns the path to the example's
+// Terraform configuration within it. The directory layout of the repository is
+// preserved so that the relative module sources used by the examples resolve.
+//
+// The temporary directory is removed once the scenario's resources have been
+// destroyed. It is retained when NO_CLEANUP_ON_FAILURE is set so that the
+// state can be used to clean up the resources manually.
+func copyExampleToTemp(t *testing.T, folderName string) string {
+	tmpRoot, err := os.MkdirTemp("", "consul-ecs-example-")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if os.Getenv("NO_CLEANUP_ON_FAILURE") != "true" {
+			_ = os.RemoveAll(tmpRoot)
+		}
+	})
+
+	filter := func(path string) bool {
+		return !files.PathContainsHiddenFileOrFolder(path) && !files.PathContainsTerraformStateOrVars(path)
+	}
+
+	// The first component of the folder name is the example's top level
+	// directory. Copy it entirely since some examples keep their Terraform
+	// configuration in a nested folder.
+	exampleDir := filepath.Join("examples", strings.Split(filepath.ToSlash(folderName), "/")[0])
+	for _, dir := range []string{"modules", exampleDir} {
+		dest := filepath.Join(tmpRoot, dir)
+		require.NoError(t, os.MkdirAll(dest, 0755))
+		require.NoError(t, files.CopyFolderContentsWithFilter(filepath.Join(repoRoot, dir), dest, filter))
+	}
+
+	terraformDir := filepath.Join(tmpRoot, "examples", folderName)
+	logger.Log(t, fmt.Sprintf("copied example %s to %s", folderName, terraformDir))
+
+	return terraformDir
+}
+
+type scenarioResult struct {
+	name        string
+	status      string
+	failedPhase string
+	duration    time.Duration
+}
+
+// scenarioSummary records the outcome of every scenario
+// that ran as part of a single TestRunScenario invocation.
+type scenarioSummary struct {
+	mu      sync.Mutex
+	results []scenarioResult
+}
+
+func (s *scenarioSummary) record(t *testing.T, result *scenarioResult, duration time.Duration) {
+	result.status = "PASS"
+	if t.Failed() {
+		result.status = "FAIL"
+	} else if t.Skipped() {
+		result.status = "SKIP"
+	}
+	result.duration = duration
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, *result)
+}
+
+// String renders the recorded results as a table sorted by scenario name.
+func (s *scenarioSummary) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.Slice(s.results, func(i, j int) bool {
+		return s.results[i].name < s.results[j].name
+	})
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCENARIO\tSTATUS\tFAILED PHASE\tDURATION")
+	for _, r := range s.results {
+		failedPhase := r.failedPhase
+		if failedPhase == "" {
+			failedPhase = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.name, r.status, failedPhase, r.duration.Round(time.Second))
+	}
+	_ = w.Flush()
+
+	return sb.String()
 }
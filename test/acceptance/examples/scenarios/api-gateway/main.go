@@ -15,34 +15,43 @@ import (
 )
 
 type TFOutputs struct {
-	ConsulServerLBAddr string `json:"consul_server_lb_address"`
-	ConsulServerToken  string `json:"consul_server_bootstrap_token"`
-	APIGatewayLBURL    string `json:"api_gateway_lb_url"`
+	ConsulServerLBAddr string `json:"consul_server_lb_address" tfoutput:"required,url"`
+	ConsulServerToken  string `json:"consul_server_bootstrap_token" tfoutput:"sensitive"`
+	APIGatewayLBURL    string `json:"api_gateway_lb_url" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResourcesName := fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6))
+	region := common.Region("API_GATEWAY")
+	tfResourcesName := common.ResourceName(fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6)))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "API_GATEWAY",
+		DisplayName:        "API Gateway",
 		FolderName:         "api-gateway",
-		TerraformInputVars: getTerraformVars(tfResourcesName),
+		TerraformInputVars: getTerraformVars(tfResourcesName, region),
 		Validate:           validate(tfResourcesName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"gateways"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-east-1",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -52,8 +61,8 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		consulServerLBAddr := tfOutputs.ConsulServerLBAddr
 		apiGatewayLBURL := tfOutputs.APIGatewayLBURL
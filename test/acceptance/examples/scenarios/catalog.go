@@ -0,0 +1,59 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CheckCatalog verifies that the registered scenarios and the example folders
+// present under the `examples/` directory are in sync. It reports every scenario
+// sharing a folder with another scenario and every example folder that is not
+// deployed by any scenario.
+func CheckCatalog(registry ScenarioRegistry, exampleFolders []string) error {
+	var problems []string
+
+	folders := make(map[string][]string)
+	covered := make(map[string]struct{})
+	for _, scenario := range registry.List() {
+		folder := path.Clean(scenario.FolderName)
+		folders[folder] = append(folders[folder], scenario.Name)
+
+		// Some examples keep their Terraform configuration in a nested
+		// folder, so the example is identified by the top level folder.
+		covered[strings.Split(folder, "/")[0]] = struct{}{}
+	}
+
+	var duplicates []string
+	for folder, names := range folders {
+		if len(names) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("scenarios %s share the folder %s", strings.Join(names, ", "), folder))
+		}
+	}
+	sort.Strings(duplicates)
+	problems = append(problems, duplicates...)
+
+	var uncovered []string
+	for _, folder := range exampleFolders {
+		if _, ok := covered[folder]; !ok {
+			uncovered = append(uncovered, fmt.Sprintf("example %s is not deployed by any scenario", folder))
+		}
+	}
+	sort.Strings(uncovered)
+	problems = append(problems, uncovered...)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("scenario catalog is out of sync with the examples:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// HasTag returns true if the scenario is labelled with the given tag.
+func (r *ScenarioRegistration) HasTag(tag string) bool {
+	return slices.Contains(r.Tags, tag)
+}
@@ -0,0 +1,68 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCatalog(t *testing.T) {
+	cases := map[string]struct {
+		folders        map[string]string
+		exampleFolders []string
+		errStrs        []string
+	}{
+		"catalog in sync": {
+			folders: map[string]string{
+				"FARGATE": "dev-server-fargate",
+				"HCP":     "admin-partitions/terraform",
+			},
+			exampleFolders: []string{"admin-partitions", "dev-server-fargate"},
+		},
+		"scenarios sharing a folder": {
+			folders: map[string]string{
+				"FARGATE":       "dev-server-fargate",
+				"FARGATE_AGAIN": "dev-server-fargate/",
+			},
+			exampleFolders: []string{"dev-server-fargate"},
+			errStrs:        []string{"scenarios FARGATE, FARGATE_AGAIN share the folder dev-server-fargate"},
+		},
+		"example without a scenario": {
+			folders: map[string]string{
+				"FARGATE": "dev-server-fargate",
+			},
+			exampleFolders: []string{"dev-server-ec2", "dev-server-fargate", "mesh-gateways"},
+			errStrs: []string{
+				"example dev-server-ec2 is not deployed by any scenario",
+				"example mesh-gateways is not deployed by any scenario",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			registry := NewScenarioRegistry()
+			for scenarioName, folder := range c.folders {
+				payload := getTestScenarioRegistrationPayload()
+				payload.Name = scenarioName
+				payload.FolderName = folder
+				registry.Register(payload)
+			}
+
+			err := CheckCatalog(registry, c.exampleFolders)
+			if len(c.errStrs) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			for _, errStr := range c.errStrs {
+				require.Contains(t, err.Error(), errStr)
+			}
+		})
+	}
+}
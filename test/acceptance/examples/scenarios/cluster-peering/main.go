@@ -4,9 +4,7 @@
 package clusterpeering
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
@@ -16,36 +14,45 @@ import (
 )
 
 type TFOutputs struct {
-	DC1ConsulServerAddr  string `json:"dc1_server_url"`
-	DC1ConsulServerToken string `json:"dc1_server_bootstrap_token"`
-	DC2ConsulServerAddr  string `json:"dc2_server_url"`
-	DC2ConsulServerToken string `json:"dc2_server_bootstrap_token"`
-	MeshClientLBAddr     string `json:"client_lb_address"`
+	DC1ConsulServerAddr  string `json:"dc1_server_url" tfoutput:"required,url"`
+	DC1ConsulServerToken string `json:"dc1_server_bootstrap_token" tfoutput:"required,sensitive"`
+	DC2ConsulServerAddr  string `json:"dc2_server_url" tfoutput:"required,url"`
+	DC2ConsulServerToken string `json:"dc2_server_bootstrap_token" tfoutput:"required,sensitive"`
+	MeshClientLBAddr     string `json:"client_lb_address" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResourcesName := common.GenerateRandomStr(4)
+	region := common.Region("CLUSTER_PEERING")
+	tfResourcesName := common.ResourceName(common.GenerateRandomStr(4))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "CLUSTER_PEERING",
+		DisplayName:        "Cluster Peering",
 		FolderName:         "cluster-peering",
-		TerraformInputVars: getTerraformVars(tfResourcesName),
+		TerraformInputVars: getTerraformVars(tfResourcesName, region),
 		Validate:           validate(tfResourcesName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"multi-cluster"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-east-2",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -55,9 +62,8 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
-		tfOutputs.MeshClientLBAddr = strings.TrimSuffix(tfOutputs.MeshClientLBAddr, "/ui")
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		logger.Log(t, "Setting up the Consul clients")
 		consulClientOne, err := common.SetupConsulClient(t, tfOutputs.DC1ConsulServerAddr, common.WithToken(tfOutputs.DC1ConsulServerToken))
@@ -77,5 +83,20 @@ func validate(tfResName string) scenarios.ValidateHook {
 		// Perform assertions by hitting the client app's LB
 		logger.Log(t, "calling client app's load balancer to see if the server app in the peer cluster is reachable")
 		common.ValidateFakeServiceResponse(t, tfOutputs.MeshClientLBAddr, serverAppName)
+
+		validatePeeringLifecycle(t, consulClientOne, consulClientTwo, tfResName, serverAppName)
 	}
 }
+
+// validatePeeringLifecycle establishes a second peering between the clusters,
+// next to the one created by Terraform, exports the server app through it
+// and deletes it, checking that the server app is no longer imported.
+func validatePeeringLifecycle(t *testing.T, consulClientOne, consulClientTwo *common.ConsulClientWrapper, tfResName, serverAppName string) {
+	logger.Log(t, "validating the lifecycle of a peering between the clusters")
+	peering := common.EstablishPeering(consulClientOne, consulClientTwo, common.PeeringConfig{
+		AcceptorPeerName: fmt.Sprintf("%s-dc2-lifecycle", tfResName),
+		DialerPeerName:   fmt.Sprintf("%s-dc1-lifecycle", tfResName),
+	})
+	peering.ExportServices(consulClientTwo, "", serverAppName)
+	peering.Delete()
+}
@@ -0,0 +1,345 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// CatalogEventType is the kind of change that a CatalogRecorder observed.
+type CatalogEventType string
+
+const (
+	InstanceAdded         CatalogEventType = "added"
+	InstanceRemoved       CatalogEventType = "removed"
+	InstanceHealthChanged CatalogEventType = "health changed"
+
+	// Mark is an event recorded by the test itself, e.g. the
+	// time at which it stopped a task.
+	Mark CatalogEventType = "mark"
+)
+
+// CatalogEvent is a change of a service instance, identified by
+// its node and service ID, or a mark recorded by the test.
+type CatalogEvent struct {
+	Time     time.Time
+	Type     CatalogEventType
+	Service  string
+	Instance string
+
+	// Status is the aggregated status of the health checks of the
+	// instance after the event and PreviousStatus the one before.
+	Status         string
+	PreviousStatus string
+
+	// Label describes a Mark.
+	Label string
+}
+
+func (e CatalogEvent) String() string {
+	ts := e.Time.Format("15:04:05.000")
+	switch e.Type {
+	case Mark:
+		return fmt.Sprintf("%s mark %q", ts, e.Label)
+	case InstanceAdded:
+		return fmt.Sprintf("%s %s %s added (%s)", ts, e.Service, e.Instance, e.Status)
+	case InstanceRemoved:
+		return fmt.Sprintf("%s %s %s removed", ts, e.Service, e.Instance)
+	default:
+		return fmt.Sprintf("%s %s %s %s -> %s", ts, e.Service, e.Instance, e.PreviousStatus, e.Status)
+	}
+}
+
+// CatalogTimeline is the chronological list of events recorded by a CatalogRecorder.
+type CatalogTimeline []CatalogEvent
+
+func (tl CatalogTimeline) String() string {
+	lines := make([]string, 0, len(tl))
+	for _, e := range tl {
+		lines = append(lines, "  - "+e.String())
+	}
+	return fmt.Sprintf("%d catalog events\n%s", len(tl), strings.Join(lines, "\n"))
+}
+
+// First returns the first event recorded at or after the time that matches.
+func (tl CatalogTimeline) First(after time.Time, match CatalogEventMatcher) (CatalogEvent, bool) {
+	for _, e := range tl {
+		if !e.Time.Before(after) && match(e) {
+			return e, true
+		}
+	}
+	return CatalogEvent{}, false
+}
+
+// CatalogEventMatcher selects the events that a test waits for.
+type CatalogEventMatcher func(CatalogEvent) bool
+
+// ForInstance restricts the matcher to the instances whose ID
+// contains the substring, e.g. the ID of an ECS task.
+func (m CatalogEventMatcher) ForInstance(substr string) CatalogEventMatcher {
+	return func(e CatalogEvent) bool {
+		return strings.Contains(e.Instance, substr) && m(e)
+	}
+}
+
+// ExceptInstances restricts the matcher to the instances that are not
+// listed, e.g. to the replacements of the instances returned by
+// CatalogRecorder.Instances before a task was stopped.
+func (m CatalogEventMatcher) ExceptInstances(ids ...string) CatalogEventMatcher {
+	except := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		except[id] = true
+	}
+	return func(e CatalogEvent) bool {
+		return !except[e.Instance] && m(e)
+	}
+}
+
+// InstanceAddedTo matches the registration of an instance of the service.
+func InstanceAddedTo(service string) CatalogEventMatcher {
+	return func(e CatalogEvent) bool {
+		return e.Type == InstanceAdded && e.Service == service
+	}
+}
+
+// InstanceRemovedFrom matches the deregistration of an instance of the service.
+func InstanceRemovedFrom(service string) CatalogEventMatcher {
+	return func(e CatalogEvent) bool {
+		return e.Type == InstanceRemoved && e.Service == service
+	}
+}
+
+// InstanceHealthy matches an instance of the service turning healthy,
+// including instances that are healthy as soon as they are registered.
+// Use ExceptInstances to ignore existing instances that recover.
+func InstanceHealthy(service string) CatalogEventMatcher {
+	return func(e CatalogEvent) bool {
+		return (e.Type == InstanceAdded || e.Type == InstanceHealthChanged) &&
+			e.Service == service && e.Status == api.HealthPassing
+	}
+}
+
+// CatalogRecorder watches the instances of services with blocking queries
+// in the background and records when instances are added, removed or change
+// health. The time of an event is the time at which the blocking query
+// returned, so it lags behind the change by the latency of the query.
+type CatalogRecorder struct {
+	ccw    *ConsulClientWrapper
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	timeline CatalogTimeline
+	// changed is closed and replaced whenever an event is recorded.
+	changed chan struct{}
+	// instances maps the services to the last observed
+	// statuses of their instances.
+	instances map[string]map[string]string
+}
+
+// StartCatalogRecorder starts recording the events of the services. The
+// instances that exist when the recorder starts are not recorded as events.
+// The test fails if the initial instances of the services cannot be read
+// within the timeout of the retry policy. The recorder is stopped when the
+// test completes, if not stopped before.
+func (ccw *ConsulClientWrapper) StartCatalogRecorder(queryOpts *api.QueryOptions, services ...string) *CatalogRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &CatalogRecorder{ccw: ccw, cancel: cancel, changed: make(chan struct{}), instances: make(map[string]map[string]string)}
+
+	ready := make(chan string, len(services))
+	for _, service := range services {
+		r.wg.Add(1)
+		go r.watch(ctx, service, queryOpts, ready)
+	}
+	// Wait for the initial state of every service so that the events
+	// that follow StartCatalogRecorder are not taken for the baseline.
+	pending := make(map[string]bool, len(services))
+	for _, service := range services {
+		pending[service] = true
+	}
+	timeout := time.After(ccw.retryPolicy.Timeout)
+	for len(pending) > 0 {
+		select {
+		case service := <-ready:
+			delete(pending, service)
+		case <-timeout:
+			r.Stop()
+			require.FailNowf(ccw.t, "failed to start the catalog recorder", "timed out after %s waiting for the instances of %s",
+				ccw.retryPolicy.Timeout, strings.Join(sortedKeys(pending), ", "))
+		}
+	}
+
+	ccw.t.Cleanup(func() {
+		r.Stop()
+	})
+	return r
+}
+
+// Mark records a labelled event, e.g. "StopTask", and returns its time
+// so that the test can wait for the events that follow it.
+func (r *CatalogRecorder) Mark(label string) time.Time {
+	e := CatalogEvent{Time: time.Now(), Type: Mark, Label: label}
+	r.record(e)
+	return e.Time
+}
+
+// Instances returns the IDs of the instances of the service as of the last
+// observed change, e.g. to tell replacements apart from the instances that
+// existed before a task was stopped.
+func (r *CatalogRecorder) Instances(service string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortedKeys(r.instances[service])
+}
+
+// Timeline returns the events recorded so far.
+func (r *CatalogRecorder) Timeline() CatalogTimeline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(CatalogTimeline(nil), r.timeline...)
+}
+
+// Stop stops the recorder and returns the events that it recorded.
+func (r *CatalogRecorder) Stop() CatalogTimeline {
+	r.cancel()
+	r.wg.Wait()
+	return r.Timeline()
+}
+
+// WaitForEvent waits for an event that matches to be recorded within the
+// bound after the time, e.g. the time returned by Mark. The returned error
+// lists all the recorded events if no event matches in time.
+func (r *CatalogRecorder) WaitForEvent(after time.Time, within time.Duration, what string, match CatalogEventMatcher) (CatalogEvent, error) {
+	deadline := after.Add(within)
+	for {
+		r.mu.Lock()
+		timeline, changed := r.timeline, r.changed
+		r.mu.Unlock()
+
+		if e, ok := timeline.First(after, match); ok {
+			if e.Time.After(deadline) {
+				return e, fmt.Errorf("expected %s within %s but it took %s\n%s", what, within, e.Time.Sub(after), timeline)
+			}
+			return e, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return CatalogEvent{}, fmt.Errorf("expected %s within %s but it did not happen\n%s", what, within, timeline)
+		}
+		select {
+		case <-changed:
+		case <-time.After(remaining):
+		}
+	}
+}
+
+func (r *CatalogRecorder) record(events ...CatalogEvent) {
+	if len(events) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeline = append(r.timeline, events...)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// watch runs blocking queries on the health of the instances of the service
+// until the context is cancelled, recording the differences between results.
+func (r *CatalogRecorder) watch(ctx context.Context, service string, queryOpts *api.QueryOptions, ready chan<- string) {
+	defer r.wg.Done()
+
+	var (
+		index     uint64
+		attempt   int
+		instances map[string]string
+	)
+	for ctx.Err() == nil {
+		opts := &api.QueryOptions{}
+		if queryOpts != nil {
+			*opts = *queryOpts
+		}
+		opts.WaitIndex = index
+
+		entries, meta, err := r.ccw.client.Health().Service(service, "", false, opts.WithContext(ctx))
+		now := time.Now()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(r.ccw.retryPolicy.Backoff(attempt)):
+			}
+			attempt++
+			continue
+		}
+		attempt = 0
+
+		current := instanceStatuses(entries)
+		r.mu.Lock()
+		r.instances[service] = current
+		r.mu.Unlock()
+		if instances == nil {
+			ready <- service
+		} else {
+			r.record(diffInstances(now, service, instances, current)...)
+		}
+		instances = current
+
+		// The index going backwards means that the state was reset,
+		// e.g. after a snapshot restore, so start over.
+		index = meta.LastIndex
+		if meta.LastIndex < opts.WaitIndex {
+			index = 0
+		}
+	}
+}
+
+// instanceStatuses maps the instances of the entries to their aggregated health status.
+func instanceStatuses(entries []*api.ServiceEntry) map[string]string {
+	statuses := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		id := fmt.Sprintf("%s/%s", entry.Node.Node, entry.Service.ID)
+		statuses[id] = entry.Checks.AggregatedStatus()
+	}
+	return statuses
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func diffInstances(now time.Time, service string, previous, current map[string]string) []CatalogEvent {
+	var events []CatalogEvent
+	for id, status := range current {
+		prevStatus, existed := previous[id]
+		switch {
+		case !existed:
+			events = append(events, CatalogEvent{Time: now, Type: InstanceAdded, Service: service, Instance: id, Status: status})
+		case prevStatus != status:
+			events = append(events, CatalogEvent{Time: now, Type: InstanceHealthChanged, Service: service, Instance: id, Status: status, PreviousStatus: prevStatus})
+		}
+	}
+	for id, prevStatus := range previous {
+		if _, ok := current[id]; !ok {
+			events = append(events, CatalogEvent{Time: now, Type: InstanceRemoved, Service: service, Instance: id, PreviousStatus: prevStatus})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Instance < events[j].Instance
+	})
+	return events
+}
@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHealth serves the health endpoint of a service with blocking
+// queries that return as soon as the instances change.
+type fakeHealth struct {
+	mu        sync.Mutex
+	index     uint64
+	instances map[string]string
+	changed   chan struct{}
+}
+
+func newFakeHealth(t *testing.T) (*fakeHealth, string) {
+	f := &fakeHealth{index: 1, instances: map[string]string{}, changed: make(chan struct{})}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+	return f, server.URL
+}
+
+func (f *fakeHealth) set(id, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if status == "" {
+		delete(f.instances, id)
+	} else {
+		f.instances[id] = status
+	}
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeHealth) serve(w http.ResponseWriter, req *http.Request) {
+	waitIndex, _ := strconv.ParseUint(req.URL.Query().Get("index"), 10, 64)
+
+	f.mu.Lock()
+	for f.index <= waitIndex {
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-req.Context().Done():
+			return
+		}
+		f.mu.Lock()
+	}
+	entries := []*api.ServiceEntry{}
+	for id, status := range f.instances {
+		entries = append(entries, &api.ServiceEntry{
+			Node:    &api.Node{Node: "node"},
+			Service: &api.AgentService{ID: id, Service: "server"},
+			Checks:  api.HealthChecks{{Status: status}},
+		})
+	}
+	index := f.index
+	f.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+func TestCatalogRecorder(t *testing.T) {
+	fake, addr := newFakeHealth(t)
+	fake.set("server-task1", api.HealthPassing)
+
+	ccw, err := SetupConsulClient(t, addr, WithRetryPolicy(RetryPolicy{Timeout: 5 * time.Second, InitialWait: 10 * time.Millisecond}))
+	require.NoError(t, err)
+	recorder := ccw.StartCatalogRecorder(nil, "server")
+	existing := recorder.Instances("server")
+	require.Equal(t, []string{"node/server-task1"}, existing)
+	replacementHealthy := InstanceHealthy("server").ExceptInstances(existing...)
+
+	// Blocking queries may coalesce changes, so every change
+	// is awaited before making the next one.
+	stopped := recorder.Mark("StopTask")
+	fake.set("server-task1", api.HealthCritical)
+	_, err = recorder.WaitForEvent(stopped, 5*time.Second, "task1 to be critical", func(e CatalogEvent) bool {
+		return e.Type == InstanceHealthChanged && e.Status == api.HealthCritical
+	})
+	require.NoError(t, err)
+
+	// The stopped instance recovering is not taken for a replacement.
+	fake.set("server-task1", api.HealthPassing)
+	_, err = recorder.WaitForEvent(stopped, 5*time.Second, "task1 to recover", InstanceHealthy("server"))
+	require.NoError(t, err)
+	_, err = recorder.WaitForEvent(stopped, 50*time.Millisecond, "a replacement to be healthy", replacementHealthy)
+	require.ErrorContains(t, err, "expected a replacement to be healthy within 50ms but it did not happen")
+
+	fake.set("server-task1", "")
+	removed, err := recorder.WaitForEvent(stopped, 5*time.Second, "task1 to be deregistered", InstanceRemovedFrom("server").ForInstance("task1"))
+	require.NoError(t, err)
+	require.Equal(t, "node/server-task1", removed.Instance)
+
+	fake.set("server-task2", api.HealthCritical)
+	_, err = recorder.WaitForEvent(stopped, 5*time.Second, "task2 to be added", InstanceAddedTo("server").ForInstance("task2"))
+	require.NoError(t, err)
+	fake.set("server-task2", api.HealthPassing)
+	replaced, err := recorder.WaitForEvent(stopped, 5*time.Second, "a replacement to be healthy", replacementHealthy)
+	require.NoError(t, err)
+	require.Equal(t, "node/server-task2", replaced.Instance)
+	require.Equal(t, []string{"node/server-task2"}, recorder.Instances("server"))
+
+	_, err = recorder.WaitForEvent(stopped, 50*time.Millisecond, "task3 to be added", InstanceAddedTo("server").ForInstance("task3"))
+	require.ErrorContains(t, err, "expected task3 to be added within 50ms but it did not happen")
+
+	_, err = recorder.WaitForEvent(stopped, time.Nanosecond, "task2 to be added", InstanceAddedTo("server").ForInstance("task2"))
+	require.ErrorContains(t, err, "expected task2 to be added within 1ns but it took")
+
+	timeline := recorder.Stop()
+	var events []string
+	for _, e := range timeline {
+		events = append(events, string(e.Type)+" "+e.Instance+" "+e.Status)
+	}
+	require.Equal(t, []string{
+		"mark  ",
+		"health changed node/server-task1 critical",
+		"health changed node/server-task1 passing",
+		"removed node/server-task1 ",
+		"added node/server-task2 critical",
+		"health changed node/server-task2 passing",
+	}, events)
+}
+
+func TestDiffInstances(t *testing.T) {
+	now := time.Now()
+	events := diffInstances(now, "server",
+		map[string]string{"a": api.HealthPassing, "b": api.HealthPassing},
+		map[string]string{"b": api.HealthCritical, "c": api.HealthWarning},
+	)
+	require.Equal(t, []CatalogEvent{
+		{Time: now, Type: InstanceRemoved, Service: "server", Instance: "a", PreviousStatus: api.HealthPassing},
+		{Time: now, Type: InstanceHealthChanged, Service: "server", Instance: "b", Status: api.HealthCritical, PreviousStatus: api.HealthPassing},
+		{Time: now, Type: InstanceAdded, Service: "server", Instance: "c", Status: api.HealthWarning},
+	}, events)
+}
@@ -4,9 +4,8 @@
 package common
 
 import (
-	"io"
 	"math/rand"
-	"net/http"
+	"os"
 	"strings"
 )
 
@@ -14,25 +13,6 @@ const (
 	characterSet = "abcdefghijklmnopqrstuvwxyz"
 )
 
-// This method relies on a third party API to retrieve
-// the public IP of the host where this test runs.
-func GetPublicIP() (string, error) {
-	resp, err := http.Get("https://api64.ipify.org?format=text")
-	if err != nil {
-		return "", err
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
-
-	ip, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(ip), nil
-}
-
 // GenerateRandomStr generate a random string of a given length
 // from the predefined characterSet.
 //
@@ -44,3 +24,13 @@ func GenerateRandomStr(length int) string {
 	}
 	return strings.ToLower(string(result))
 }
+
+// ResourceName returns the name used to prefix the terraform resources
+// of a scenario. The TEST_RESOURCE_NAME environment variable overrides
+// defaultName, e.g. to validate an existing deployment with -reuse-state.
+func ResourceName(defaultName string) string {
+	if name := os.Getenv("TEST_RESOURCE_NAME"); name != "" {
+		return name
+	}
+	return defaultName
+}
@@ -0,0 +1,41 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/configentries"
+)
+
+// configEntries returns the manager of the config entries of the test.
+func (ccw *ConsulClientWrapper) configEntries() *configentries.Manager {
+	return configentries.NewManager(ccw.t, ccw.client, ccw.retryPolicy)
+}
+
+// WriteConfigEntry writes a config entry of any kind, e.g. service-defaults,
+// proxy-defaults, service-resolver, service-router, service-splitter,
+// exported-services, sameness-group or gateway routes, and waits until Consul
+// reports it as applied. The entry that existed before is restored, or the
+// new entry deleted, when the test completes.
+func (ccw *ConsulClientWrapper) WriteConfigEntry(entry api.ConfigEntry) {
+	ccw.configEntries().Write(entry)
+}
+
+// DeleteConfigEntry deletes a config entry and waits until Consul no
+// longer returns it. The entry is restored when the test completes.
+func (ccw *ConsulClientWrapper) DeleteConfigEntry(kind, name string, queryOpts *api.QueryOptions) {
+	ccw.configEntries().Delete(kind, name, queryOpts)
+}
+
+// ReadConfigEntry returns the config entry or nil if it does not exist.
+func (ccw *ConsulClientWrapper) ReadConfigEntry(kind, name string, queryOpts *api.QueryOptions) api.ConfigEntry {
+	return ccw.configEntries().Read(kind, name, queryOpts)
+}
+
+// WriteServiceIntentions writes the intentions and waits until Consul
+// applied them. The intentions that existed before are restored, or the
+// new ones deleted, when the test completes.
+func (ccw *ConsulClientWrapper) WriteServiceIntentions(intentions *configentries.ServiceIntentions) {
+	ccw.configEntries().WriteServiceIntentions(intentions)
+}
@@ -5,45 +5,75 @@ package common
 
 import (
 	"fmt"
+	"sort"
 	"testing"
-	"time"
 
 	"github.com/hashicorp/consul/api"
-	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
 	"github.com/stretchr/testify/require"
 )
 
 type ConsulClientWrapper struct {
-	t      *testing.T
-	client *api.Client
+	t           *testing.T
+	client      *api.Client
+	retryPolicy RetryPolicy
 }
 
-type ClientOpts func(*api.Config)
+type consulClientConfig struct {
+	api         *api.Config
+	client      *api.Client
+	retryPolicy RetryPolicy
+}
+
+type ClientOpts func(*consulClientConfig)
 
 // SetupConsulClient sets up a consul client that can be used to directly
 // interact with the consul server.
 func SetupConsulClient(t *testing.T, serverAddr string, opts ...ClientOpts) (*ConsulClientWrapper, error) {
-	cfg := api.DefaultConfig()
-	cfg.Address = serverAddr
+	cfg := &consulClientConfig{
+		api:         api.DefaultConfig(),
+		retryPolicy: DefaultRetryPolicy(),
+	}
+	cfg.api.Address = serverAddr
 
 	for _, opt := range opts {
 		opt(cfg)
 	}
 
-	client, err := api.NewClient(cfg)
-	if err != nil {
-		return nil, err
+	client := cfg.client
+	if client == nil {
+		var err error
+		if client, err = api.NewClient(cfg.api); err != nil {
+			return nil, err
+		}
 	}
 	return &ConsulClientWrapper{
-		t:      t,
-		client: client,
+		t:           t,
+		client:      client,
+		retryPolicy: cfg.retryPolicy,
 	}, nil
 }
 
 func WithToken(token string) ClientOpts {
-	return func(c *api.Config) {
-		c.Token = token
+	return func(c *consulClientConfig) {
+		c.api.Token = token
+	}
+}
+
+// WithClient makes the wrapper use the client, e.g. a client of a fake
+// Consul, instead of one built for the server address and the token.
+func WithClient(client *api.Client) ClientOpts {
+	return func(c *consulClientConfig) {
+		c.client = client
+	}
+}
+
+// WithRetryPolicy sets the policy used by the helpers
+// that wait for the state of Consul to converge.
+func WithRetryPolicy(policy RetryPolicy) ClientOpts {
+	return func(c *consulClientConfig) {
+		c.retryPolicy = policy
 	}
 }
 
@@ -55,86 +85,102 @@ func (ccw *ConsulClientWrapper) EnsureServiceReadiness(name string, queryOpts *a
 }
 
 // EnsureServiceDeregistration makes sure that a service with a given name
-// is registered as part of Consul's catalog
+// is not registered as part of Consul's catalog
 func (ccw *ConsulClientWrapper) EnsureServiceDeregistration(name string, queryOpts *api.QueryOptions) {
 	logger.Log(ccw.t, fmt.Sprintf("checking if service %s is deregistered from Consul", name))
-	retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, ccw.t, func(r *retry.R) {
-		exists, err := ccw.serviceExists(name, queryOpts)
-		require.NoError(r, err)
-		require.False(r, exists)
+	err := ccw.waitFor(fmt.Sprintf("service %s to be deregistered", name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		services, meta, err := ccw.client.Catalog().Services(opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		_, ok := services[name]
+		return !ok, describeServices(services), meta, nil
 	})
+	require.NoError(ccw.t, err)
 }
 
 // EnsureServiceInstances verifies if the number of service instances for a service
 // in Consul catalog matches the expected count.
 func (ccw *ConsulClientWrapper) EnsureServiceInstances(name string, expectedCount int, queryOpts *api.QueryOptions) {
-	logger.Log(ccw.t, fmt.Sprintf("checking if service %s has two instances registered", name))
-	retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, ccw.t, func(r *retry.R) {
-		instances, err := ccw.listServiceInstances(name, nil)
-		require.NoError(r, err)
-		require.Len(r, instances, expectedCount)
+	logger.Log(ccw.t, fmt.Sprintf("checking if service %s has %d instances registered", name, expectedCount))
+	err := ccw.waitFor(fmt.Sprintf("service %s to have %d instances", name, expectedCount), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		instances, meta, err := ccw.client.Catalog().Service(name, "", opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		return len(instances) == expectedCount, describeInstances(instances), meta, nil
 	})
+	require.NoError(ccw.t, err)
 }
 
 // ensureServiceRegistration makes sure that a service with a given name
 // is registered as part of Consul's catalog
 func (ccw *ConsulClientWrapper) ensureServiceRegistration(name string, queryOpts *api.QueryOptions) {
 	logger.Log(ccw.t, fmt.Sprintf("checking if service %s is registered in Consul", name))
-	retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, ccw.t, func(r *retry.R) {
-		exists, err := ccw.serviceExists(name, queryOpts)
-		require.NoError(r, err)
-		require.True(r, exists)
+	err := ccw.waitFor(fmt.Sprintf("service %s to be registered", name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		services, meta, err := ccw.client.Catalog().Services(opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		_, ok := services[name]
+		return ok, describeServices(services), meta, nil
 	})
+	require.NoError(ccw.t, err)
 }
 
-// ensureHealthyService polls the catalog endpoint to understand a service's health status.
+// ensureHealthyService waits for all the instances of a service to be healthy.
 // Note that the health of a service is an accumulation of all the health checks associated
 // with that of the service instances of that service.
-func (ccw *ConsulClientWrapper) ensureHealthyService(name string, opts *api.QueryOptions) {
+func (ccw *ConsulClientWrapper) ensureHealthyService(name string, queryOpts *api.QueryOptions) {
 	logger.Log(ccw.t, fmt.Sprintf("checking if all instances of %s are healthy", name))
-	retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, ccw.t, func(r *retry.R) {
-		healthy, err := ccw.isServiceHealthy(name, opts)
-		require.NoError(r, err)
-		require.True(r, healthy)
-	})
+	_, err := ccw.WaitForHealthyService(name, queryOpts)
+	require.NoError(ccw.t, err)
 }
 
-// serviceExists verifies if a service with a given name exists in Consul's catalog.
-func (ccw *ConsulClientWrapper) serviceExists(serviceName string, queryOpts *api.QueryOptions) (bool, error) {
-	services, err := ccw.listServices(queryOpts)
-	if err != nil {
-		return false, err
-	}
-
-	_, ok := services[serviceName]
-	return ok, nil
+// WaitForHealthyService waits for the service to have instances whose health
+// checks all pass and returns the health of every instance. If the service
+// does not become healthy before the retry policy's timeout, the returned
+// *WaitTimeoutError holds the last *ServiceHealthReport observed, which
+// lists the status and output of every check of every instance.
+func (ccw *ConsulClientWrapper) WaitForHealthyService(name string, queryOpts *api.QueryOptions) (*ServiceHealthReport, error) {
+	var report *ServiceHealthReport
+	err := ccw.waitFor(fmt.Sprintf("all instances of %s to be healthy", name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		entries, meta, err := ccw.client.Health().Service(name, "", false, opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		report = newServiceHealthReport(name, entries)
+		return report.Healthy(), report, meta, nil
+	})
+	return report, err
 }
 
-// isServiceHealthy verifies if all service instances of a service with a given name are healthy in Consul's catalog.
-func (ccw *ConsulClientWrapper) isServiceHealthy(serviceName string, queryOpts *api.QueryOptions) (bool, error) {
-	res, _, err := ccw.client.Health().Checks(serviceName, queryOpts)
-	if err != nil {
-		return false, err
-	}
+// queryFunc queries Consul using the given options and reports whether the
+// awaited state was reached along with a description of the observed state.
+type queryFunc = consulwait.QueryFunc
 
-	return res.AggregatedStatus() == api.HealthPassing, nil
+// waitFor runs query until it reports that the awaited state was reached or
+// the retry policy's timeout elapses, see consulwait.Wait.
+func (ccw *ConsulClientWrapper) waitFor(what string, queryOpts *api.QueryOptions, query queryFunc) error {
+	return consulwait.Wait(ccw.retryPolicy, what, queryOpts, query)
 }
 
-// ListServiceInstances returns the list of service instances for a given service
-func (c *ConsulClientWrapper) listServiceInstances(serviceName string, queryOpts *api.QueryOptions) ([]*api.CatalogService, error) {
-	instances, _, err := c.client.Catalog().Service(serviceName, "", queryOpts)
-	if err != nil {
-		return nil, err
-	}
+// stateString is a plain description of the state of Consul.
+type stateString = consulwait.State
 
-	return instances, nil
+func describeServices(services map[string][]string) stateString {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return stateString(fmt.Sprintf("registered services %v", names))
 }
 
-func (c *ConsulClientWrapper) listServices(opts *api.QueryOptions) (map[string][]string, error) {
-	services, _, err := c.client.Catalog().Services(opts)
-	if err != nil {
-		return nil, err
+func describeInstances(instances []*api.CatalogService) stateString {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, fmt.Sprintf("%s/%s", instance.Node, instance.ServiceID))
 	}
-
-	return services, nil
+	return stateString(fmt.Sprintf("%d instances %v", len(instances), ids))
 }
@@ -0,0 +1,209 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCatalog serves the catalog services endpoint of Consul
+// and supports blocking queries.
+type fakeCatalog struct {
+	mu       sync.Mutex
+	index    uint64
+	services map[string][]string
+	changed  chan struct{}
+	failures int
+	requests int
+}
+
+func newFakeCatalog(t *testing.T, services ...string) (*fakeCatalog, string) {
+	c := &fakeCatalog{
+		index:    1,
+		services: make(map[string][]string),
+		changed:  make(chan struct{}),
+	}
+	for _, name := range services {
+		c.services[name] = nil
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(c.serveServices))
+	t.Cleanup(server.Close)
+	return c, server.URL
+}
+
+func (c *fakeCatalog) register(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.services[name] = nil
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *fakeCatalog) serveServices(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	c.requests++
+	if c.failures > 0 {
+		c.failures--
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	changed := c.changed
+	blocking := false
+	if index, err := strconv.ParseUint(req.URL.Query().Get("index"), 10, 64); err == nil && index >= c.index {
+		blocking = true
+	}
+	c.mu.Unlock()
+
+	if blocking {
+		wait, err := time.ParseDuration(req.URL.Query().Get("wait"))
+		if err != nil {
+			wait = 5 * time.Minute
+		}
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	_ = json.NewEncoder(w).Encode(c.services)
+}
+
+func TestWaitForBlockingQuery(t *testing.T) {
+	catalog, addr := newFakeCatalog(t, "consul")
+
+	// The backoff is longer than the timeout so the
+	// check only succeeds if the query blocks.
+	policy := RetryPolicy{Timeout: 5 * time.Second, InitialWait: time.Minute}
+	ccw, err := SetupConsulClient(t, addr, WithRetryPolicy(policy))
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		catalog.register("web")
+	}()
+
+	start := time.Now()
+	ccw.ensureServiceRegistration("web", nil)
+	require.Less(t, time.Since(start), 2*time.Second)
+
+	catalog.mu.Lock()
+	defer catalog.mu.Unlock()
+	require.Equal(t, 2, catalog.requests)
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	_, addr := newFakeCatalog(t, "consul", "api")
+
+	ccw, err := SetupConsulClient(t, addr, WithRetryPolicy(RetryPolicy{Timeout: 300 * time.Millisecond}))
+	require.NoError(t, err)
+
+	err = ccw.waitFor("service web to be registered", nil, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		services, meta, err := ccw.client.Catalog().Services(opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		_, ok := services["web"]
+		return ok, describeServices(services), meta, nil
+	})
+
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, "registered services [api consul]", timeoutErr.LastState.String())
+	require.Contains(t, err.Error(), "timed out after 300ms waiting for service web to be registered")
+}
+
+func TestWaitForRetriesErrors(t *testing.T) {
+	catalog, addr := newFakeCatalog(t, "web")
+	catalog.failures = 2
+
+	ccw, err := SetupConsulClient(t, addr, WithRetryPolicy(RetryPolicy{
+		Timeout:     5 * time.Second,
+		InitialWait: 10 * time.Millisecond,
+		Multiplier:  2,
+	}))
+	require.NoError(t, err)
+
+	ccw.ensureServiceRegistration("web", nil)
+
+	catalog.mu.Lock()
+	defer catalog.mu.Unlock()
+	require.Equal(t, 3, catalog.requests)
+}
+
+func TestWaitForTimeoutWithError(t *testing.T) {
+	ccw, err := SetupConsulClient(t, "127.0.0.1:1", WithRetryPolicy(RetryPolicy{
+		Timeout:     100 * time.Millisecond,
+		InitialWait: 10 * time.Millisecond,
+	}))
+	require.NoError(t, err)
+
+	queryErr := errors.New("connection refused")
+	err = ccw.waitFor("anything", nil, func(*api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		return false, nil, nil, queryErr
+	})
+	require.ErrorIs(t, err, queryErr)
+	require.Contains(t, err.Error(), "last error: connection refused")
+}
+
+func TestEnsureServiceReadiness(t *testing.T) {
+	consul := fakes.NewConsul(t, fakes.ServiceInstance{ID: "web-1", Service: "web", Status: api.HealthCritical})
+	consul.MaxWait = 10 * time.Millisecond
+	consul.After(5, func() { consul.SetStatus("web-1", api.HealthPassing) })
+	consul.FailNext(1, http.StatusInternalServerError)
+
+	ccw, err := SetupConsulClient(t, "", WithClient(consul.Client(t)), WithRetryPolicy(RetryPolicy{Timeout: 5 * time.Second, InitialWait: 10 * time.Millisecond}))
+	require.NoError(t, err)
+
+	// The registration check fails once and then finds the service, and the
+	// health check polls until the instance becomes healthy on the fifth poll.
+	ccw.EnsureServiceReadiness("web", nil)
+	require.Equal(t, 5, consul.Polls())
+
+	consul.After(1, func() { consul.Register(fakes.ServiceInstance{ID: "web-2", Service: "web"}) })
+	ccw.EnsureServiceInstances("web", 2, nil)
+
+	consul.After(1, func() {
+		consul.Deregister("web-1")
+		consul.Deregister("web-2")
+	})
+	ccw.EnsureServiceDeregistration("web", nil)
+}
+
+func TestWaitForHealthyServiceTimeout(t *testing.T) {
+	consul := fakes.NewConsul(t,
+		fakes.ServiceInstance{ID: "web-1", Service: "web"},
+		fakes.ServiceInstance{ID: "web-2", Service: "web", Status: api.HealthCritical},
+	)
+	consul.MaxWait = 10 * time.Millisecond
+
+	ccw, err := SetupConsulClient(t, "", WithClient(consul.Client(t)), WithRetryPolicy(RetryPolicy{Timeout: 100 * time.Millisecond, InitialWait: 10 * time.Millisecond}))
+	require.NoError(t, err)
+
+	report, err := ccw.WaitForHealthyService("web", nil)
+	var timeoutErr *WaitTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.False(t, report.Healthy())
+	require.Contains(t, err.Error(), "web-2")
+}
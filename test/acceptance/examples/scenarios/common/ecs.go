@@ -5,37 +5,37 @@ package common
 
 import (
 	"context"
-	"fmt"
-	"testing"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
-	"github.com/gruntwork-io/terratest/modules/shell"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
 )
 
 type ECSClientWrapper struct {
-	client     *ecs.Client
+	client     ecsapi.API
 	clusterARN string
 	region     string
 }
 
 type ECSClientWrapperOpts func(*ECSClientWrapper)
 
+// NewECSClient returns a client for the ECS API. The client talks to the
+// region given with WithRegion, unless a client is given with WithECSClient.
+// The region defaults to DefaultRegion.
 func NewECSClient(opts ...ECSClientWrapperOpts) (*ECSClientWrapper, error) {
-	cfg, err := config.LoadDefaultConfig(context.TODO())
-	if err != nil {
-		return nil, err
-	}
-
-	ew := &ECSClientWrapper{}
+	ew := &ECSClientWrapper{region: DefaultRegion()}
 	for _, opt := range opts {
 		opt(ew)
 	}
+	if ew.client != nil {
+		return ew, nil
+	}
 
-	if ew.region != "" {
-		cfg.Region = ew.region
+	client, err := ecsapi.NewClient(context.TODO(), ew.region)
+	if err != nil {
+		return nil, err
 	}
-	ew.client = ecs.NewFromConfig(cfg)
+	ew.client = client
 
 	return ew, nil
 }
@@ -52,6 +52,14 @@ func WithRegion(region string) ECSClientWrapperOpts {
 	}
 }
 
+// WithECSClient makes the wrapper use the client, e.g. a client of a fake
+// ECS API or a mock, instead of one built from the default AWS configuration.
+func WithECSClient(client ecsapi.API) ECSClientWrapperOpts {
+	return func(ew *ECSClientWrapper) {
+		ew.client = client
+	}
+}
+
 func (e *ECSClientWrapper) WithClusterARN(clusterARN string) *ECSClientWrapper {
 	e.clusterARN = clusterARN
 	return e
@@ -59,84 +67,27 @@ func (e *ECSClientWrapper) WithClusterARN(clusterARN string) *ECSClientWrapper {
 
 // ListTasksForService returns back the taskARN list for a given service
 func (e *ECSClientWrapper) ListTasksForService(service string) ([]string, error) {
-	taskARNs := make([]string, 0)
-	var nextToken *string
-	for {
-		req := &ecs.ListTasksInput{
-			Cluster:     &e.clusterARN,
-			ServiceName: &service,
-			NextToken:   nextToken,
-		}
-
-		res, err := e.client.ListTasks(context.TODO(), req)
-		if err != nil {
-			return nil, err
-		}
-		nextToken = res.NextToken
-
-		taskARNs = append(taskARNs, res.TaskArns...)
-		if nextToken == nil {
-			break
-		}
-	}
-
-	return taskARNs, nil
+	return ecsapi.ListTasks(context.TODO(), e.client, e.clusterARN, ecsapi.ListTasksFilter{ServiceName: service})
 }
 
 // DescribeTasks returns back a detailed description of all the tasks passed as input.
 func (e *ECSClientWrapper) DescribeTasks(taskIDs []string) (*ecs.DescribeTasksOutput, error) {
-	req := &ecs.DescribeTasksInput{
-		Tasks:   taskIDs,
-		Cluster: &e.clusterARN,
-	}
-
-	return e.client.DescribeTasks(context.TODO(), req)
+	return ecsapi.DescribeTasks(context.TODO(), e.client, e.clusterARN, taskIDs...)
 }
 
 // StopTask stops a given task with a reason
 func (e *ECSClientWrapper) StopTask(taskID, reason string) error {
-	req := &ecs.StopTaskInput{
-		Task:    &taskID,
-		Cluster: &e.clusterARN,
-		Reason:  &reason,
-	}
-
-	_, err := e.client.StopTask(context.TODO(), req)
-	return err
+	return ecsapi.StopTask(context.TODO(), e.client, e.clusterARN, taskID, reason)
 }
 
 // UpdateService scales the number of tasks governed by the service to the
 // desiredCount.
 func (e *ECSClientWrapper) UpdateService(serviceName string, desiredCount int32) error {
-	req := &ecs.UpdateServiceInput{
-		Service:      &serviceName,
-		Cluster:      &e.clusterARN,
-		DesiredCount: &desiredCount,
-	}
-
-	_, err := e.client.UpdateService(context.TODO(), req)
-	return err
+	return ecsapi.UpdateServiceDesiredCount(context.TODO(), e.client, e.clusterARN, serviceName, desiredCount)
 }
 
-// ExecuteCommandInteractive runs the provided command inside a container in the ECS task
-// and returns back the results.
-//
-// Note: Ideally we should try to use the SDK for this but it wasn't straight forward and
-// there was no clear documentation around the same.
-func (e *ECSClientWrapper) ExecuteCommandInteractive(t *testing.T, taskARN, container, command string) (string, error) {
-	return shell.RunCommandAndGetOutputE(t, shell.Command{
-		Command: "aws",
-		Args: []string{
-			"ecs",
-			"execute-command",
-			"--cluster",
-			e.clusterARN,
-			"--task",
-			taskARN,
-			fmt.Sprintf("--container=%s", container),
-			"--command",
-			command,
-			"--interactive",
-		},
-	})
+// ExecuteCommand runs the provided command inside a container in the ECS task
+// and returns back its output and exit code.
+func (e *ECSClientWrapper) ExecuteCommand(taskARN, container, command string) (*ecsexec.Result, error) {
+	return ecsapi.Exec(context.TODO(), e.client, e.clusterARN, taskARN, container, command)
 }
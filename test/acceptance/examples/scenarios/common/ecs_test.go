@@ -0,0 +1,54 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSClientWrapper(t *testing.T) {
+	fake := fakes.NewECS(t,
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/a", Family: "web", Service: "web"},
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/b", Family: "web", Service: "web"},
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/c", Family: "web", Service: "web"},
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/d", Family: "api", Service: "api"},
+	)
+	fake.PageSize = 2
+
+	ew, err := NewECSClient(WithECSClient(fake.Client()), WithClusterARN("arn:aws:ecs:us-east-1:123456789012:cluster/cluster"))
+	require.NoError(t, err)
+
+	arns, err := ew.ListTasksForService("web")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster/a",
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster/b",
+		"arn:aws:ecs:us-east-1:123456789012:task/cluster/c",
+	}, arns)
+
+	// A failure on the second page fails the whole listing.
+	fake.After(2, func() { fake.FailNext(1, "ServerException") })
+	_, err = ew.ListTasksForService("web")
+	var serverErr *types.ServerException
+	require.True(t, errors.As(err, &serverErr), "unexpected error %v", err)
+
+	require.NoError(t, ew.StopTask("a", "testing"))
+	out, err := ew.DescribeTasks([]string{"a"})
+	require.NoError(t, err)
+	require.Equal(t, "STOPPED", aws.ToString(out.Tasks[0].LastStatus))
+
+	arns, err = ew.ListTasksForService("web")
+	require.NoError(t, err)
+	require.Len(t, arns, 2)
+
+	require.NoError(t, ew.UpdateService("web", 5))
+	count, _ := fake.DesiredCount("web")
+	require.Equal(t, int32(5), count)
+}
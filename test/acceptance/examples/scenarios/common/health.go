@@ -0,0 +1,170 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// taskIDMetaKey is the service metadata key holding the ID
+	// of the ECS task that registered the service instance.
+	taskIDMetaKey = "task-id"
+
+	// ecsHealthSyncCheckName is the name of the checks that the
+	// consul-ecs-health-sync container keeps in sync with the
+	// health checks of the task's containers.
+	ecsHealthSyncCheckName = "consul ecs synced"
+
+	// dataplaneContainerName is the suffix of the ID of the check
+	// tracking the readiness of the consul-dataplane container.
+	dataplaneContainerName = "consul-dataplane"
+)
+
+// CheckSource is the component that registered a health check.
+type CheckSource string
+
+const (
+	CheckSourceECSHealthSync CheckSource = "ecs-health-sync"
+	CheckSourceDataplane     CheckSource = "consul-dataplane"
+	CheckSourceNode          CheckSource = "node"
+	CheckSourceOther         CheckSource = "other"
+)
+
+// ServiceHealthReport describes the health of every instance of a service.
+type ServiceHealthReport struct {
+	Service   string
+	Instances []InstanceHealth
+}
+
+// InstanceHealth is the health of a single service instance.
+type InstanceHealth struct {
+	Node      string
+	ServiceID string
+
+	// TaskID is the ID of the ECS task running the instance.
+	TaskID string
+
+	// Status is the aggregated status of the instance's checks.
+	Status string
+	Checks []CheckHealth
+}
+
+// CheckHealth is the state of a single health check of a service instance.
+type CheckHealth struct {
+	CheckID string
+	Name    string
+	Status  string
+	Output  string
+	Source  CheckSource
+}
+
+// Healthy returns true if the service has instances and all their checks pass.
+func (r *ServiceHealthReport) Healthy() bool {
+	if len(r.Instances) == 0 {
+		return false
+	}
+	for _, instance := range r.Instances {
+		if instance.Status != api.HealthPassing {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the report as a table with a row per health check.
+func (r *ServiceHealthReport) String() string {
+	if len(r.Instances) == 0 {
+		return fmt.Sprintf("service %s has no instances", r.Service)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "health of the %d instances of service %s:\n", len(r.Instances), r.Service)
+
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tSERVICE ID\tTASK ID\tCHECK\tSOURCE\tSTATUS\tOUTPUT")
+	for _, instance := range r.Instances {
+		taskID := instance.TaskID
+		if taskID == "" {
+			taskID = "-"
+		}
+
+		if len(instance.Checks) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t%s\t-\n", instance.Node, instance.ServiceID, taskID, instance.Status)
+			continue
+		}
+		for _, check := range instance.Checks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				instance.Node, instance.ServiceID, taskID, check.Name, check.Source, check.Status, formatCheckOutput(check.Output))
+		}
+	}
+	_ = w.Flush()
+
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// newServiceHealthReport builds the report from the
+// response of Consul's service health endpoint.
+func newServiceHealthReport(service string, entries []*api.ServiceEntry) *ServiceHealthReport {
+	report := &ServiceHealthReport{Service: service}
+	for _, entry := range entries {
+		instance := InstanceHealth{
+			Status: api.HealthChecks(entry.Checks).AggregatedStatus(),
+		}
+		if entry.Node != nil {
+			instance.Node = entry.Node.Node
+		}
+		if entry.Service != nil {
+			instance.ServiceID = entry.Service.ID
+			instance.TaskID = entry.Service.Meta[taskIDMetaKey]
+		}
+
+		for _, check := range entry.Checks {
+			instance.Checks = append(instance.Checks, CheckHealth{
+				CheckID: check.CheckID,
+				Name:    check.Name,
+				Status:  check.Status,
+				Output:  check.Output,
+				Source:  checkSource(check),
+			})
+		}
+		sort.Slice(instance.Checks, func(i, j int) bool {
+			return instance.Checks[i].CheckID < instance.Checks[j].CheckID
+		})
+
+		report.Instances = append(report.Instances, instance)
+	}
+
+	sort.Slice(report.Instances, func(i, j int) bool {
+		return report.Instances[i].ServiceID < report.Instances[j].ServiceID
+	})
+	return report
+}
+
+func checkSource(check *api.HealthCheck) CheckSource {
+	switch {
+	case check.ServiceID == "":
+		return CheckSourceNode
+	case check.CheckID == fmt.Sprintf("%s-%s", check.ServiceID, dataplaneContainerName):
+		return CheckSourceDataplane
+	case check.Name == ecsHealthSyncCheckName:
+		return CheckSourceECSHealthSync
+	default:
+		return CheckSourceOther
+	}
+}
+
+// formatCheckOutput keeps the output of a check on a single line of the report.
+func formatCheckOutput(output string) string {
+	output = strings.Join(strings.Fields(output), " ")
+	if output == "" {
+		return "-"
+	}
+	return output
+}
@@ -0,0 +1,137 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForHealthyService(t *testing.T) {
+	instance := func(node, id, taskID string, checks ...*api.HealthCheck) *api.ServiceEntry {
+		for _, check := range checks {
+			check.Node = node
+			if check.ServiceID == "" && check.CheckID != "serfHealth" {
+				check.ServiceID = id
+			}
+		}
+		return &api.ServiceEntry{
+			Node:    &api.Node{Node: node},
+			Service: &api.AgentService{ID: id, Service: "server", Meta: map[string]string{"task-id": taskID}},
+			Checks:  checks,
+		}
+	}
+
+	healthyEntries := []*api.ServiceEntry{
+		instance("node-a", "server-a", "task-a",
+			&api.HealthCheck{CheckID: "server-a-consul-dataplane", Name: "Consul dataplane readiness", Status: api.HealthPassing},
+			&api.HealthCheck{CheckID: "server-a-server", Name: "consul ecs synced", Status: api.HealthPassing},
+		),
+	}
+	unhealthyEntries := []*api.ServiceEntry{
+		instance("node-b", "server-b", "task-b",
+			&api.HealthCheck{CheckID: "server-b-server", Name: "consul ecs synced", Status: api.HealthCritical, Output: "ECS health status is \"UNHEALTHY\"\nfor container server"},
+			&api.HealthCheck{CheckID: "server-b-consul-dataplane", Name: "Consul dataplane readiness", Status: api.HealthPassing},
+			&api.HealthCheck{CheckID: "serfHealth", Name: "Serf Health Status", Status: api.HealthPassing},
+		),
+		healthyEntries[0],
+	}
+
+	cases := map[string]struct {
+		entries  []*api.ServiceEntry
+		expected *ServiceHealthReport
+		healthy  bool
+	}{
+		"healthy": {
+			entries: healthyEntries,
+			healthy: true,
+			expected: &ServiceHealthReport{
+				Service:   "server",
+				Instances: []InstanceHealth{healthyReportInstance()},
+			},
+		},
+		"unhealthy instance": {
+			entries: unhealthyEntries,
+			expected: &ServiceHealthReport{
+				Service: "server",
+				Instances: []InstanceHealth{
+					healthyReportInstance(),
+					{
+						Node: "node-b", ServiceID: "server-b", TaskID: "task-b", Status: api.HealthCritical,
+						Checks: []CheckHealth{
+							{CheckID: "serfHealth", Name: "Serf Health Status", Status: api.HealthPassing, Source: CheckSourceNode},
+							{CheckID: "server-b-consul-dataplane", Name: "Consul dataplane readiness", Status: api.HealthPassing, Source: CheckSourceDataplane},
+							{CheckID: "server-b-server", Name: "consul ecs synced", Status: api.HealthCritical, Output: "ECS health status is \"UNHEALTHY\"\nfor container server", Source: CheckSourceECSHealthSync},
+						},
+					},
+				},
+			},
+		},
+		"no instances": {
+			entries:  []*api.ServiceEntry{},
+			expected: &ServiceHealthReport{Service: "server"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				require.Equal(t, "/v1/health/service/server", req.URL.Path)
+				_ = json.NewEncoder(w).Encode(c.entries)
+			}))
+			t.Cleanup(server.Close)
+
+			ccw, err := SetupConsulClient(t, server.URL, WithRetryPolicy(RetryPolicy{
+				Timeout:     200 * time.Millisecond,
+				InitialWait: 50 * time.Millisecond,
+			}))
+			require.NoError(t, err)
+
+			report, err := ccw.WaitForHealthyService("server", nil)
+			require.Equal(t, c.expected, report)
+			if c.healthy {
+				require.NoError(t, err)
+				return
+			}
+
+			var timeoutErr *WaitTimeoutError
+			require.ErrorAs(t, err, &timeoutErr)
+			require.Equal(t, c.expected, timeoutErr.LastState)
+		})
+	}
+}
+
+func healthyReportInstance() InstanceHealth {
+	return InstanceHealth{
+		Node: "node-a", ServiceID: "server-a", TaskID: "task-a", Status: api.HealthPassing,
+		Checks: []CheckHealth{
+			{CheckID: "server-a-consul-dataplane", Name: "Consul dataplane readiness", Status: api.HealthPassing, Source: CheckSourceDataplane},
+			{CheckID: "server-a-server", Name: "consul ecs synced", Status: api.HealthPassing, Source: CheckSourceECSHealthSync},
+		},
+	}
+}
+
+func TestServiceHealthReportString(t *testing.T) {
+	report := &ServiceHealthReport{
+		Service: "server",
+		Instances: []InstanceHealth{{
+			Node: "node-b", ServiceID: "server-b", Status: api.HealthCritical,
+			Checks: []CheckHealth{
+				{Name: "consul ecs synced", Status: api.HealthCritical, Output: "ECS health status is \"UNHEALTHY\"\nfor container server", Source: CheckSourceECSHealthSync},
+			},
+		}},
+	}
+
+	expected := `health of the 1 instances of service server:
+NODE    SERVICE ID  TASK ID  CHECK              SOURCE           STATUS    OUTPUT
+node-b  server-b    -        consul ecs synced  ecs-health-sync  critical  ECS health status is "UNHEALTHY" for container server`
+	require.Equal(t, expected, report.String())
+	require.Equal(t, "service server has no instances", (&ServiceHealthReport{Service: "server"}).String())
+}
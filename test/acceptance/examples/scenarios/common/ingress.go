@@ -0,0 +1,300 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ingressEnvVar overrides the ingress of every scenario with a
+	// comma separated list of IP addresses or CIDR blocks.
+	ingressEnvVar = "TEST_LB_INGRESS"
+
+	defaultEchoTimeout = 10 * time.Second
+)
+
+// IPFamily selects the IP version of the ingress addresses.
+type IPFamily int
+
+const (
+	IPv4 IPFamily = iota
+	IPv6
+)
+
+func (f IPFamily) String() string {
+	if f == IPv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func (f IPFamily) matches(addr netip.Addr) bool {
+	if f == IPv6 {
+		return addr.Is6() && !addr.Is4In6()
+	}
+	return addr.Is4() || addr.Is4In6()
+}
+
+// ErrNoIngress is returned by providers that have no ingress to offer,
+// e.g. because they are not configured. ChainIngress moves on to the next
+// provider when it gets this error.
+var ErrNoIngress = errors.New("no ingress available")
+
+// IngressProvider discovers the CIDR blocks that the load balancers
+// deployed by the scenarios must accept traffic from, which usually
+// is the public address of the host running the tests.
+type IngressProvider interface {
+	// Ingress returns the CIDR blocks of the given IP family.
+	Ingress(family IPFamily) ([]netip.Prefix, error)
+}
+
+// EnvIngress reads the ingress from a comma separated list of IP addresses
+// or CIDR blocks held by an environment variable. It returns ErrNoIngress
+// if the variable is not set.
+type EnvIngress struct {
+	Name string
+}
+
+func (e EnvIngress) Ingress(family IPFamily) ([]netip.Prefix, error) {
+	value := os.Getenv(e.Name)
+	if value == "" {
+		return nil, ErrNoIngress
+	}
+
+	cidrs, err := NewCIDRListIngress(strings.Split(value, ",")...)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", e.Name, err)
+	}
+	return cidrs.Ingress(family)
+}
+
+// CIDRListIngress is a fixed list of CIDR blocks.
+type CIDRListIngress []netip.Prefix
+
+// NewCIDRListIngress parses a list of IP addresses or CIDR blocks. IP
+// addresses are converted to CIDR blocks holding that single address.
+func NewCIDRListIngress(cidrs ...string) (CIDRListIngress, error) {
+	var list CIDRListIngress
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		prefix, err := parseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, prefix)
+	}
+	return list, nil
+}
+
+// Ingress returns the CIDR blocks of the list that belong to the
+// given IP family or ErrNoIngress if there are none.
+func (l CIDRListIngress) Ingress(family IPFamily) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, prefix := range l {
+		if family.matches(prefix.Addr()) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no %s CIDR block in %v: %w", family, l, ErrNoIngress)
+	}
+	return prefixes, nil
+}
+
+// StaticIngress always returns the same CIDR blocks and error regardless
+// of the IP family. It is meant for offline unit tests.
+type StaticIngress struct {
+	Prefixes []netip.Prefix
+	Err      error
+}
+
+func (s StaticIngress) Ingress(IPFamily) ([]netip.Prefix, error) {
+	return s.Prefixes, s.Err
+}
+
+// EchoIngress discovers the public address of the host running the tests
+// by calling services that echo the address of the caller. The services
+// are tried in order until one of them answers with an address of the
+// requested IP family. The connections are made over the requested IP
+// family so that a dual stack host reports the right address.
+type EchoIngress struct {
+	// IPv4Services and IPv6Services are the URLs of the
+	// echo services used for each IP family.
+	IPv4Services []string
+	IPv6Services []string
+
+	// Timeout is the timeout of every request.
+	Timeout time.Duration
+}
+
+// NewEchoIngress returns an EchoIngress using well known public echo services.
+func NewEchoIngress() *EchoIngress {
+	return &EchoIngress{
+		IPv4Services: []string{
+			"https://api.ipify.org?format=text",
+			"https://checkip.amazonaws.com",
+			"https://ipv4.icanhazip.com",
+		},
+		IPv6Services: []string{
+			"https://api6.ipify.org?format=text",
+			"https://ipv6.icanhazip.com",
+		},
+		Timeout: defaultEchoTimeout,
+	}
+}
+
+func (e *EchoIngress) Ingress(family IPFamily) ([]netip.Prefix, error) {
+	services, network := e.IPv4Services, "tcp4"
+	if family == IPv6 {
+		services, network = e.IPv6Services, "tcp6"
+	}
+
+	timeout := e.Timeout
+	if timeout == 0 {
+		timeout = defaultEchoTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	var errs []error
+	for _, url := range services {
+		addr, err := echoAddr(client, url)
+		if err == nil && !family.matches(addr) {
+			err = fmt.Errorf("%s is not an %s address", addr, family)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, err))
+			continue
+		}
+		return []netip.Prefix{netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())}, nil
+	}
+
+	return nil, fmt.Errorf("discovering the public %s address: %w", family, errors.Join(errs...))
+}
+
+func echoAddr(client *http.Client, url string) (netip.Addr, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.ParseAddr(strings.TrimSpace(string(body)))
+}
+
+// ChainIngress tries each provider in order and returns the ingress of the
+// first one that does not return ErrNoIngress.
+type ChainIngress []IngressProvider
+
+func (c ChainIngress) Ingress(family IPFamily) ([]netip.Prefix, error) {
+	for _, provider := range c {
+		prefixes, err := provider.Ingress(family)
+		if errors.Is(err, ErrNoIngress) {
+			continue
+		}
+		return prefixes, err
+	}
+	return nil, ErrNoIngress
+}
+
+// DefaultIngressProvider returns the provider used by the scenarios unless
+// overridden with SetIngressProvider. The TEST_LB_INGRESS environment
+// variable takes precedence over the public echo services.
+func DefaultIngressProvider() IngressProvider {
+	return ChainIngress{
+		EnvIngress{Name: ingressEnvVar},
+		NewEchoIngress(),
+	}
+}
+
+var (
+	ingressMu       sync.Mutex
+	ingressProvider = DefaultIngressProvider()
+)
+
+// SetIngressProvider replaces the provider that the scenarios get their
+// ingress from, e.g. with a StaticIngress in unit tests.
+func SetIngressProvider(provider IngressProvider) {
+	ingressMu.Lock()
+	defer ingressMu.Unlock()
+	ingressProvider = provider
+}
+
+// Ingress returns the CIDR blocks of the given IP family
+// from the provider currently used by the scenarios.
+func Ingress(family IPFamily) ([]netip.Prefix, error) {
+	ingressMu.Lock()
+	provider := ingressProvider
+	ingressMu.Unlock()
+
+	return provider.Ingress(family)
+}
+
+// IngressIP returns the single IP address of the given family that the
+// scenarios' load balancers must accept traffic from. It is meant for the
+// `lb_ingress_ip` input variable of the examples, which only accepts a
+// single address.
+func IngressIP(family IPFamily) (string, error) {
+	prefixes, err := Ingress(family)
+	if err != nil {
+		return "", err
+	}
+
+	if len(prefixes) != 1 || !prefixes[0].IsSingleIP() {
+		return "", fmt.Errorf("expected a single %s address for the load balancer ingress, got %v", family, prefixes)
+	}
+	return prefixes[0].Addr().String(), nil
+}
+
+func parseCIDR(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
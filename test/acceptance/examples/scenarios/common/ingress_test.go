@@ -0,0 +1,142 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngressProviders(t *testing.T) {
+	echoServer := func(code int, body string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+
+	cidrs, err := NewCIDRListIngress("203.0.113.10", " 198.51.100.0/24", "2001:db8::/32", "")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		env      string
+		provider IngressProvider
+		family   IPFamily
+		expected []string
+		err      error
+	}{
+		"env override": {
+			env:      "203.0.113.10, 2001:db8::1",
+			provider: EnvIngress{Name: ingressEnvVar},
+			family:   IPv4,
+			expected: []string{"203.0.113.10/32"},
+		},
+		"env override IPv6": {
+			env:      "203.0.113.10, 2001:db8::1",
+			provider: EnvIngress{Name: ingressEnvVar},
+			family:   IPv6,
+			expected: []string{"2001:db8::1/128"},
+		},
+		"env not set": {
+			provider: EnvIngress{Name: ingressEnvVar},
+			family:   IPv4,
+			err:      ErrNoIngress,
+		},
+		"CIDR list IPv4": {
+			provider: cidrs,
+			family:   IPv4,
+			expected: []string{"203.0.113.10/32", "198.51.100.0/24"},
+		},
+		"CIDR list IPv6": {
+			provider: cidrs,
+			family:   IPv6,
+			expected: []string{"2001:db8::/32"},
+		},
+		"CIDR list without family": {
+			provider: CIDRListIngress{netip.MustParsePrefix("2001:db8::/32")},
+			family:   IPv4,
+			err:      ErrNoIngress,
+		},
+		"echo falls back to the next service": {
+			provider: &EchoIngress{
+				IPv4Services: []string{
+					echoServer(http.StatusServiceUnavailable, ""),
+					echoServer(http.StatusOK, "2001:db8::1"),
+					echoServer(http.StatusOK, "not an address"),
+					echoServer(http.StatusOK, "203.0.113.10\n"),
+				},
+				Timeout: time.Second,
+			},
+			family:   IPv4,
+			expected: []string{"203.0.113.10/32"},
+		},
+		"chain skips providers without ingress": {
+			provider: ChainIngress{
+				EnvIngress{Name: ingressEnvVar},
+				StaticIngress{Err: ErrNoIngress},
+				StaticIngress{Prefixes: []netip.Prefix{netip.MustParsePrefix("203.0.113.10/32")}},
+				StaticIngress{Err: errors.New("unreachable")},
+			},
+			family:   IPv4,
+			expected: []string{"203.0.113.10/32"},
+		},
+		"chain without ingress": {
+			provider: ChainIngress{EnvIngress{Name: ingressEnvVar}},
+			family:   IPv4,
+			err:      ErrNoIngress,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(ingressEnvVar, c.env)
+
+			prefixes, err := c.provider.Ingress(c.family)
+			if c.err != nil {
+				require.ErrorIs(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var actual []string
+			for _, prefix := range prefixes {
+				actual = append(actual, prefix.String())
+			}
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestEchoIngressFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := (&EchoIngress{IPv4Services: []string{server.URL}, Timeout: time.Second}).Ingress(IPv4)
+	require.ErrorContains(t, err, "unexpected status code 500")
+}
+
+func TestIngressIP(t *testing.T) {
+	t.Cleanup(func() {
+		SetIngressProvider(DefaultIngressProvider())
+	})
+
+	SetIngressProvider(StaticIngress{Prefixes: []netip.Prefix{netip.MustParsePrefix("203.0.113.10/32")}})
+	ip, err := IngressIP(IPv4)
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.10", ip)
+
+	SetIngressProvider(StaticIngress{Prefixes: []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")}})
+	_, err = IngressIP(IPv4)
+	require.ErrorContains(t, err, "expected a single IPv4 address")
+}
@@ -0,0 +1,56 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+)
+
+// rbacDeniedOutput is the response of Envoy to a request
+// that the L7 permissions of an intention deny.
+const rbacDeniedOutput = "RBAC: access denied"
+
+// HTTPIntentionCheck is a request that a source service makes
+// to an upstream and whether its intentions allow the request.
+type HTTPIntentionCheck struct {
+	Path    string
+	Allowed bool
+}
+
+// ValidateHTTPIntentions makes the requests of the checks to the upstream
+// address, e.g. localhost:1234, of the source service by running curl with
+// exec in the source task. It waits until the fake-service upstream answers
+// the allowed requests and Envoy denies the other ones. Both answer with a
+// response, so curl must exit successfully either way.
+func ValidateHTTPIntentions(t *testing.T, exec func(command string) (*ecsexec.Result, error), upstreamAddr string, checks ...HTTPIntentionCheck) {
+	retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, t, func(r *retry.R) {
+		for _, check := range checks {
+			url := strings.TrimSuffix(upstreamAddr, "/") + "/" + strings.TrimPrefix(check.Path, "/")
+			res, err := exec(fmt.Sprintf(`/bin/sh -c "curl -sS %s"`, url))
+			if err != nil {
+				r.Fatalf("failed to call %s: %s", url, err)
+			}
+			out := res.Stdout + res.Stderr
+			if res.ExitCode != 0 {
+				r.Fatalf("failed to call %s, curl exited with %d: %q", url, res.ExitCode, out)
+			}
+
+			expected := rbacDeniedOutput
+			if check.Allowed {
+				expected = `"code": 200`
+			}
+			if !strings.Contains(out, expected) {
+				r.Fatalf("expected %q in the response of %s but got %q", expected, url, out)
+			}
+		}
+	})
+	logger.Log(t, fmt.Sprintf("intentions allowed and denied the expected %d requests", len(checks)))
+}
@@ -0,0 +1,30 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateHTTPIntentions(t *testing.T) {
+	responses := map[string]*ecsexec.Result{
+		`/bin/sh -c "curl -sS localhost:1234/allowed"`: {Stdout: `{"name": "server", "code": 200}`},
+		`/bin/sh -c "curl -sS localhost:1234/denied"`:  {Stdout: "RBAC: access denied"},
+	}
+
+	var commands []string
+	exec := func(command string) (*ecsexec.Result, error) {
+		commands = append(commands, command)
+		return responses[command], nil
+	}
+
+	ValidateHTTPIntentions(t, exec, "localhost:1234",
+		HTTPIntentionCheck{Path: "/allowed", Allowed: true},
+		HTTPIntentionCheck{Path: "denied"},
+	)
+	require.Len(t, commands, 2)
+}
@@ -0,0 +1,269 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// PeeringConfig names the two sides of a cluster peering.
+type PeeringConfig struct {
+	// AcceptorPeerName is the name that the acceptor cluster gives to
+	// the peering with the dialer cluster, and DialerPeerName the name
+	// that the dialer cluster gives to the peering with the acceptor.
+	AcceptorPeerName string
+	DialerPeerName   string
+
+	// AcceptorPartition and DialerPartition are the partitions that
+	// are peered. They default to the default partition.
+	AcceptorPartition string
+	DialerPartition   string
+}
+
+// Peering is a cluster peering between the Consul clusters of two clients.
+type Peering struct {
+	acceptor peer
+	dialer   peer
+	deleted  bool
+}
+
+// peer is one side of a peering.
+type peer struct {
+	ccw *ConsulClientWrapper
+
+	// name is the name of the peering in the cluster of ccw.
+	name      string
+	partition string
+}
+
+func (p peer) queryOpts() *api.QueryOptions {
+	return &api.QueryOptions{Partition: p.partition}
+}
+
+func (p peer) writeOpts() *api.WriteOptions {
+	return &api.WriteOptions{Partition: p.partition}
+}
+
+// EstablishPeering generates a peering token in the acceptor cluster,
+// establishes the peering from the dialer cluster with it and waits for
+// both sides to be active. The peering is deleted from both clusters when
+// the test completes.
+func EstablishPeering(acceptor, dialer *ConsulClientWrapper, cfg PeeringConfig) *Peering {
+	p := &Peering{
+		acceptor: peer{ccw: acceptor, name: cfg.AcceptorPeerName, partition: cfg.AcceptorPartition},
+		dialer:   peer{ccw: dialer, name: cfg.DialerPeerName, partition: cfg.DialerPartition},
+	}
+	t := acceptor.t
+
+	logger.Log(t, fmt.Sprintf("generating peering token for peer %s", p.acceptor.name))
+	var token string
+	err := acceptor.waitFor(fmt.Sprintf("peering token for peer %s to be generated", p.acceptor.name), nil, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		resp, _, err := acceptor.client.Peerings().GenerateToken(opts.Context(), api.PeeringGenerateTokenRequest{
+			PeerName:  p.acceptor.name,
+			Partition: p.acceptor.partition,
+		}, p.acceptor.writeOpts())
+		if err != nil {
+			return false, nil, nil, err
+		}
+		token = resp.PeeringToken
+		return true, nil, nil, nil
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if !p.deleted {
+			p.cleanup()
+		}
+	})
+
+	logger.Log(t, fmt.Sprintf("establishing peering for peer %s", p.dialer.name))
+	err = dialer.waitFor(fmt.Sprintf("peering for peer %s to be established", p.dialer.name), nil, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		_, _, err := dialer.client.Peerings().Establish(opts.Context(), api.PeeringEstablishRequest{
+			PeerName:     p.dialer.name,
+			PeeringToken: token,
+			Partition:    p.dialer.partition,
+		}, p.dialer.writeOpts())
+		return err == nil, nil, nil, err
+	})
+	require.NoError(t, err)
+
+	p.WaitForState(api.PeeringStateActive)
+	return p
+}
+
+// WaitForState waits for both sides of the peering to reach the state.
+func (p *Peering) WaitForState(state api.PeeringState) {
+	for _, side := range []peer{p.acceptor, p.dialer} {
+		logger.Log(side.ccw.t, fmt.Sprintf("waiting for peering %s to be %s", side.name, state))
+		require.NoError(side.ccw.t, side.waitForPeering(func(peering *api.Peering) bool {
+			return peering != nil && peering.State == state
+		}, string(state)))
+	}
+}
+
+func (p peer) waitForPeering(done func(*api.Peering) bool, desc string) error {
+	return p.ccw.waitFor(fmt.Sprintf("peering %s to be %s", p.name, desc), p.queryOpts(), func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		peering, meta, err := p.ccw.client.Peerings().Read(opts.Context(), p.name, opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		return done(peering), describePeering(p.name, peering), meta, nil
+	})
+}
+
+// ExportServices exports the services of the namespace in the cluster of
+// the exporter, which is either side of the peering, to the other side.
+// It keeps the services that the partition already exports and restores
+// the previous exported-services config entry when the test completes.
+// It then waits for the services to be imported by the other side.
+func (p *Peering) ExportServices(exporter *ConsulClientWrapper, namespace string, services ...string) {
+	local, remote := p.sides(exporter)
+
+	partition := local.partition
+	if partition == "" {
+		partition = "default"
+	}
+
+	entry := &api.ExportedServicesConfigEntry{Name: partition, Partition: local.partition}
+	if existing := exporter.ReadConfigEntry(api.ExportedServices, partition, local.queryOpts()); existing != nil {
+		entry.Services = existing.(*api.ExportedServicesConfigEntry).Services
+	}
+	entry.Services = addPeerConsumer(entry.Services, namespace, local.name, services)
+
+	exporter.WriteConfigEntry(entry)
+	p.WaitForImportedServices(remote.ccw, namespace, services...)
+}
+
+// addPeerConsumer adds the peer to the consumers of the services,
+// exporting the services that are not yet exported.
+func addPeerConsumer(exported []api.ExportedService, namespace, peerName string, services []string) []api.ExportedService {
+	result := append([]api.ExportedService(nil), exported...)
+	for _, name := range services {
+		i := 0
+		for ; i < len(result); i++ {
+			if result[i].Name == name && result[i].Namespace == namespace {
+				break
+			}
+		}
+		if i == len(result) {
+			result = append(result, api.ExportedService{Name: name, Namespace: namespace})
+		}
+
+		consumers := append([]api.ServiceConsumer(nil), result[i].Consumers...)
+		found := false
+		for _, consumer := range consumers {
+			if consumer.Peer == peerName {
+				found = true
+			}
+		}
+		if !found {
+			consumers = append(consumers, api.ServiceConsumer{Peer: peerName})
+		}
+		result[i].Consumers = consumers
+	}
+	return result
+}
+
+// WaitForImportedServices waits for the services to appear in the catalog
+// of the importer, which is either side of the peering, as services
+// imported from the other side.
+func (p *Peering) WaitForImportedServices(importer *ConsulClientWrapper, namespace string, services ...string) {
+	local, _ := p.sides(importer)
+	logger.Log(importer.t, fmt.Sprintf("waiting for services %v to be imported from peer %s", services, local.name))
+	require.NoError(importer.t, local.waitForImports(namespace, fmt.Sprintf("services %v to be imported", services), func(imported map[string][]string) bool {
+		for _, name := range services {
+			if _, ok := imported[name]; !ok {
+				return false
+			}
+		}
+		return true
+	}))
+}
+
+func (p peer) waitForImports(namespace, what string, done func(map[string][]string) bool) error {
+	queryOpts := p.queryOpts()
+	queryOpts.Peer = p.name
+	queryOpts.Namespace = namespace
+	return p.ccw.waitFor(fmt.Sprintf("%s from peer %s", what, p.name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		services, meta, err := p.ccw.client.Catalog().Services(opts)
+		if err != nil {
+			return false, nil, nil, err
+		}
+		return done(services), describeServices(services), meta, nil
+	})
+}
+
+// Delete deletes the peering from the acceptor cluster and verifies the
+// fallout: the acceptor removes the peering, the dialer reports it as
+// terminated and neither side has services imported through the peering
+// left in its default namespace.
+func (p *Peering) Delete() {
+	t := p.acceptor.ccw.t
+	logger.Log(t, fmt.Sprintf("deleting peering %s", p.acceptor.name))
+	require.NoError(t, p.acceptor.delete())
+	p.deleted = true
+
+	require.NoError(t, p.acceptor.waitForPeering(func(peering *api.Peering) bool {
+		return peering == nil
+	}, "deleted"))
+	require.NoError(t, p.dialer.waitForPeering(func(peering *api.Peering) bool {
+		return peering != nil && peering.State == api.PeeringStateTerminated
+	}, string(api.PeeringStateTerminated)))
+
+	for _, side := range []peer{p.acceptor, p.dialer} {
+		require.NoError(t, side.waitForImports("", "imported services to be removed", func(imported map[string][]string) bool {
+			return len(imported) == 0
+		}))
+	}
+
+	p.dialer.ccw.t.Cleanup(func() {
+		if err := p.dialer.delete(); err != nil {
+			logger.Log(p.dialer.ccw.t, fmt.Sprintf("failed to delete terminated peering %s: %s", p.dialer.name, err))
+		}
+	})
+}
+
+func (p peer) delete() error {
+	return p.ccw.waitFor(fmt.Sprintf("peering %s to be deleted", p.name), nil, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		_, err := p.ccw.client.Peerings().Delete(opts.Context(), p.name, p.writeOpts())
+		return err == nil, nil, nil, err
+	})
+}
+
+// cleanup deletes the peering from both clusters.
+func (p *Peering) cleanup() {
+	for _, side := range []peer{p.acceptor, p.dialer} {
+		logger.Log(side.ccw.t, fmt.Sprintf("cleaning up peering %s", side.name))
+		if err := side.delete(); err != nil {
+			logger.Log(side.ccw.t, fmt.Sprintf("failed to delete peering %s: %s", side.name, err))
+		}
+	}
+}
+
+// sides returns the side of the peering of the client and the other side.
+func (p *Peering) sides(ccw *ConsulClientWrapper) (local, remote peer) {
+	switch ccw {
+	case p.acceptor.ccw:
+		return p.acceptor, p.dialer
+	case p.dialer.ccw:
+		return p.dialer, p.acceptor
+	default:
+		require.FailNow(ccw.t, "the Consul client is not a side of the peering")
+		return peer{}, peer{}
+	}
+}
+
+func describePeering(name string, peering *api.Peering) stateString {
+	if peering == nil {
+		return stateString(fmt.Sprintf("peering %s does not exist", name))
+	}
+	imported := append([]string(nil), peering.StreamStatus.ImportedServices...)
+	sort.Strings(imported)
+	return stateString(fmt.Sprintf("peering %s is %s, imported services %v", name, peering.State, imported))
+}
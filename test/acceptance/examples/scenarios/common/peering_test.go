@@ -0,0 +1,224 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePeeringCluster serves the peering, config entry and catalog
+// endpoints of a Consul cluster. Services exported by the cluster
+// linked through a peering show up in its catalog.
+type fakePeeringCluster struct {
+	entries *fakes.ConfigEntries
+
+	mu       sync.Mutex
+	peerings map[string]*api.Peering
+	// links maps the name of a local peering to the cluster
+	// and name of the peering on the other side.
+	links map[string]fakePeerLink
+}
+
+type fakePeerLink struct {
+	cluster *fakePeeringCluster
+	name    string
+}
+
+func newFakePeeringCluster(t *testing.T) (*fakePeeringCluster, string) {
+	c := &fakePeeringCluster{
+		entries:  fakes.NewConfigEntries(),
+		peerings: make(map[string]*api.Peering),
+		links:    make(map[string]fakePeerLink),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/config", c.entries.ServeHTTP)
+	mux.HandleFunc("/v1/config/", c.entries.ServeHTTP)
+	mux.HandleFunc("/v1/peering/", c.servePeering)
+	mux.HandleFunc("/v1/catalog/services", c.serveServices)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return c, server.URL
+}
+
+// newTestPeeringClient returns a client whose cleanups run
+// when t completes, so that tests can check what they restored.
+func newTestPeeringClient(t *testing.T, addr string) *ConsulClientWrapper {
+	ccw, err := SetupConsulClient(t, addr, WithRetryPolicy(RetryPolicy{
+		Timeout:     5 * time.Second,
+		InitialWait: 10 * time.Millisecond,
+	}))
+	require.NoError(t, err)
+	return ccw
+}
+
+// tokens maps peering tokens to the cluster that generated them.
+var (
+	tokensMu sync.Mutex
+	tokens   = map[string]fakePeerLink{}
+)
+
+func (c *fakePeeringCluster) servePeering(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", "1")
+	switch path := strings.TrimPrefix(req.URL.Path, "/v1/peering/"); {
+	case path == "token":
+		var body api.PeeringGenerateTokenRequest
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		c.peerings[body.PeerName] = &api.Peering{Name: body.PeerName, State: api.PeeringStatePending}
+
+		token := "token-" + body.PeerName
+		tokensMu.Lock()
+		tokens[token] = fakePeerLink{cluster: c, name: body.PeerName}
+		tokensMu.Unlock()
+		_ = json.NewEncoder(w).Encode(api.PeeringGenerateTokenResponse{PeeringToken: token})
+	case path == "establish":
+		var body api.PeeringEstablishRequest
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		tokensMu.Lock()
+		acceptor, ok := tokens[body.PeeringToken]
+		tokensMu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.peerings[body.PeerName] = &api.Peering{Name: body.PeerName, State: api.PeeringStateActive}
+		c.links[body.PeerName] = acceptor
+
+		acceptor.cluster.mu.Lock()
+		acceptor.cluster.peerings[acceptor.name].State = api.PeeringStateActive
+		acceptor.cluster.links[acceptor.name] = fakePeerLink{cluster: c, name: body.PeerName}
+		acceptor.cluster.mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	case req.Method == http.MethodDelete:
+		if link, ok := c.links[path]; ok {
+			link.cluster.mu.Lock()
+			if remote, ok := link.cluster.peerings[link.name]; ok {
+				remote.State = api.PeeringStateTerminated
+			}
+			link.cluster.mu.Unlock()
+		}
+		delete(c.peerings, path)
+		delete(c.links, path)
+	default:
+		peering, ok := c.peerings[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(peering)
+	}
+}
+
+// serveServices returns the services that the peer of the
+// query exports to this cluster through an active peering.
+func (c *fakePeeringCluster) serveServices(w http.ResponseWriter, req *http.Request) {
+	c.mu.Lock()
+	peerName := req.URL.Query().Get("peer")
+	link, linked := c.links[peerName]
+	peering := c.peerings[peerName]
+	c.mu.Unlock()
+
+	w.Header().Set("X-Consul-Index", "1")
+	services := map[string][]string{}
+	if linked && peering != nil && peering.State == api.PeeringStateActive {
+		entry := link.cluster.entries.Get(api.ExportedServices, "default")
+		exported, _ := entry["Services"].([]any)
+		for _, s := range exported {
+			service := s.(map[string]any)
+			consumers, _ := service["Consumers"].([]any)
+			for _, consumer := range consumers {
+				if consumer.(map[string]any)["Peer"] == link.name {
+					services[service["Name"].(string)] = nil
+				}
+			}
+		}
+	}
+	_ = json.NewEncoder(w).Encode(services)
+}
+
+func (c *fakePeeringCluster) peering(name string) *api.Peering {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.peerings[name]
+}
+
+func TestPeeringLifecycle(t *testing.T) {
+	dc1, dc1Addr := newFakePeeringCluster(t)
+	dc2, dc2Addr := newFakePeeringCluster(t)
+
+	// An exported service that must survive the export of the test.
+	_, _, err := newTestPeeringClient(t, dc2Addr).client.ConfigEntries().Set(&api.ExportedServicesConfigEntry{
+		Name:     "default",
+		Services: []api.ExportedService{{Name: "mesh-gateway", Consumers: []api.ServiceConsumer{{Partition: "part1"}}}},
+	}, nil)
+	require.NoError(t, err)
+
+	t.Run("lifecycle", func(t *testing.T) {
+		ccw1 := newTestPeeringClient(t, dc1Addr)
+		ccw2 := newTestPeeringClient(t, dc2Addr)
+
+		peering := EstablishPeering(ccw1, ccw2, PeeringConfig{AcceptorPeerName: "dc2", DialerPeerName: "dc1"})
+		require.Equal(t, api.PeeringStateActive, dc1.peering("dc2").State)
+		require.Equal(t, api.PeeringStateActive, dc2.peering("dc1").State)
+
+		peering.ExportServices(ccw2, "", "server")
+		entry := ccw2.ReadConfigEntry(api.ExportedServices, "default", nil).(*api.ExportedServicesConfigEntry)
+		require.Equal(t, []api.ExportedService{
+			{Name: "mesh-gateway", Consumers: []api.ServiceConsumer{{Partition: "part1"}}},
+			{Name: "server", Consumers: []api.ServiceConsumer{{Peer: "dc1"}}},
+		}, entry.Services)
+
+		peering.Delete()
+		require.Nil(t, dc1.peering("dc2"))
+		require.Equal(t, api.PeeringStateTerminated, dc2.peering("dc1").State)
+	})
+
+	require.Nil(t, dc2.peering("dc1"))
+	entry := dc2.entries.Get(api.ExportedServices, "default")
+	require.Len(t, entry["Services"], 1)
+}
+
+func TestPeeringCleanup(t *testing.T) {
+	dc1, dc1Addr := newFakePeeringCluster(t)
+	dc2, dc2Addr := newFakePeeringCluster(t)
+
+	t.Run("establish", func(t *testing.T) {
+		EstablishPeering(newTestPeeringClient(t, dc1Addr), newTestPeeringClient(t, dc2Addr),
+			PeeringConfig{AcceptorPeerName: "dc2", DialerPeerName: "dc1"})
+	})
+
+	require.Nil(t, dc1.peering("dc2"))
+	require.Nil(t, dc2.peering("dc1"))
+}
+
+func TestAddPeerConsumer(t *testing.T) {
+	exported := []api.ExportedService{
+		{Name: "server", Namespace: "ns1", Consumers: []api.ServiceConsumer{{Peer: "dc1"}}},
+	}
+
+	result := addPeerConsumer(exported, "ns1", "dc1", []string{"server", "api"})
+	require.Equal(t, []api.ExportedService{
+		{Name: "server", Namespace: "ns1", Consumers: []api.ServiceConsumer{{Peer: "dc1"}}},
+		{Name: "api", Namespace: "ns1", Consumers: []api.ServiceConsumer{{Peer: "dc1"}}},
+	}, result)
+
+	result = addPeerConsumer(exported, "ns1", "dc3", []string{"server"})
+	require.Equal(t, []api.ServiceConsumer{{Peer: "dc1"}, {Peer: "dc3"}}, result[0].Consumers)
+	require.Len(t, exported[0].Consumers, 1)
+}
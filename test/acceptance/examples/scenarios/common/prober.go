@@ -0,0 +1,170 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeInterval = 2 * time.Second
+	defaultProbeTimeout  = 5 * time.Second
+)
+
+// ProbeFailure is a single failed request made by a Prober.
+type ProbeFailure struct {
+	Time time.Time
+	Err  error
+}
+
+// ProbeReport summarizes the requests made by a Prober.
+type ProbeReport struct {
+	Total    int
+	Failures []ProbeFailure
+}
+
+// String renders the report along with the time and
+// the error of every failed request.
+func (r ProbeReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d out of %d probes failed", len(r.Failures), r.Total)
+	for _, f := range r.Failures {
+		fmt.Fprintf(&sb, "\n  - %s: %s", f.Time.Format(time.RFC3339), f.Err)
+	}
+	return sb.String()
+}
+
+// Prober continuously calls the client app's load balancer in the
+// background and records every request that did not reach the
+// expected upstream. It is used to verify that traffic through
+// the mesh never drops while the deployment changes.
+type Prober struct {
+	url              string
+	expectedUpstream string
+	interval         time.Duration
+	client           *http.Client
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+
+	mu     sync.Mutex
+	report ProbeReport
+}
+
+type ProberOpts func(*Prober)
+
+// WithProbeInterval sets the time to wait between two probes.
+func WithProbeInterval(interval time.Duration) ProberOpts {
+	return func(p *Prober) {
+		p.interval = interval
+	}
+}
+
+// WithProbeTimeout sets the timeout of every probe.
+func WithProbeTimeout(timeout time.Duration) ProberOpts {
+	return func(p *Prober) {
+		p.client.Timeout = timeout
+	}
+}
+
+// StartProber starts probing the fake service reachable at lbURL in the
+// background. Every probe expects the request to be forwarded to the
+// expectedUpstream app. The Prober must be stopped by calling Stop.
+func StartProber(lbURL, expectedUpstream string, opts ...ProberOpts) *Prober {
+	p := &Prober{
+		url:              lbURL,
+		expectedUpstream: expectedUpstream,
+		interval:         defaultProbeInterval,
+		client:           &http.Client{Timeout: defaultProbeTimeout},
+		stopCh:           make(chan struct{}),
+		doneCh:           make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	go p.run()
+	return p
+}
+
+// Stop stops the Prober and returns the report of all the probes it made.
+func (p *Prober) Stop() ProbeReport {
+	close(p.stopCh)
+	<-p.doneCh
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.report
+}
+
+func (p *Prober) run() {
+	defer close(p.doneCh)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.record(p.probe())
+
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Prober) record(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.report.Total++
+	if err != nil {
+		p.report.Failures = append(p.report.Failures, ProbeFailure{Time: time.Now(), Err: err})
+	}
+}
+
+func (p *Prober) probe() error {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var fakeSvcResp FakeServiceResponse
+	if err := json.Unmarshal(body, &fakeSvcResp); err != nil {
+		return fmt.Errorf("unmarshalling json %w", err)
+	}
+
+	upstreamResp, ok := fakeSvcResp.UpstreamCalls["http://localhost:1234"]
+	if !ok {
+		return fmt.Errorf("response does not contain an upstream call")
+	}
+	if upstreamResp.Code != http.StatusOK {
+		return fmt.Errorf("upstream call failed with code %d", upstreamResp.Code)
+	}
+	if upstreamResp.Name != p.expectedUpstream {
+		return fmt.Errorf("expected upstream %s but got %s", p.expectedUpstream, upstreamResp.Name)
+	}
+
+	return nil
+}
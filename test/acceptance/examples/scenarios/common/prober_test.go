@@ -0,0 +1,88 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProber(t *testing.T) {
+	const (
+		successResp = `{"body": "Hello World", "code": 200, "upstream_calls": {"http://localhost:1234": {"name": "server-app", "body": "Hello World", "code": 200}}}`
+		failureResp = `{"body": "Hello World", "code": 500, "upstream_calls": {"http://localhost:1234": {"code": -1}}}`
+	)
+
+	cases := map[string]struct {
+		expectedUpstream string
+		// respond returns the status code and the body of the n-th request.
+		respond      func(n int64) (int, string)
+		wantFailures bool
+	}{
+		"traffic never drops": {
+			expectedUpstream: "server-app",
+			respond: func(int64) (int, string) {
+				return http.StatusOK, successResp
+			},
+		},
+		"upstream call fails": {
+			expectedUpstream: "server-app",
+			respond: func(n int64) (int, string) {
+				if n == 2 {
+					return http.StatusOK, failureResp
+				}
+				return http.StatusOK, successResp
+			},
+			wantFailures: true,
+		},
+		"load balancer error": {
+			expectedUpstream: "server-app",
+			respond: func(n int64) (int, string) {
+				if n == 2 {
+					return http.StatusBadGateway, ""
+				}
+				return http.StatusOK, successResp
+			},
+			wantFailures: true,
+		},
+		"unexpected upstream": {
+			expectedUpstream: "other-app",
+			respond: func(int64) (int, string) {
+				return http.StatusOK, successResp
+			},
+			wantFailures: true,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				code, body := c.respond(requests.Add(1))
+				w.WriteHeader(code)
+				_, _ = w.Write([]byte(body))
+			}))
+			t.Cleanup(server.Close)
+
+			prober := StartProber(server.URL, c.expectedUpstream, WithProbeInterval(10*time.Millisecond))
+			require.Eventually(t, func() bool {
+				return requests.Load() >= 3
+			}, 5*time.Second, 10*time.Millisecond)
+			report := prober.Stop()
+
+			require.GreaterOrEqual(t, report.Total, 3)
+			if c.wantFailures {
+				require.NotEmpty(t, report.Failures, report.String())
+			} else {
+				require.Empty(t, report.Failures, report.String())
+			}
+		})
+	}
+}
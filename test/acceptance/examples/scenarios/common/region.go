@@ -0,0 +1,112 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultRegionPool is the list of AWS regions
+// scenarios are deployed into by default.
+const DefaultRegionPool = "us-east-1,us-east-2,us-west-1,us-west-2"
+
+// RegionPool spreads the scenarios across a list of AWS regions so that
+// no single region runs out of VPCs or Elastic IPs. The region of a
+// scenario is picked from a hash of its name, so it only depends on the
+// name and the pool, not on the other scenarios.
+type RegionPool struct {
+	regions []string
+
+	// configured is false for the DefaultRegionPool
+	// used when no regions are given.
+	configured bool
+}
+
+// NewRegionPool returns a pool of the given comma separated list of
+// regions. The DefaultRegionPool is used if the list is empty.
+func NewRegionPool(regions string) *RegionPool {
+	p := &RegionPool{configured: true}
+	for _, region := range strings.Split(regions, ",") {
+		if region = strings.TrimSpace(region); region != "" {
+			p.regions = append(p.regions, region)
+		}
+	}
+
+	if len(p.regions) == 0 {
+		p = NewRegionPool(DefaultRegionPool)
+		p.configured = false
+	}
+	return p
+}
+
+// Regions returns the regions of the pool.
+func (p *RegionPool) Regions() []string {
+	return append([]string(nil), p.regions...)
+}
+
+// Assign returns the region that the named scenario deploys into. A scenario
+// that only supports some regions, e.g. the ones where HCP is available, gets
+// one of the regions of the pool that it supports. If the pool has none of
+// them, the scenario gets a region that it does not support, which its
+// Prerequisites report before it is deployed.
+func (p *RegionPool) Assign(scenario string, supported ...string) string {
+	candidates := p.regions
+	if len(supported) > 0 {
+		var regions []string
+		for _, region := range p.regions {
+			if slices.Contains(supported, region) {
+				regions = append(regions, region)
+			}
+		}
+		if len(regions) > 0 {
+			candidates = regions
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(scenario))
+	return candidates[h.Sum32()%uint32(len(candidates))]
+}
+
+var (
+	regionPoolMu sync.Mutex
+	regionPool   = NewRegionPool(DefaultRegionPool)
+)
+
+// SetRegionPool replaces the pool that scenarios get their region from. It
+// must be called before the scenarios are registered, e.g. with the value
+// of the -region-pool flag.
+func SetRegionPool(pool *RegionPool) {
+	regionPoolMu.Lock()
+	defer regionPoolMu.Unlock()
+	regionPool = pool
+}
+
+func currentRegionPool() *RegionPool {
+	regionPoolMu.Lock()
+	defer regionPoolMu.Unlock()
+	return regionPool
+}
+
+// Region returns the AWS region of the current pool that the named
+// scenario deploys into, among the supported regions if any are given.
+func Region(scenario string, supported ...string) string {
+	return currentRegionPool().Assign(scenario, supported...)
+}
+
+// DefaultRegion returns the first region of the current pool if the pool
+// was configured, e.g. with the -region-pool flag, and an empty string
+// otherwise. It is the region used by NewECSClient when none is provided,
+// where an empty string lets the AWS SDK pick the region of the environment,
+// e.g. from AWS_REGION.
+func DefaultRegion() string {
+	pool := currentRegionPool()
+	if !pool.configured {
+		return ""
+	}
+	return pool.regions[0]
+}
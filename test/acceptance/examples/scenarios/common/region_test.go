@@ -0,0 +1,88 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionPool(t *testing.T) {
+	cases := map[string]struct {
+		regions   string
+		scenarios []string
+		expected  []string
+	}{
+		"default pool": {
+			regions:   "",
+			scenarios: []string{"A", "B", "C", "D", "E"},
+			expected:  []string{"us-east-1", "us-east-2", "us-west-1", "us-west-2", "us-east-1"},
+		},
+		"single region": {
+			regions:   "eu-west-1",
+			scenarios: []string{"A", "B"},
+			expected:  []string{"eu-west-1", "eu-west-1"},
+		},
+		"custom pool": {
+			regions:   " eu-west-1, ,eu-central-1 ",
+			scenarios: []string{"A", "B", "C"},
+			expected:  []string{"eu-west-1", "eu-central-1", "eu-west-1"},
+		},
+		"same scenario gets the same region": {
+			regions:   "eu-west-1,eu-central-1",
+			scenarios: []string{"A", "A", "B", "A"},
+			expected:  []string{"eu-west-1", "eu-west-1", "eu-central-1", "eu-west-1"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pool := NewRegionPool(c.regions)
+
+			var assigned []string
+			for _, scenario := range c.scenarios {
+				assigned = append(assigned, pool.Assign(scenario))
+			}
+			require.Equal(t, c.expected, assigned)
+		})
+	}
+}
+
+func TestRegionPoolSupportedRegions(t *testing.T) {
+	pool := NewRegionPool("us-east-1,us-west-1,us-west-2")
+
+	// Scenarios only get the regions of the pool that they support.
+	for _, scenario := range []string{"A", "B", "C", "D", "E"} {
+		require.Equal(t, "us-west-2", pool.Assign(scenario, "us-west-2", "eu-west-1"))
+		require.Contains(t, []string{"us-east-1", "us-west-2"}, pool.Assign(scenario, "us-east-1", "us-west-2"))
+	}
+
+	// Without any supported region in the pool, the scenario
+	// gets its usual region and fails its prerequisites.
+	require.Equal(t, pool.Assign("A"), pool.Assign("A", "eu-west-1"))
+}
+
+func TestRegionPoolIgnoresOtherScenarios(t *testing.T) {
+	// The region of a scenario does not depend on the
+	// scenarios assigned before it.
+	all := NewRegionPool("")
+	for _, scenario := range []string{"A", "B", "C", "D"} {
+		all.Assign(scenario)
+	}
+	require.Equal(t, NewRegionPool("").Assign("E"), all.Assign("E"))
+	require.Equal(t, NewRegionPool("").Assign("D"), all.Assign("D"))
+}
+
+func TestDefaultRegion(t *testing.T) {
+	t.Cleanup(func() { SetRegionPool(NewRegionPool("")) })
+
+	// The AWS SDK picks the region unless a pool is configured.
+	SetRegionPool(NewRegionPool(""))
+	require.Empty(t, DefaultRegion())
+	require.Equal(t, "us-east-1", Region("A"))
+
+	SetRegionPool(NewRegionPool("eu-west-1,eu-central-1"))
+	require.Equal(t, "eu-west-1", DefaultRegion())
+}
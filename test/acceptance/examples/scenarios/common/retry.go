@@ -0,0 +1,20 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
+
+// RetryPolicy controls how long and how often the helpers of the
+// ConsulClientWrapper check the state of Consul before giving up.
+type RetryPolicy = consulwait.RetryPolicy
+
+// WaitTimeoutError is returned when the state of Consul does not
+// converge before the retry policy's timeout elapses.
+type WaitTimeoutError = consulwait.TimeoutError
+
+// DefaultRetryPolicy returns the policy used by the ConsulClientWrapper
+// unless overridden with WithRetryPolicy.
+func DefaultRetryPolicy() RetryPolicy {
+	return consulwait.DefaultRetryPolicy()
+}
@@ -0,0 +1,113 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// releasedModulesSource is the source that the examples' modules
+	// are pinned to when testing upgrades from a released version.
+	releasedModulesSource = "git::https://github.com/hashicorp/terraform-aws-consul-ecs.git//modules/%s?ref=v%s"
+)
+
+var (
+	// localModuleSourceRegex matches the module blocks of the examples that
+	// reference the mesh-task, gateway-task and controller modules present
+	// in this repository.
+	localModuleSourceRegex = regexp.MustCompile(`(?m)^(\s*source\s*=\s*)"(?:\.\./)+modules/(mesh-task|gateway-task|controller)/?"`)
+
+	// releaseHeadingRegex matches the heading of a released version in the changelog.
+	releaseHeadingRegex = regexp.MustCompile(`^## (\d+\.\d+\.\d+) \(`)
+)
+
+// PinnedModuleSources holds the original contents of the
+// Terraform files whose module sources were pinned.
+type PinnedModuleSources struct {
+	files map[string][]byte
+}
+
+// PinModuleSources rewrites every reference to the local mesh-task, gateway-task
+// and controller modules found in the .tf files under dir so that they point to
+// the given released version of the modules instead. The original sources can
+// be restored by calling Restore.
+func PinModuleSources(dir, version string) (*PinnedModuleSources, error) {
+	pinned := &PinnedModuleSources{files: make(map[string][]byte)}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".tf" {
+			return nil
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !localModuleSourceRegex.Match(contents) {
+			return nil
+		}
+
+		replacement := fmt.Sprintf(`${1}"%s"`, fmt.Sprintf(releasedModulesSource, "${2}", version))
+		pinnedContents := localModuleSourceRegex.ReplaceAll(contents, []byte(replacement))
+		if err := os.WriteFile(path, pinnedContents, 0644); err != nil {
+			return err
+		}
+
+		pinned.files[path] = contents
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pinning module sources in %s: %w", dir, err)
+	}
+
+	if len(pinned.files) == 0 {
+		return nil, fmt.Errorf("no references to the local modules found in %s", dir)
+	}
+
+	return pinned, nil
+}
+
+// Restore points the module sources back to the local modules.
+func (p *PinnedModuleSources) Restore() error {
+	for path, contents := range p.files {
+		if err := os.WriteFile(path, contents, 0644); err != nil {
+			return fmt.Errorf("restoring module sources in %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LatestReleasedVersion returns the most recent
+// version released according to the given changelog.
+func LatestReleasedVersion(changelogPath string) (string, error) {
+	f, err := os.Open(changelogPath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := releaseHeadingRegex.FindStringSubmatch(strings.TrimSpace(scanner.Text())); m != nil {
+			return m[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("no released version found in %s", changelogPath)
+}
@@ -0,0 +1,99 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinModuleSources(t *testing.T) {
+	const mainTF = `module "example_client_app" {
+  source                   = "../../modules/mesh-task"
+  family                   = "client"
+}
+
+module "consul_server" {
+  source = "../../modules/dev-server"
+}
+`
+	const datacenterTF = `module "ecs_controller" {
+  source = "../../../modules/controller/"
+}
+`
+	const outputsTF = `output "client_lb_address" {
+  value = "http://example.com"
+}
+`
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.tf":                  mainTF,
+		"outputs.tf":               outputsTF,
+		"datacenter/datacenter.tf": datacenterTF,
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+
+	pinned, err := PinModuleSources(dir, "0.10.0")
+	require.NoError(t, err)
+
+	contents, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `source                   = "git::https://github.com/hashicorp/terraform-aws-consul-ecs.git//modules/mesh-task?ref=v0.10.0"`)
+	require.Contains(t, string(contents), `source = "../../modules/dev-server"`)
+
+	contents, err = os.ReadFile(filepath.Join(dir, "datacenter/datacenter.tf"))
+	require.NoError(t, err)
+	require.Contains(t, string(contents), `source = "git::https://github.com/hashicorp/terraform-aws-consul-ecs.git//modules/controller?ref=v0.10.0"`)
+
+	require.NoError(t, pinned.Restore())
+	for name, expected := range files {
+		contents, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, expected, string(contents))
+	}
+
+	_, err = PinModuleSources(t.TempDir(), "0.10.0")
+	require.ErrorContains(t, err, "no references to the local modules")
+}
+
+func TestLatestReleasedVersion(t *testing.T) {
+	cases := map[string]struct {
+		changelog string
+		expected  string
+		errStr    string
+	}{
+		"latest release": {
+			changelog: "## UNRELEASED\n\n* Change\n\n## 0.10.0 (July 9, 2026)\n\n## 0.9.4 (April 15, 2026)\n",
+			expected:  "0.10.0",
+		},
+		"no release": {
+			changelog: "## UNRELEASED\n",
+			errStr:    "no released version found",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "CHANGELOG.md")
+			require.NoError(t, os.WriteFile(path, []byte(c.changelog), 0644))
+
+			version, err := LatestReleasedVersion(path)
+			if c.errStr != "" {
+				require.ErrorContains(t, err, c.errStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, version)
+		})
+	}
+}
@@ -4,9 +4,7 @@
 package ec2tproxy
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 	"testing"
 	"time"
 
@@ -18,34 +16,43 @@ import (
 )
 
 type TFOutputs struct {
-	ConsulServerLBAddr string `json:"consul_server_lb_address"`
-	ConsulToken        string `json:"consul_server_bootstrap_token"`
-	MeshClientLBAddr   string `json:"mesh_client_lb_address"`
+	ConsulServerLBAddr string `json:"consul_server_lb_address" tfoutput:"required,url"`
+	ConsulToken        string `json:"consul_server_bootstrap_token" tfoutput:"required,sensitive"`
+	MeshClientLBAddr   string `json:"mesh_client_lb_address" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResourcesName := fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6))
+	region := common.Region("EC2_TPROXY")
+	tfResourcesName := common.ResourceName(fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6)))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "EC2_TPROXY",
+		DisplayName:        "Consul ECS on EC2 - Transparent Proxy",
 		FolderName:         "dev-server-ec2-transparent-proxy",
-		TerraformInputVars: getTerraformVars(tfResourcesName),
+		TerraformInputVars: getTerraformVars(tfResourcesName, region),
 		Validate:           validate(tfResourcesName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeEC2,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"single-cluster", "tproxy"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-east-1",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -55,13 +62,12 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		consulServerLBAddr := tfOutputs.ConsulServerLBAddr
 		consulToken := tfOutputs.ConsulToken
 		meshClientLBAddr := tfOutputs.MeshClientLBAddr
-		meshClientLBAddr = strings.TrimSuffix(meshClientLBAddr, "/ui")
 
 		logger.Log(t, "Setting up the Consul client")
 		consulClient, err := common.SetupConsulClient(t, consulServerLBAddr, common.WithToken(consulToken))
@@ -4,9 +4,7 @@
 package ec2
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
@@ -16,33 +14,43 @@ import (
 )
 
 type TFOutputs struct {
-	ConsulServerLBAddr string `json:"consul_server_lb_address"`
-	MeshClientLBAddr   string `json:"mesh_client_lb_address"`
+	ConsulServerLBAddr string `json:"consul_server_lb_address" tfoutput:"required,url"`
+	MeshClientLBAddr   string `json:"mesh_client_lb_address" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResourcesName := fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6))
+	region := common.Region("EC2")
+	tfResourcesName := common.ResourceName(fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6)))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "EC2",
+		DisplayName:        "Consul ECS on EC2",
 		FolderName:         "dev-server-ec2",
-		TerraformInputVars: getTerraformVars(tfResourcesName),
+		TerraformInputVars: getTerraformVars(tfResourcesName, region),
 		Validate:           validate(tfResourcesName),
+		TrafficProbe:       trafficProbe(tfResourcesName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeEC2,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"single-cluster"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-east-2",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -52,12 +60,11 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		consulServerLBAddr := tfOutputs.ConsulServerLBAddr
 		meshClientLBAddr := tfOutputs.MeshClientLBAddr
-		meshClientLBAddr = strings.TrimSuffix(meshClientLBAddr, "/ui")
 
 		logger.Log(t, "Setting up the Consul client")
 		consulClient, err := common.SetupConsulClient(t, consulServerLBAddr)
@@ -74,3 +81,15 @@ func validate(tfResName string) scenarios.ValidateHook {
 		common.ValidateFakeServiceResponse(t, meshClientLBAddr, serverAppName)
 	}
 }
+
+func trafficProbe(tfResName string) scenarios.TrafficProbeHook {
+	return func(t *testing.T, data []byte) scenarios.ProbeTarget {
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
+
+		return scenarios.ProbeTarget{
+			URL:              tfOutputs.MeshClientLBAddr,
+			ExpectedUpstream: fmt.Sprintf("%s-example-server-app", tfResName),
+		}
+	}
+}
@@ -4,8 +4,6 @@
 package hcp
 
 import (
-	"encoding/json"
-	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -15,56 +13,72 @@ import (
 	"github.com/hashicorp/serf/testutil/retry"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/common"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/configentries"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
 	"github.com/stretchr/testify/require"
 )
 
 type TFOutputs struct {
-	HCPConsulServerAddr  string `json:"hcp_public_endpoint"`
-	HCPConsulServerToken string `json:"token"`
-	ClientApp            *App   `json:"client"`
-	ServerApp            *App   `json:"server"`
+	HCPConsulServerAddr  string `json:"hcp_public_endpoint" tfoutput:"required,url"`
+	HCPConsulServerToken string `json:"token" tfoutput:"required,sensitive"`
+	ClientApp            *App   `json:"client" tfoutput:"required"`
+	ServerApp            *App   `json:"server" tfoutput:"required"`
 }
 
 type App struct {
-	Name          string `json:"name"`
-	ECSClusterARN string `json:"ecs_cluster_arn"`
+	Name          string `json:"name" tfoutput:"required"`
+	ECSClusterARN string `json:"ecs_cluster_arn" tfoutput:"required"`
 	Region        string `json:"region"`
 	Partition     string `json:"partition"`
 	Namespace     string `json:"namespace"`
 }
 
+// hcpRegions are the AWS regions where HCP Consul clusters can be created.
+var hcpRegions = []string{
+	"us-east-1", "us-east-2", "us-west-2", "ca-central-1",
+	"eu-central-1", "eu-west-1", "eu-west-2",
+	"ap-northeast-1", "ap-southeast-1", "ap-southeast-2",
+}
+
 func RegisterScenario(r scenarios.ScenarioRegistry) {
+	region := common.Region("HCP", hcpRegions...)
+
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "HCP",
+		DisplayName:        "Consul ECS with HCP",
 		FolderName:         "admin-partitions/terraform",
-		TerraformInputVars: getTerraformVars(),
-		Validate:           validate(),
+		TerraformInputVars: getTerraformVars(region),
+		Validate:           validate(region),
+		Prerequisites: scenarios.Prerequisites{
+			EnvVars:          []string{"HCP_PROJECT_ID", "HCP_CLIENT_ID", "HCP_CLIENT_SECRET"},
+			LaunchType:       scenarios.LaunchTypeFargate,
+			Region:           region,
+			SupportedRegions: hcpRegions,
+		},
+		Tags:      []string{"single-cluster", "hcp"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars() scenarios.TerraformInputVarsHook {
+func getTerraformVars(region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-west-2",
+			"region": region,
 		}
 
-		hcpProjectID := os.Getenv("HCP_PROJECT_ID")
-		if hcpProjectID == "" {
-			return nil, fmt.Errorf("expected HCP_PROJECT_ID to be non empty")
-		}
-		vars["hcp_project_id"] = hcpProjectID
+		vars["hcp_project_id"] = os.Getenv("HCP_PROJECT_ID")
 
 		return vars, nil
 	}
 }
 
-func validate() scenarios.ValidateHook {
+func validate(region string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		logger.Log(t, "Setting up the Consul client")
 		consulClient, err := common.SetupConsulClient(t, tfOutputs.HCPConsulServerAddr, common.WithToken(tfOutputs.HCPConsulServerToken))
@@ -75,7 +89,7 @@ func validate() scenarios.ValidateHook {
 
 		logger.Log(t, "Setting up ECS client")
 
-		ecsClient, err := common.NewECSClient()
+		ecsClient, err := common.NewECSClient(common.WithRegion(region))
 		require.NoError(t, err)
 
 		// List tasks for the client service
@@ -87,15 +101,48 @@ func validate() scenarios.ValidateHook {
 		retry.RunWith(&retry.Timer{Timeout: 3 * time.Minute, Wait: 10 * time.Second}, t, func(r *retry.R) {
 			res, err := ecsClient.
 				WithClusterARN(tfOutputs.ClientApp.ECSClusterARN).
-				ExecuteCommandInteractive(t, tasks[0], "basic", `/bin/sh -c "curl localhost:1234"`)
+				ExecuteCommand(tasks[0], "basic", `/bin/sh -c "curl localhost:1234"`)
 			r.Check(err)
-			if !strings.Contains(res, `"code": 200`) {
-				r.Errorf("response was unexpected: %q", res)
+			if res.ExitCode != 0 || !strings.Contains(res.Stdout, `"code": 200`) {
+				r.Errorf("response was unexpected, curl exited with %d: %q", res.ExitCode, res.Stdout+res.Stderr)
 			}
 		})
+
+		validateL7Intentions(t, consulClient, tfOutputs.ClientApp, tfOutputs.ServerApp, func(command string) (*ecsexec.Result, error) {
+			return ecsClient.
+				WithClusterARN(tfOutputs.ClientApp.ECSClusterARN).
+				ExecuteCommand(tasks[0], "basic", command)
+		})
 	}
 }
 
+// validateL7Intentions replaces the intention that the example creates
+// with one that only allows the client to call the /allowed path of the
+// server. The example's intention is restored when the test completes.
+func validateL7Intentions(t *testing.T, consulClient *common.ConsulClientWrapper, client, server *App, exec func(string) (*ecsexec.Result, error)) {
+	logger.Log(t, "Validating L7 intentions between the apps")
+
+	consulClient.WriteConfigEntry(&api.ServiceConfigEntry{
+		Kind:      api.ServiceDefaults,
+		Name:      server.Name,
+		Partition: server.Partition,
+		Namespace: server.Namespace,
+		Protocol:  "http",
+	})
+	consulClient.WriteServiceIntentions(configentries.NewServiceIntentions(server.Name, server.Partition, server.Namespace).
+		Permissions(
+			configentries.IntentionSource{Name: client.Name, Partition: client.Partition, Namespace: client.Namespace},
+			configentries.AllowHTTP(configentries.WithPathPrefix("/allowed"), configentries.WithMethods("GET")),
+			configentries.DenyHTTP(configentries.WithPathPrefix("/")),
+		))
+
+	common.ValidateHTTPIntentions(t, exec, "localhost:1234",
+		common.HTTPIntentionCheck{Path: "/allowed", Allowed: true},
+		common.HTTPIntentionCheck{Path: "/allowed/nested", Allowed: true},
+		common.HTTPIntentionCheck{Path: "/denied"},
+	)
+}
+
 func ensureServiceReadiness(consulClient *common.ConsulClientWrapper, service *App) {
 	opts := &api.QueryOptions{
 		Namespace: service.Namespace,
@@ -4,13 +4,13 @@
 package localityawarerouting
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
+	"path"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/hashicorp/serf/testutil/retry"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
@@ -20,68 +20,90 @@ import (
 )
 
 type TFOutputs struct {
-	ConsulServerAddr  string `json:"consul_server_url"`
-	ConsulServerToken string `json:"consul_server_bootstrap_token"`
-	MeshClientLBAddr  string `json:"client_lb_address"`
-	ECSClusterARN     string `json:"ecs_cluster_arn"`
+	ConsulServerAddr  string `json:"consul_server_url" tfoutput:"required,url"`
+	ConsulServerToken string `json:"consul_server_bootstrap_token" tfoutput:"required,sensitive"`
+	MeshClientLBAddr  string `json:"client_lb_address" tfoutput:"required,url"`
+	ECSClusterARN     string `json:"ecs_cluster_arn" tfoutput:"required"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResName := common.GenerateRandomStr(4)
+	region := common.Region("LOCALITY_AWARE_ROUTING")
+	tfResName := common.ResourceName(common.GenerateRandomStr(4))
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "LOCALITY_AWARE_ROUTING",
+		DisplayName:        "Locality Aware Routing",
 		FolderName:         "locality-aware-routing",
-		TerraformInputVars: getTerraformVars(tfResName),
-		Validate:           validate(tfResName),
+		TerraformInputVars: getTerraformVars(tfResName, region),
+		PostApply:          postApply(),
+		Validate:           validate(tfResName, region),
+		OnFailure:          onFailure(region),
+		Prerequisites: scenarios.Prerequisites{
+			Enterprise:    true,
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"multi-cluster"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-west-2",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
-		enterpriseLicense := os.Getenv("CONSUL_LICENSE")
-		if enterpriseLicense == "" {
-			return nil, fmt.Errorf("expected CONSUL_LICENSE to be non empty")
-		}
-		vars["consul_license"] = enterpriseLicense
+		vars["consul_license"] = os.Getenv("CONSUL_LICENSE")
 
 		return vars, nil
 	}
 }
 
-func validate(tfResName string) scenarios.ValidateHook {
-	return func(t *testing.T, data []byte) {
-		logger.Log(t, "Fetching required output terraform variables")
+const (
+	clientAppName = "example-client-app"
+	serverAppName = "example-server-app"
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
-		tfOutputs.MeshClientLBAddr = strings.TrimSuffix(tfOutputs.MeshClientLBAddr, "/ui")
+	// deregistrationBound is the time within which a stopped task
+	// must leave the catalog and replacementBound the time within
+	// which ECS must replace it with a healthy task.
+	deregistrationBound = 30 * time.Second
+	replacementBound    = 5 * time.Minute
+)
+
+func postApply() scenarios.PostApplyHook {
+	return func(t *testing.T, data []byte) {
+		tfOutputs := getTFOutputs(t, data)
 
 		logger.Log(t, "Setting up the Consul client")
 		consulClient, err := common.SetupConsulClient(t, tfOutputs.ConsulServerAddr, common.WithToken(tfOutputs.ConsulServerToken))
 		require.NoError(t, err)
 
-		clientAppName := "example-client-app"
-		serverAppName := "example-server-app"
-
 		consulClient.EnsureServiceReadiness(clientAppName, nil)
 		consulClient.EnsureServiceReadiness(serverAppName, nil)
 
 		consulClient.EnsureServiceInstances(serverAppName, 2, nil)
+	}
+}
+
+func validate(tfResName, region string) scenarios.ValidateHook {
+	return func(t *testing.T, data []byte) {
+		tfOutputs := getTFOutputs(t, data)
 
-		ecsClient, err := common.NewECSClient(common.WithClusterARN(tfOutputs.ECSClusterARN))
+		ecsClient, err := common.NewECSClient(common.WithClusterARN(tfOutputs.ECSClusterARN), common.WithRegion(region))
 		require.NoError(t, err)
 
+		consulClient, err := common.SetupConsulClient(t, tfOutputs.ConsulServerAddr, common.WithToken(tfOutputs.ConsulServerToken))
+		require.NoError(t, err)
+		recorder := consulClient.StartCatalogRecorder(nil, serverAppName)
+
 		logger.Log(t, "Listing and describing tasks for each ECS service")
 		clientTasks := assertAndListTasks(t, ecsClient, clientAppName, 1)
 		serverTasks := assertAndListTasks(t, ecsClient, serverAppName, 2)
@@ -140,8 +162,16 @@ func validate(tfResName string) scenarios.ValidateHook {
 		}
 
 		logger.Log(t, "Stopping the server app's task present in the same AZ as that of the client.")
+		existingInstances := recorder.Instances(serverAppName)
+		stopped := recorder.Mark("StopTask")
 		require.NoError(t, ecsClient.StopTask(*serverTaskToStop.TaskArn, "stopping as part of a test"))
 
+		taskID := path.Base(*serverTaskToStop.TaskArn)
+		removed, err := recorder.WaitForEvent(stopped, deregistrationBound, fmt.Sprintf("task %s to be deregistered", taskID),
+			common.InstanceRemovedFrom(serverAppName).ForInstance(taskID))
+		require.NoError(t, err)
+		logger.Log(t, fmt.Sprintf("task %s was deregistered %s after it was stopped", taskID, removed.Time.Sub(stopped)))
+
 		logger.Log(t, "Calling the client app's load balancer to verify if the client app falls back to hit the server app in the other availability zone")
 		performAssertions(false)
 
@@ -152,6 +182,12 @@ func validate(tfResName string) scenarios.ValidateHook {
 			require.Len(r, serverTasks, 2)
 		})
 
+		replaced, err := recorder.WaitForEvent(stopped, replacementBound, "a replacement server task to be healthy",
+			common.InstanceHealthy(serverAppName).ExceptInstances(existingInstances...))
+		require.NoError(t, err)
+		logger.Log(t, fmt.Sprintf("replacement instance %s was healthy %s after the task was stopped", replaced.Instance, replaced.Time.Sub(stopped)))
+		logger.Log(t, recorder.Stop().String())
+
 		tasks = assertAndDescribeTasks(t, ecsClient, serverTasks, 2)
 		serverTaskIPMap = getTaskIPToTaskMap(t, tasks)
 
@@ -160,6 +196,52 @@ func validate(tfResName string) scenarios.ValidateHook {
 	}
 }
 
+// onFailure logs the placement and status of the server app's tasks
+// since the assertions of this scenario depend on them.
+func onFailure(region string) scenarios.OnFailureHook {
+	return func(t *testing.T, data []byte) {
+		if data == nil {
+			return
+		}
+		tfOutputs := getTFOutputs(t, data)
+
+		ecsClient, err := common.NewECSClient(common.WithClusterARN(tfOutputs.ECSClusterARN), common.WithRegion(region))
+		if err != nil {
+			logger.Log(t, "failed to setup ECS client:", err)
+			return
+		}
+
+		for _, service := range []string{clientAppName, serverAppName} {
+			taskARNs, err := ecsClient.ListTasksForService(service)
+			if err != nil || len(taskARNs) == 0 {
+				logger.Log(t, fmt.Sprintf("failed to list tasks for %s: %v", service, err))
+				continue
+			}
+
+			tasks, err := ecsClient.DescribeTasks(taskARNs)
+			if err != nil {
+				logger.Log(t, fmt.Sprintf("failed to describe tasks for %s: %v", service, err))
+				continue
+			}
+
+			for _, task := range tasks.Tasks {
+				logger.Log(t, fmt.Sprintf("%s task %s: status=%s, availability zone=%s",
+					service, aws.ToString(task.TaskArn), aws.ToString(task.LastStatus), aws.ToString(task.AvailabilityZone)))
+			}
+		}
+	}
+}
+
+func getTFOutputs(t *testing.T, data []byte) *TFOutputs {
+	logger.Log(t, "Fetching required output terraform variables")
+
+	tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+	require.NoError(t, err)
+	logger.Log(t, "terraform outputs:", scenarios.RedactOutputs(tfOutputs))
+
+	return tfOutputs
+}
+
 func assertAndListTasks(t *testing.T, ecsClient *common.ECSClientWrapper, service string, expectedCount int) []string {
 	tasks, err := ecsClient.ListTasksForService(service)
 	require.NoError(t, err)
@@ -0,0 +1,264 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	// outputTag is the struct tag used to annotate the fields
+	// of a scenario's terraform outputs struct.
+	outputTag = "tfoutput"
+
+	optRequired   = "required"
+	optSensitive  = "sensitive"
+	optURL        = "url"
+	optTrimSuffix = "trimsuffix="
+
+	redactedValue = "<redacted>"
+)
+
+// MissingOutputsError is returned by DecodeOutputs when
+// required terraform outputs are missing or empty.
+type MissingOutputsError struct {
+	Missing []string
+}
+
+func (e *MissingOutputsError) Error() string {
+	return fmt.Sprintf("missing required terraform outputs: %s", strings.Join(e.Missing, ", "))
+}
+
+// DecodeOutputs decodes the JSON encoded terraform outputs passed to the
+// scenario hooks into a value of type T. The name of every output is taken
+// from the field's json tag. Fields, including the ones of nested structs,
+// can be annotated with a `tfoutput` tag holding a comma separated list of
+// the following options:
+//
+//   - required: the output must be present and non empty.
+//   - url: the value is a URL. Trailing `/ui` and `/` are removed from it.
+//   - trimsuffix=<suffix>: the suffix is removed from the value.
+//   - sensitive: the value is redacted by RedactOutputs.
+//
+// A *MissingOutputsError listing every missing output is returned if any
+// of the required outputs are missing.
+func DecodeOutputs[T any](data []byte) (*T, error) {
+	var outputs T
+	missing, err := decodeOutputs(data, &outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingOutputsError{Missing: missing}
+	}
+	return &outputs, nil
+}
+
+// CheckOutputs verifies that the JSON encoded terraform outputs match the
+// struct that a scenario decodes them into. The outputs argument is a value
+// of that struct or a pointer to it. A *MissingOutputsError is returned if
+// any output declared by the struct is absent or if a required output is
+// empty.
+func CheckOutputs(outputs any, data []byte) error {
+	t := reflect.TypeOf(outputs)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("terraform outputs must be decoded into a struct, got %T", outputs)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("unmarshalling terraform outputs: %w", err)
+	}
+
+	var missing []string
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := outputName(t.Field(i))
+		if !ok {
+			continue
+		}
+		if _, ok := raw[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	requiredMissing, err := decodeOutputs(data, reflect.New(t).Interface())
+	if err != nil {
+		return err
+	}
+	for _, path := range requiredMissing {
+		if !slices.Contains(missing, path) {
+			missing = append(missing, path)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &MissingOutputsError{Missing: missing}
+	}
+	return nil
+}
+
+// decodeOutputs decodes the outputs into the struct pointed to by
+// outputs, applies the transforms and returns the paths of the
+// required outputs that are missing.
+func decodeOutputs(data []byte, outputs any) ([]string, error) {
+	if err := json.Unmarshal(data, outputs); err != nil {
+		return nil, fmt.Errorf("unmarshalling terraform outputs: %w", err)
+	}
+
+	var missing []string
+	walkOutputs(reflect.ValueOf(outputs).Elem(), "", func(path string, v reflect.Value, opts outputOpts) {
+		if opts.required && isEmpty(v) {
+			missing = append(missing, path)
+			return
+		}
+
+		if v.Kind() == reflect.String {
+			s := v.String()
+			if opts.url {
+				s = strings.TrimSuffix(strings.TrimSuffix(s, "/"), "/ui")
+			}
+			for _, suffix := range opts.trimSuffixes {
+				s = strings.TrimSuffix(s, suffix)
+			}
+			v.SetString(s)
+		}
+	})
+
+	return missing, nil
+}
+
+// RedactOutputs returns a JSON representation of the decoded outputs
+// with the values of the fields marked as sensitive redacted. It is
+// meant to be used to log the outputs of a scenario.
+func RedactOutputs(outputs any) string {
+	b, err := json.Marshal(redact(reflect.ValueOf(outputs), false))
+	if err != nil {
+		return fmt.Sprintf("failed to marshal outputs: %s", err)
+	}
+	return string(b)
+}
+
+type outputOpts struct {
+	required     bool
+	sensitive    bool
+	url          bool
+	trimSuffixes []string
+}
+
+func parseOutputOpts(tag string) outputOpts {
+	var opts outputOpts
+	for _, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case opt == optRequired:
+			opts.required = true
+		case opt == optSensitive:
+			opts.sensitive = true
+		case opt == optURL:
+			opts.url = true
+		case strings.HasPrefix(opt, optTrimSuffix):
+			opts.trimSuffixes = append(opts.trimSuffixes, strings.TrimPrefix(opt, optTrimSuffix))
+		}
+	}
+	return opts
+}
+
+// outputName returns the name of the terraform output for a field
+// and false if the field is not decoded from the outputs.
+func outputName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+
+	name := f.Name
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		tagName := strings.Split(tag, ",")[0]
+		if tagName == "-" {
+			return "", false
+		}
+		if tagName != "" {
+			name = tagName
+		}
+	}
+	return name, true
+}
+
+// walkOutputs calls fn for every field of the struct v, descending into
+// nested structs and non nil pointers to structs. The path passed to fn is
+// the dot separated list of output names leading to the field.
+func walkOutputs(v reflect.Value, prefix string, fn func(path string, v reflect.Value, opts outputOpts)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := outputName(f)
+		if !ok {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		fn(path, fv, parseOutputOpts(f.Tag.Get(outputTag)))
+
+		switch {
+		case fv.Kind() == reflect.Struct:
+			walkOutputs(fv, path, fn)
+		case fv.Kind() == reflect.Pointer && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			walkOutputs(fv.Elem(), path, fn)
+		}
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// redact converts v into a value that marshals to the same JSON
+// as v, except for the fields marked as sensitive.
+func redact(v reflect.Value, sensitive bool) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if sensitive && !isEmpty(v) {
+		return redactedValue
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem(), false)
+	case reflect.Struct:
+		m := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := outputName(f)
+			if !ok {
+				continue
+			}
+			m[name] = redact(v.Field(i), parseOutputOpts(f.Tag.Get(outputTag)).sensitive)
+		}
+		return m
+	default:
+		return v.Interface()
+	}
+}
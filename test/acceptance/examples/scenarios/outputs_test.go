@@ -0,0 +1,180 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAppOutputs struct {
+	Name   string `json:"name" tfoutput:"required"`
+	LBAddr string `json:"lb_address" tfoutput:"required,url"`
+}
+
+type testOutputs struct {
+	ConsulServerURL  string          `json:"consul_server_url" tfoutput:"required,url"`
+	ConsulToken      string          `json:"consul_server_bootstrap_token" tfoutput:"required,sensitive"`
+	ClusterARN       string          `json:"ecs_cluster_arn" tfoutput:"trimsuffix=-cluster"`
+	Region           string          `json:"region"`
+	Client           *testAppOutputs `json:"client" tfoutput:"required"`
+	Server           testAppOutputs  `json:"server"`
+	OptionalApp      *testAppOutputs `json:"optional_app"`
+	PrivateSubnetIDs []string        `json:"private_subnet_ids" tfoutput:"required"`
+}
+
+func TestDecodeOutputs(t *testing.T) {
+	cases := map[string]struct {
+		data     string
+		expected *testOutputs
+		missing  []string
+		errStr   string
+	}{
+		"outputs are decoded and transformed": {
+			data: `{
+				"consul_server_url": "http://consul.example.com/ui/",
+				"consul_server_bootstrap_token": "secret",
+				"ecs_cluster_arn": "arn:aws:ecs:us-east-1:123456789012:cluster/test-cluster",
+				"client": {"name": "client", "lb_address": "http://client.example.com/ui"},
+				"server": {"name": "server", "lb_address": "http://server.example.com"},
+				"private_subnet_ids": ["subnet-1"]
+			}`,
+			expected: &testOutputs{
+				ConsulServerURL:  "http://consul.example.com",
+				ConsulToken:      "secret",
+				ClusterARN:       "arn:aws:ecs:us-east-1:123456789012:cluster/test",
+				Client:           &testAppOutputs{Name: "client", LBAddr: "http://client.example.com"},
+				Server:           testAppOutputs{Name: "server", LBAddr: "http://server.example.com"},
+				PrivateSubnetIDs: []string{"subnet-1"},
+			},
+		},
+		"all missing outputs are reported": {
+			data: `{
+				"consul_server_bootstrap_token": "secret",
+				"server": {"name": "server"},
+				"private_subnet_ids": []
+			}`,
+			missing: []string{
+				"consul_server_url",
+				"client",
+				"server.lb_address",
+				"private_subnet_ids",
+			},
+		},
+		"missing nested outputs are reported": {
+			data: `{
+				"consul_server_url": "http://consul.example.com",
+				"consul_server_bootstrap_token": "secret",
+				"client": {"lb_address": "http://client.example.com"},
+				"server": {"name": "server", "lb_address": "http://server.example.com"},
+				"optional_app": {"name": "optional"},
+				"private_subnet_ids": ["subnet-1"]
+			}`,
+			missing: []string{
+				"client.name",
+				"optional_app.lb_address",
+			},
+		},
+		"invalid outputs": {
+			data:   `{"consul_server_url": 1}`,
+			errStr: "unmarshalling terraform outputs",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			outputs, err := DecodeOutputs[testOutputs]([]byte(c.data))
+			switch {
+			case c.errStr != "":
+				require.ErrorContains(t, err, c.errStr)
+			case len(c.missing) > 0:
+				var missingErr *MissingOutputsError
+				require.ErrorAs(t, err, &missingErr)
+				require.Equal(t, c.missing, missingErr.Missing)
+			default:
+				require.NoError(t, err)
+				require.Equal(t, c.expected, outputs)
+			}
+		})
+	}
+}
+
+func TestRedactOutputs(t *testing.T) {
+	outputs := &testOutputs{
+		ConsulServerURL: "http://consul.example.com",
+		ConsulToken:     "secret",
+		Client:          &testAppOutputs{Name: "client", LBAddr: "http://client.example.com"},
+	}
+
+	redacted := RedactOutputs(outputs)
+	require.NotContains(t, redacted, "secret")
+	require.JSONEq(t, `{
+		"consul_server_url": "http://consul.example.com",
+		"consul_server_bootstrap_token": "<redacted>",
+		"ecs_cluster_arn": "",
+		"region": "",
+		"client": {"name": "client", "lb_address": "http://client.example.com"},
+		"server": {"name": "", "lb_address": ""},
+		"optional_app": null,
+		"private_subnet_ids": null
+	}`, redacted)
+}
+
+func TestCheckOutputs(t *testing.T) {
+	cases := map[string]struct {
+		outputs any
+		data    string
+		missing []string
+		errStr  string
+	}{
+		"outputs match": {
+			outputs: testAppOutputs{},
+			data:    `{"name": "client", "lb_address": "http://client.example.com", "extra": "value"}`,
+		},
+		"pointer to the outputs struct": {
+			outputs: &testAppOutputs{},
+			data:    `{"name": "client", "lb_address": "http://client.example.com"}`,
+		},
+		"absent and empty outputs": {
+			outputs: testOutputs{},
+			data: `{
+				"consul_server_url": "",
+				"consul_server_bootstrap_token": "secret",
+				"client": {"name": "client", "lb_address": "http://client.example.com"},
+				"server": {"name": "server", "lb_address": "http://server.example.com"},
+				"private_subnet_ids": ["subnet-1"]
+			}`,
+			missing: []string{"ecs_cluster_arn", "region", "optional_app", "consul_server_url"},
+		},
+		"outputs of another type": {
+			outputs: testAppOutputs{},
+			data:    `{"name": ["client"]}`,
+			errStr:  "unmarshalling terraform outputs",
+		},
+		"not a struct": {
+			outputs: "outputs",
+			data:    `{}`,
+			errStr:  "must be decoded into a struct",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			err := CheckOutputs(c.outputs, []byte(c.data))
+			switch {
+			case c.errStr != "":
+				require.ErrorContains(t, err, c.errStr)
+			case len(c.missing) > 0:
+				var missingErr *MissingOutputsError
+				require.ErrorAs(t, err, &missingErr)
+				require.Equal(t, c.missing, missingErr.Missing)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
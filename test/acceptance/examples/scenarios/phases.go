@@ -0,0 +1,78 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+)
+
+// PhaseSteps are the steps that RunPhases takes to deploy the
+// example of a scenario in every phase.
+type PhaseSteps struct {
+	// Setup is an optional step called at the start of every phase,
+	// before the input variables of the phase are read.
+	Setup func(t *testing.T, phase Phase, stepPrefix string)
+
+	// InputVars returns the terraform input variables of the phase.
+	InputVars func(t *testing.T, phase Phase) map[string]interface{}
+
+	// Apply applies the example with the input variables of the
+	// phase and returns the terraform outputs as JSON.
+	Apply func(t *testing.T, stepPrefix string, tfVars map[string]interface{}) []byte
+
+	// Hook calls a hook of the scenario as the named step of the phase,
+	// e.g. to time it. The hook is called directly if Hook is nil.
+	Hook func(t *testing.T, stepName string, hook func())
+}
+
+// RunPhases runs TerraformInputVars, terraform apply, PostApply and Validate
+// for every phase in order, against the same state. The steps of a scenario
+// with multiple phases are prefixed with the name of their phase, every phase
+// runs as a subtest and the phases stop at the first one that fails, whose
+// name is returned.
+func (r *ScenarioRegistration) RunPhases(t *testing.T, phases []Phase, steps PhaseSteps) (failedPhase string) {
+	hook := steps.Hook
+	if hook == nil {
+		hook = func(_ *testing.T, _ string, f func()) { f() }
+	}
+
+	runPhase := func(phase Phase, stepPrefix string) func(t *testing.T) {
+		return func(t *testing.T) {
+			if steps.Setup != nil {
+				steps.Setup(t, phase, stepPrefix)
+			}
+
+			outputJSON := steps.Apply(t, stepPrefix, steps.InputVars(t, phase))
+
+			if r.PostApply != nil {
+				logger.Log(t, "running post apply hook for scenario")
+				hook(t, stepPrefix+"post-apply", func() {
+					r.PostApply(t, outputJSON)
+				})
+			}
+
+			logger.Log(t, "running validation for scenario")
+			hook(t, stepPrefix+"validate", func() {
+				phase.Validate(t, outputJSON)
+			})
+			logger.Log(t, "validation successful!!")
+		}
+	}
+
+	if len(phases) == 1 {
+		runPhase(phases[0], "")(t)
+		return ""
+	}
+
+	for i, phase := range phases {
+		logger.Log(t, fmt.Sprintf("running phase %s (%d/%d)", phase.Name, i+1, len(phases)))
+		if !t.Run(phase.Name, runPhase(phase, phase.Name+"/")) {
+			return phase.Name
+		}
+	}
+	return ""
+}
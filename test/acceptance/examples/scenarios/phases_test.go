@@ -0,0 +1,105 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPhases(t *testing.T) {
+	cases := map[string]struct {
+		phases    []string
+		wantCalls []string
+	}{
+		"single phase": {
+			phases: []string{"default"},
+			wantCalls: []string{
+				"default: input vars",
+				"step apply",
+				"default: apply",
+				"step post-apply",
+				"post-apply {default}",
+				"step validate",
+				"default: validate {default}",
+			},
+		},
+		"multiple phases": {
+			phases: []string{"acls-disabled", "acls-enabled"},
+			wantCalls: []string{
+				"acls-disabled: setup",
+				"acls-disabled: input vars",
+				"step acls-disabled/apply",
+				"acls-disabled: apply",
+				"step acls-disabled/post-apply",
+				"post-apply {acls-disabled}",
+				"step acls-disabled/validate",
+				"acls-disabled: validate {acls-disabled}",
+				"acls-enabled: setup",
+				"acls-enabled: input vars",
+				"step acls-enabled/apply",
+				"acls-enabled: apply",
+				"step acls-enabled/post-apply",
+				"post-apply {acls-enabled}",
+				"step acls-enabled/validate",
+				"acls-enabled: validate {acls-enabled}",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var calls []string
+			record := func(format string, args ...any) {
+				calls = append(calls, fmt.Sprintf(format, args...))
+			}
+
+			scenario := ScenarioRegistration{
+				PostApply: func(_ *testing.T, outputs []byte) {
+					record("post-apply %s", outputs)
+				},
+			}
+			var phases []Phase
+			for _, name := range c.phases {
+				phases = append(phases, Phase{
+					Name: name,
+					TerraformInputVars: func() (map[string]interface{}, error) {
+						record("%s: input vars", name)
+						return map[string]interface{}{"phase": name}, nil
+					},
+					Validate: func(_ *testing.T, outputs []byte) {
+						record("%s: validate %s", name, outputs)
+					},
+				})
+			}
+
+			steps := PhaseSteps{
+				InputVars: func(t *testing.T, phase Phase) map[string]interface{} {
+					tfVars, err := phase.TerraformInputVars()
+					require.NoError(t, err)
+					return tfVars
+				},
+				Apply: func(_ *testing.T, stepPrefix string, tfVars map[string]interface{}) []byte {
+					record("step %sapply", stepPrefix)
+					record("%s: apply", tfVars["phase"])
+					return []byte(fmt.Sprintf("{%s}", tfVars["phase"]))
+				},
+				Hook: func(_ *testing.T, stepName string, hook func()) {
+					record("step %s", stepName)
+					hook()
+				},
+			}
+			if len(phases) > 1 {
+				steps.Setup = func(_ *testing.T, phase Phase, _ string) {
+					record("%s: setup", phase.Name)
+				}
+			}
+
+			require.Empty(t, scenario.RunPhases(t, phases, steps))
+			require.Equal(t, c.wantCalls, calls)
+		})
+	}
+}
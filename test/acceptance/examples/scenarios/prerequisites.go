@@ -0,0 +1,195 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package scenarios
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LaunchType string
+
+const (
+	LaunchTypeFargate LaunchType = "FARGATE"
+	LaunchTypeEC2     LaunchType = "EC2"
+
+	// enterpriseLicenseEnvVar is the environment variable that holds
+	// the Consul Enterprise license used by enterprise scenarios.
+	enterpriseLicenseEnvVar = "CONSUL_LICENSE"
+
+	// regionsEnvVar and launchTypesEnvVar optionally restrict the regions
+	// and the ECS launch types that scenarios are allowed to use.
+	regionsEnvVar     = "TEST_REGIONS"
+	launchTypesEnvVar = "TEST_LAUNCH_TYPES"
+
+	// egressCheckURL is the endpoint used to verify that the host running
+	// the tests can reach the internet.
+	egressCheckURL = "https://api64.ipify.org?format=text"
+)
+
+// Prerequisites declares what a scenario needs from the environment
+// running it. All the prerequisites are checked before the scenario
+// is deployed.
+type Prerequisites struct {
+	// EnvVars is the list of environment variables that
+	// must be set to a non empty value.
+	EnvVars []string `json:"env_vars,omitempty"`
+
+	// Enterprise indicates that the scenario deploys Consul Enterprise
+	// and requires a license to be present in CONSUL_LICENSE.
+	Enterprise bool `json:"enterprise,omitempty"`
+
+	// LaunchType is the ECS launch type used by the scenario's tasks.
+	LaunchType LaunchType `json:"launch_type,omitempty"`
+
+	// Region is the AWS region that the scenario deploys into. Scenarios
+	// get it from the region pool with common.Region.
+	Region string `json:"region,omitempty"`
+
+	// SupportedRegions restricts the regions that the scenario can deploy
+	// into, e.g. to the regions where HCP is available. An empty list
+	// supports every region. Scenarios pass it to common.Region so that they
+	// get one of these regions from the pool.
+	SupportedRegions []string `json:"supported_regions,omitempty"`
+
+	// NetworkEgress indicates that the scenario needs to reach the internet
+	// from the host running the tests, e.g. to discover its public IP.
+	NetworkEgress bool `json:"network_egress,omitempty"`
+}
+
+// Environment describes what the environment running
+// the scenarios provides.
+type Environment struct {
+	// LookupEnv retrieves the value of an environment variable.
+	LookupEnv func(string) (string, bool)
+
+	// Regions is the list of AWS regions scenarios can deploy into.
+	// An empty list allows every region.
+	Regions []string
+
+	// LaunchTypes is the list of ECS launch types scenarios can use.
+	// An empty list allows every launch type.
+	LaunchTypes []LaunchType
+
+	// CheckEgress returns an error if the internet cannot be
+	// reached from the host running the tests.
+	CheckEgress func() error
+}
+
+var (
+	egressOnce sync.Once
+	egressErr  error
+)
+
+// DefaultEnvironment returns the environment of the current process. The
+// regions and launch types can be restricted with the TEST_REGIONS and
+// TEST_LAUNCH_TYPES environment variables as comma separated lists.
+func DefaultEnvironment() Environment {
+	env := Environment{
+		LookupEnv: os.LookupEnv,
+		Regions:   splitList(os.Getenv(regionsEnvVar)),
+		CheckEgress: func() error {
+			// The result is cached since every scenario
+			// would otherwise perform the same check.
+			egressOnce.Do(func() {
+				egressErr = checkEgress(egressCheckURL)
+			})
+			return egressErr
+		},
+	}
+
+	for _, launchType := range splitList(os.Getenv(launchTypesEnvVar)) {
+		env.LaunchTypes = append(env.LaunchTypes, LaunchType(strings.ToUpper(launchType)))
+	}
+
+	return env
+}
+
+// PrerequisiteError is returned when a scenario's
+// prerequisites are not met by the environment.
+type PrerequisiteError struct {
+	Scenario string
+	Missing  []string
+}
+
+func (e *PrerequisiteError) Error() string {
+	return fmt.Sprintf("scenario %s has unmet prerequisites:\n  - %s", e.Scenario, strings.Join(e.Missing, "\n  - "))
+}
+
+// check returns the list of all the prerequisites
+// that the environment does not satisfy.
+func (p Prerequisites) check(env Environment) []string {
+	var missing []string
+
+	lookupEnv := func(name string) bool {
+		if env.LookupEnv == nil {
+			return false
+		}
+		v, ok := env.LookupEnv(name)
+		return ok && v != ""
+	}
+
+	for _, name := range p.EnvVars {
+		if !lookupEnv(name) {
+			missing = append(missing, fmt.Sprintf("environment variable %s must be set", name))
+		}
+	}
+
+	if p.Enterprise && !lookupEnv(enterpriseLicenseEnvVar) {
+		missing = append(missing, fmt.Sprintf("enterprise scenario requires %s to be set", enterpriseLicenseEnvVar))
+	}
+
+	if p.LaunchType != "" && len(env.LaunchTypes) > 0 && !slices.Contains(env.LaunchTypes, p.LaunchType) {
+		missing = append(missing, fmt.Sprintf("launch type %s is not one of the allowed launch types %v", p.LaunchType, env.LaunchTypes))
+	}
+
+	if p.Region != "" && len(env.Regions) > 0 && !slices.Contains(env.Regions, p.Region) {
+		missing = append(missing, fmt.Sprintf("region %s is not one of the allowed regions %v", p.Region, env.Regions))
+	}
+
+	if p.Region != "" && len(p.SupportedRegions) > 0 && !slices.Contains(p.SupportedRegions, p.Region) {
+		missing = append(missing, fmt.Sprintf("region %s is not one of the supported regions %v, add one of them to the region pool", p.Region, p.SupportedRegions))
+	}
+
+	if p.NetworkEgress {
+		if env.CheckEgress == nil {
+			missing = append(missing, "network egress is required but cannot be verified")
+		} else if err := env.CheckEgress(); err != nil {
+			missing = append(missing, fmt.Sprintf("network egress is required: %s", err))
+		}
+	}
+
+	return missing
+}
+
+func checkEgress(url string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -3,18 +3,41 @@
 
 package scenarios
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
 
 type scenarioName string
 
 type registry struct {
 	scenarios map[scenarioName]ScenarioRegistration
+	env       Environment
 }
 
-func NewScenarioRegistry() ScenarioRegistry {
-	return &registry{
+type RegistryOpts func(*registry)
+
+// WithEnvironment overrides the environment that
+// scenario prerequisites are checked against.
+func WithEnvironment(env Environment) RegistryOpts {
+	return func(r *registry) {
+		r.env = env
+	}
+}
+
+func NewScenarioRegistry(opts ...RegistryOpts) ScenarioRegistry {
+	r := &registry{
 		scenarios: make(map[scenarioName]ScenarioRegistration),
+		env:       DefaultEnvironment(),
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
 func (s *registry) Register(reg ScenarioRegistration) {
@@ -35,5 +58,71 @@ func (s *registry) Retrieve(name string) (ScenarioRegistration, error) {
 		return ScenarioRegistration{}, fmt.Errorf("scenario %s is not registered", name)
 	}
 
+	if missing := scenario.Prerequisites.check(s.env); len(missing) > 0 {
+		return ScenarioRegistration{}, &PrerequisiteError{Scenario: name, Missing: missing}
+	}
+
 	return scenario, nil
 }
+
+func (s *registry) List() []ScenarioRegistration {
+	scenarios := make([]ScenarioRegistration, 0, len(s.scenarios))
+	for _, scenario := range s.scenarios {
+		scenarios = append(scenarios, scenario)
+	}
+
+	sort.Slice(scenarios, func(i, j int) bool {
+		return scenarios[i].Name < scenarios[j].Name
+	})
+	return scenarios
+}
+
+func (s *registry) Filter(expr string) ([]ScenarioRegistration, error) {
+	var (
+		entries  []string
+		patterns []*regexp.Regexp
+	)
+	for _, entry := range strings.Split(expr, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// Anchor the expression so that a plain scenario name
+		// does not match other scenarios that share its prefix.
+		pattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", entry))
+		if err != nil {
+			return nil, fmt.Errorf("invalid scenario expression %q: %w", entry, err)
+		}
+		entries = append(entries, entry)
+		patterns = append(patterns, pattern)
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("scenario expression cannot be empty")
+	}
+
+	var filtered []ScenarioRegistration
+	matched := make([]bool, len(patterns))
+	for _, scenario := range s.List() {
+		include := false
+		for i, pattern := range patterns {
+			if pattern.MatchString(scenario.Name) {
+				matched[i] = true
+				include = true
+			}
+		}
+
+		if include {
+			filtered = append(filtered, scenario)
+		}
+	}
+
+	for i, ok := range matched {
+		if !ok {
+			return nil, fmt.Errorf("no registered scenario matches %q", entries[i])
+		}
+	}
+
+	return filtered, nil
+}
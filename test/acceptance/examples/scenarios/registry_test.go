@@ -4,6 +4,7 @@
 package scenarios
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -57,9 +58,61 @@ func TestRegistry(t *testing.T) {
 			registerScenario: true,
 			shouldPanic:      true,
 		},
+		"invalid scenario launch type": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.Prerequisites.LaunchType = "EXTERNAL"
+				return sr
+			},
+			registerScenario: true,
+			shouldPanic:      true,
+		},
+		"invalid scenario with both phases and hooks": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.Phases = getTestPhases("apply")
+				return sr
+			},
+			registerScenario: true,
+			shouldPanic:      true,
+		},
+		"invalid scenario phase name": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.TerraformInputVars, sr.Validate = nil, nil
+				sr.Phases = getTestPhases("apply", "")
+				return sr
+			},
+			registerScenario: true,
+			shouldPanic:      true,
+		},
+		"duplicate scenario phase names": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.TerraformInputVars, sr.Validate = nil, nil
+				sr.Phases = getTestPhases("apply", "apply")
+				return sr
+			},
+			registerScenario: true,
+			shouldPanic:      true,
+		},
+		"invalid scenario phase Validate hook": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.TerraformInputVars, sr.Validate = nil, nil
+				sr.Phases = getTestPhases("apply", "enable-acls")
+				sr.Phases[1].Validate = nil
+				return sr
+			},
+			registerScenario: true,
+			shouldPanic:      true,
+		},
 		"successful registration and retrieval": {
 			registerScenario: true,
 		},
+		"successful registration and retrieval with phases": {
+			mutateScenario: func(sr ScenarioRegistration) ScenarioRegistration {
+				sr.TerraformInputVars, sr.Validate = nil, nil
+				sr.Phases = getTestPhases("apply", "enable-acls")
+				return sr
+			},
+			registerScenario: true,
+		},
 	}
 
 	for name, c := range cases {
@@ -114,3 +167,191 @@ func getTestScenarioRegistrationPayload() ScenarioRegistration {
 		Validate: func(t *testing.T, b []byte) {},
 	}
 }
+
+func getTestPhases(names ...string) []Phase {
+	var phases []Phase
+	for _, name := range names {
+		phases = append(phases, Phase{
+			Name: name,
+			TerraformInputVars: func() (map[string]interface{}, error) {
+				return nil, nil
+			},
+			Validate: func(t *testing.T, b []byte) {},
+		})
+	}
+	return phases
+}
+
+func TestGetPhases(t *testing.T) {
+	payload := getTestScenarioRegistrationPayload()
+	phases := payload.GetPhases()
+	require.Len(t, phases, 1)
+	require.Equal(t, defaultPhaseName, phases[0].Name)
+	require.NotNil(t, phases[0].TerraformInputVars)
+	require.NotNil(t, phases[0].Validate)
+
+	payload.TerraformInputVars, payload.Validate = nil, nil
+	payload.Phases = getTestPhases("apply", "enable-acls")
+	phases = payload.GetPhases()
+	require.Len(t, phases, 2)
+	require.Equal(t, "apply", phases[0].Name)
+	require.Equal(t, "enable-acls", phases[1].Name)
+}
+
+func TestRegistryFilter(t *testing.T) {
+	registry := NewScenarioRegistry()
+	for _, name := range []string{"EC2", "EC2_TPROXY", "FARGATE", "TERMINATING_GATEWAY", "TERMINATING_GATEWAY_TLS"} {
+		payload := getTestScenarioRegistrationPayload()
+		payload.Name = name
+		registry.Register(payload)
+	}
+
+	cases := map[string]struct {
+		expr          string
+		expectedNames []string
+		errStr        string
+	}{
+		"single scenario name": {
+			expr:          "EC2",
+			expectedNames: []string{"EC2"},
+		},
+		"comma separated names": {
+			expr:          "FARGATE, EC2",
+			expectedNames: []string{"EC2", "FARGATE"},
+		},
+		"regex": {
+			expr:          "TERMINATING_GATEWAY.*",
+			expectedNames: []string{"TERMINATING_GATEWAY", "TERMINATING_GATEWAY_TLS"},
+		},
+		"overlapping entries": {
+			expr:          "EC2.*,EC2_TPROXY",
+			expectedNames: []string{"EC2", "EC2_TPROXY"},
+		},
+		"empty expression": {
+			expr:   " , ",
+			errStr: "scenario expression cannot be empty",
+		},
+		"invalid regex": {
+			expr:   "EC2(",
+			errStr: "invalid scenario expression",
+		},
+		"unknown scenario": {
+			expr:   "EC2,UNKNOWN",
+			errStr: `no registered scenario matches "UNKNOWN"`,
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			filtered, err := registry.Filter(c.expr)
+			if c.errStr != "" {
+				require.ErrorContains(t, err, c.errStr)
+				return
+			}
+
+			require.NoError(t, err)
+			var names []string
+			for _, s := range filtered {
+				names = append(names, s.Name)
+			}
+			require.Equal(t, c.expectedNames, names)
+		})
+	}
+}
+
+func TestRegistryList(t *testing.T) {
+	registry := NewScenarioRegistry()
+	for _, name := range []string{"WAN_FEDERATION", "API_GATEWAY", "HCP"} {
+		payload := getTestScenarioRegistrationPayload()
+		payload.Name = name
+		registry.Register(payload)
+	}
+
+	var names []string
+	for _, s := range registry.List() {
+		names = append(names, s.Name)
+	}
+	require.Equal(t, []string{"API_GATEWAY", "HCP", "WAN_FEDERATION"}, names)
+}
+
+func TestRegistryPrerequisites(t *testing.T) {
+	env := Environment{
+		LookupEnv: func(name string) (string, bool) {
+			switch name {
+			case "HCP_PROJECT_ID":
+				return "project", true
+			case "EMPTY":
+				return "", true
+			}
+			return "", false
+		},
+		Regions:     []string{"us-east-1", "us-east-2"},
+		LaunchTypes: []LaunchType{LaunchTypeFargate},
+		CheckEgress: func() error {
+			return fmt.Errorf("no route to host")
+		},
+	}
+
+	cases := map[string]struct {
+		prerequisites Prerequisites
+		missing       []string
+	}{
+		"no prerequisites": {},
+		"satisfied prerequisites": {
+			prerequisites: Prerequisites{
+				EnvVars:    []string{"HCP_PROJECT_ID"},
+				LaunchType: LaunchTypeFargate,
+				Region:     "us-east-2",
+			},
+		},
+		"unsupported region": {
+			prerequisites: Prerequisites{
+				Region:           "us-east-1",
+				SupportedRegions: []string{"us-west-2", "eu-west-1"},
+			},
+			missing: []string{
+				"region us-east-1 is not one of the supported regions [us-west-2 eu-west-1], add one of them to the region pool",
+			},
+		},
+		"every prerequisite missing": {
+			prerequisites: Prerequisites{
+				EnvVars:       []string{"EMPTY", "UNSET"},
+				Enterprise:    true,
+				LaunchType:    LaunchTypeEC2,
+				Region:        "us-west-2",
+				NetworkEgress: true,
+			},
+			missing: []string{
+				"environment variable EMPTY must be set",
+				"environment variable UNSET must be set",
+				"enterprise scenario requires CONSUL_LICENSE to be set",
+				"launch type EC2 is not one of the allowed launch types [FARGATE]",
+				"region us-west-2 is not one of the allowed regions [us-east-1 us-east-2]",
+				"network egress is required: no route to host",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			registry := NewScenarioRegistry(WithEnvironment(env))
+
+			payload := getTestScenarioRegistrationPayload()
+			payload.Prerequisites = c.prerequisites
+			registry.Register(payload)
+
+			_, err := registry.Retrieve(payload.Name)
+			if len(c.missing) == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var prerequisiteErr *PrerequisiteError
+			require.ErrorAs(t, err, &prerequisiteErr)
+			require.Equal(t, payload.Name, prerequisiteErr.Scenario)
+			require.Equal(t, c.missing, prerequisiteErr.Missing)
+		})
+	}
+}
@@ -8,18 +8,66 @@ import (
 	"testing"
 )
 
+// defaultPhaseName is the name of the single phase
+// of scenarios that are not registered with phases.
+const defaultPhaseName = "default"
+
 // ScenarioRegistry helps us interact with the
 // actual registry that holds details about the scenarios.
 type ScenarioRegistry interface {
 	// Register registers a scenario into the registry
 	Register(ScenarioRegistration)
 
-	// Retrieve retrieves a scenario from the registry
+	// Retrieve retrieves a scenario from the registry. It returns a
+	// *PrerequisiteError listing every unmet prerequisite if the
+	// environment cannot run the scenario.
 	Retrieve(name string) (ScenarioRegistration, error)
+
+	// List returns all the registered scenarios sorted by name
+	List() []ScenarioRegistration
+
+	// Filter returns the registered scenarios, sorted by name, that match
+	// the given expression. The expression is a comma separated list where
+	// each entry is either the exact name of a scenario or a regular expression
+	// that is matched against the complete scenario name.
+	Filter(expr string) ([]ScenarioRegistration, error)
 }
 
 type TerraformInputVarsHook func() (map[string]interface{}, error)
 type ValidateHook func(*testing.T, []byte)
+type PreApplyHook func(*testing.T)
+type PostApplyHook func(*testing.T, []byte)
+type PreDestroyHook func(*testing.T, []byte)
+type OnFailureHook func(*testing.T, []byte)
+type TrafficProbeHook func(*testing.T, []byte) ProbeTarget
+
+// ProbeTarget is the fake service app whose traffic is probed
+// while the scenario's deployment is being upgraded.
+type ProbeTarget struct {
+	// URL is the address of the client app's load balancer.
+	URL string
+
+	// ExpectedUpstream is the name of the upstream app that
+	// every request to the client app must reach.
+	ExpectedUpstream string
+}
+
+// Phase is a single step of a multi-phase scenario. Every phase applies
+// the example with its own input variables against the state left behind
+// by the previous phase and validates the resulting deployment.
+type Phase struct {
+	// The name of the phase. It must be unique within the scenario
+	// and is used to report the phase in which the scenario failed.
+	Name string
+
+	// List of TF variables that needs to be supplied to the example's
+	// terraform config in this phase. These replace the variables of
+	// the previous phase.
+	TerraformInputVars TerraformInputVarsHook
+
+	// Validate is the hook called after this phase's terraform apply succeeds.
+	Validate ValidateHook
+}
 
 // ScenarioRegistration is the struct we expect each individual
 // scenario to use and register themselves by providing valid
@@ -30,9 +78,26 @@ type ScenarioRegistration struct {
 	// to determine the scenario to run.
 	Name string
 
+	// DisplayName is the human readable name of the scenario, e.g. used to
+	// name its CI job. It defaults to Name.
+	DisplayName string
+
 	// The name of the example's folder under the `examples/` directory
 	FolderName string
 
+	// Prerequisites declares what the scenario needs from the environment.
+	// The registry verifies them when the scenario is retrieved.
+	Prerequisites Prerequisites
+
+	// Tags are free form labels used to group scenarios,
+	// e.g. to generate the CI matrix for a stage of jobs.
+	Tags []string
+
+	// TFOutputs is a value of the struct that the scenario decodes its
+	// terraform outputs into. It is used to verify the outputs of an
+	// existing deployment before validating it with -reuse-state.
+	TFOutputs any
+
 	// List of TF variables that needs to be supplied to the
 	// example's terraform config.
 	TerraformInputVars TerraformInputVarsHook
@@ -40,6 +105,38 @@ type ScenarioRegistration struct {
 	// Validate is the hook called when validations need to be performed on the deployment. This
 	// hook will only be called after a successful terraform apply.
 	Validate ValidateHook
+
+	// Phases is an ordered list of phases for scenarios that need to verify
+	// the deployment across a change, e.g. enabling ACLs or bumping image
+	// versions. It is used instead of TerraformInputVars and Validate. The
+	// PostApply hook is called after every phase's terraform apply.
+	Phases []Phase
+
+	// PreApply is an optional hook called once before the first terraform
+	// apply, ahead of TerraformInputVars.
+	PreApply PreApplyHook
+
+	// PostApply is an optional hook called after a successful terraform apply
+	// and before Validate. It is meant for setup steps such as waiting for
+	// the deployment to become ready.
+	PostApply PostApplyHook
+
+	// PreDestroy is an optional hook called right before terraform destroy.
+	// It is meant for cleaning up state that was created outside of terraform,
+	// such as Consul config entries written by the scenario. This hook will
+	// only be called if terraform apply succeeded.
+	PreDestroy PreDestroyHook
+
+	// OnFailure is an optional hook called when the scenario fails. It runs
+	// before PreDestroy and terraform destroy so that the scenario can collect
+	// diagnostics from the deployment. The terraform outputs are nil if the
+	// scenario failed before terraform apply succeeded.
+	OnFailure OnFailureHook
+
+	// TrafficProbe is an optional hook returning the app that is probed
+	// for the whole duration of a module upgrade test. When provided, the
+	// upgrade fails if any request through the app's load balancer fails.
+	TrafficProbe TrafficProbeHook
 }
 
 func (r *ScenarioRegistration) validate() error {
@@ -51,6 +148,16 @@ func (r *ScenarioRegistration) validate() error {
 		return fmt.Errorf("scenario %s should have a folder name associated to it", r.Name)
 	}
 
+	switch r.Prerequisites.LaunchType {
+	case "", LaunchTypeFargate, LaunchTypeEC2:
+	default:
+		return fmt.Errorf("scenario %s has an unsupported launch type %s", r.Name, r.Prerequisites.LaunchType)
+	}
+
+	if len(r.Phases) > 0 {
+		return r.validatePhases()
+	}
+
 	if r.TerraformInputVars == nil {
 		return fmt.Errorf("scenario %s should provide hooks for providing terraform input variables", r.Name)
 	}
@@ -61,3 +168,46 @@ func (r *ScenarioRegistration) validate() error {
 
 	return nil
 }
+
+func (r *ScenarioRegistration) validatePhases() error {
+	if r.TerraformInputVars != nil || r.Validate != nil {
+		return fmt.Errorf("scenario %s should provide either phases or the TerraformInputVars and Validate hooks", r.Name)
+	}
+
+	names := make(map[string]struct{}, len(r.Phases))
+	for i, phase := range r.Phases {
+		if phase.Name == "" {
+			return fmt.Errorf("scenario %s has a phase at index %d without a name", r.Name, i)
+		}
+
+		if _, ok := names[phase.Name]; ok {
+			return fmt.Errorf("scenario %s has more than one phase named %s", r.Name, phase.Name)
+		}
+		names[phase.Name] = struct{}{}
+
+		if phase.TerraformInputVars == nil {
+			return fmt.Errorf("scenario %s should provide hooks for providing terraform input variables in phase %s", r.Name, phase.Name)
+		}
+
+		if phase.Validate == nil {
+			return fmt.Errorf("scenario %s should provide hooks validating the deployment in phase %s", r.Name, phase.Name)
+		}
+	}
+
+	return nil
+}
+
+// GetPhases returns the ordered list of phases of the scenario. A scenario
+// registered with the TerraformInputVars and Validate hooks has a single
+// phase made of these hooks.
+func (r *ScenarioRegistration) GetPhases() []Phase {
+	if len(r.Phases) > 0 {
+		return r.Phases
+	}
+
+	return []Phase{{
+		Name:               defaultPhaseName,
+		TerraformInputVars: r.TerraformInputVars,
+		Validate:           r.Validate,
+	}}
+}
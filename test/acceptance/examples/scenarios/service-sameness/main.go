@@ -4,10 +4,8 @@
 package sameness
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"testing"
 	"time"
 
@@ -19,27 +17,23 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	awsRegion = "us-west-1"
-)
-
 type TFOutputs struct {
-	DC1ConsulServerAddr  string            `json:"dc1_server_url"`
-	DC1ConsulServerToken string            `json:"dc1_server_bootstrap_token"`
-	DC2ConsulServerAddr  string            `json:"dc2_server_url"`
-	DC2ConsulServerToken string            `json:"dc2_server_bootstrap_token"`
-	DC1DefaultPartition  *PartitionDetails `json:"dc1_default_partition_apps"`
-	DC1Part1Partition    *PartitionDetails `json:"dc1_part1_partition_apps"`
-	DC2DefaultPartition  *PartitionDetails `json:"dc2_default_partition_apps"`
+	DC1ConsulServerAddr  string            `json:"dc1_server_url" tfoutput:"required,url"`
+	DC1ConsulServerToken string            `json:"dc1_server_bootstrap_token" tfoutput:"required,sensitive"`
+	DC2ConsulServerAddr  string            `json:"dc2_server_url" tfoutput:"required,url"`
+	DC2ConsulServerToken string            `json:"dc2_server_bootstrap_token" tfoutput:"required,sensitive"`
+	DC1DefaultPartition  *PartitionDetails `json:"dc1_default_partition_apps" tfoutput:"required"`
+	DC1Part1Partition    *PartitionDetails `json:"dc1_part1_partition_apps" tfoutput:"required"`
+	DC2DefaultPartition  *PartitionDetails `json:"dc2_default_partition_apps" tfoutput:"required"`
 }
 
 type PartitionDetails struct {
 	Partition     string `json:"partition"`
 	Namespace     string `json:"namespace"`
-	ECSClusterARN string `json:"ecs_cluster_arn"`
+	ECSClusterARN string `json:"ecs_cluster_arn" tfoutput:"required"`
 	Region        string `json:"region"`
-	ClientApp     *App   `json:"client"`
-	ServerApp     *App   `json:"server"`
+	ClientApp     *App   `json:"client" tfoutput:"required"`
+	ServerApp     *App   `json:"server" tfoutput:"required"`
 }
 
 func (p *PartitionDetails) getClientAppName() string       { return p.ClientApp.Name }
@@ -49,68 +43,56 @@ func (p *PartitionDetails) getClientAppConsulName() string { return p.ClientApp.
 func (p *PartitionDetails) getServerAppConsulName() string { return p.ServerApp.ConsulName }
 
 type App struct {
-	Name       string `json:"name"`
+	Name       string `json:"name" tfoutput:"required"`
 	ConsulName string `json:"consul_service_name"`
-	LBAddr     string `json:"lb_address,omitempty"`
+	LBAddr     string `json:"lb_address,omitempty" tfoutput:"url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResName := common.GenerateRandomStr(4)
+	region := common.Region("SERVICE_SAMENESS")
+	tfResName := common.ResourceName(common.GenerateRandomStr(4))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "SERVICE_SAMENESS",
+		DisplayName:        "Service Sameness",
 		FolderName:         "service-sameness",
-		TerraformInputVars: getTerraformVars(tfResName),
-		Validate:           validate(tfResName),
+		TerraformInputVars: getTerraformVars(tfResName, region),
+		PostApply:          postApply(tfResName),
+		Validate:           validate(tfResName, region),
+		Prerequisites: scenarios.Prerequisites{
+			Enterprise:    true,
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"multi-cluster"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": awsRegion,
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
-		enterpriseLicense := os.Getenv("CONSUL_LICENSE")
-		if enterpriseLicense == "" {
-			return nil, fmt.Errorf("expected CONSUL_LICENSE to be non empty")
-		}
-		vars["consul_license"] = enterpriseLicense
+		vars["consul_license"] = os.Getenv("CONSUL_LICENSE")
 
 		return vars, nil
 	}
 }
 
-func validate(tfResName string) scenarios.ValidateHook {
+func postApply(tfResName string) scenarios.PostApplyHook {
 	return func(t *testing.T, data []byte) {
-		logger.Log(t, "Fetching required output terraform variables")
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
-
-		removeUISuffix := func(addr string) string {
-			return strings.TrimSuffix(addr, "/ui")
-		}
-		tfOutputs.DC1DefaultPartition.ClientApp.LBAddr = removeUISuffix(tfOutputs.DC1DefaultPartition.ClientApp.LBAddr)
-		tfOutputs.DC1Part1Partition.ClientApp.LBAddr = removeUISuffix(tfOutputs.DC1Part1Partition.ClientApp.LBAddr)
-		tfOutputs.DC2DefaultPartition.ClientApp.LBAddr = removeUISuffix(tfOutputs.DC2DefaultPartition.ClientApp.LBAddr)
-
-		logger.Log(t, "Setting up the Consul clients")
-		consulClientOne, err := common.SetupConsulClient(t, tfOutputs.DC1ConsulServerAddr, common.WithToken(tfOutputs.DC1ConsulServerToken))
-		require.NoError(t, err)
-
-		consulClientTwo, err := common.SetupConsulClient(t, tfOutputs.DC2ConsulServerAddr, common.WithToken(tfOutputs.DC2ConsulServerToken))
-		require.NoError(t, err)
-
-		logger.Log(t, "Setting up ECS Client")
-		ecsClient, err := common.NewECSClient(common.WithRegion(awsRegion))
-		require.NoError(t, err)
+		tfOutputs := getTFOutputs(t, data)
+		consulClientOne, consulClientTwo := setupConsulClients(t, tfOutputs)
 
 		ensureAppsReadiness(t, consulClientOne, tfOutputs.DC1DefaultPartition)
 		ensureAppsReadiness(t, consulClientOne, tfOutputs.DC1Part1Partition)
@@ -120,6 +102,17 @@ func validate(tfResName string) scenarios.ValidateHook {
 		consulClientOne.EnsureServiceReadiness(fmt.Sprintf("%s-dc1-default-mesh-gateway", tfResName), nil)
 		consulClientOne.EnsureServiceReadiness(fmt.Sprintf("%s-dc1-%s-mesh-gateway", tfResName, tfOutputs.DC1Part1Partition.Partition), &api.QueryOptions{Partition: tfOutputs.DC1Part1Partition.Partition})
 		consulClientTwo.EnsureServiceReadiness(fmt.Sprintf("%s-dc2-mesh-gateway", tfResName), nil)
+	}
+}
+
+func validate(tfResName, region string) scenarios.ValidateHook {
+	return func(t *testing.T, data []byte) {
+		tfOutputs := getTFOutputs(t, data)
+		consulClientOne, consulClientTwo := setupConsulClients(t, tfOutputs)
+
+		logger.Log(t, "Setting up ECS Client")
+		ecsClient, err := common.NewECSClient(common.WithRegion(region))
+		require.NoError(t, err)
 
 		// Begin actual validation
 		clusterAppsList := []*PartitionDetails{
@@ -215,6 +208,26 @@ func validate(tfResName string) scenarios.ValidateHook {
 	}
 }
 
+func getTFOutputs(t *testing.T, data []byte) *TFOutputs {
+	logger.Log(t, "Fetching required output terraform variables")
+	tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+	require.NoError(t, err)
+	logger.Log(t, "terraform outputs:", scenarios.RedactOutputs(tfOutputs))
+
+	return tfOutputs
+}
+
+func setupConsulClients(t *testing.T, tfOutputs *TFOutputs) (*common.ConsulClientWrapper, *common.ConsulClientWrapper) {
+	logger.Log(t, "Setting up the Consul clients")
+	consulClientOne, err := common.SetupConsulClient(t, tfOutputs.DC1ConsulServerAddr, common.WithToken(tfOutputs.DC1ConsulServerToken))
+	require.NoError(t, err)
+
+	consulClientTwo, err := common.SetupConsulClient(t, tfOutputs.DC2ConsulServerAddr, common.WithToken(tfOutputs.DC2ConsulServerToken))
+	require.NoError(t, err)
+
+	return consulClientOne, consulClientTwo
+}
+
 func ensureAppsReadiness(t *testing.T, consulClient *common.ConsulClientWrapper, partitionDetails *PartitionDetails) {
 	logger.Log(t, fmt.Sprintf("checking if apps in %s partition & %s namespace are registered in Consul", partitionDetails.Partition, partitionDetails.Namespace))
 
@@ -4,9 +4,7 @@
 package terminatinggatewaytls
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
@@ -16,34 +14,43 @@ import (
 )
 
 type TFOutputs struct {
-	ConsulServerLBAddr string `json:"consul_server_lb_address"`
-	ConsulServerToken  string `json:"consul_server_bootstrap_token"`
-	MeshClientLBAddr   string `json:"mesh_client_lb_address"`
+	ConsulServerLBAddr string `json:"consul_server_lb_address" tfoutput:"required,url"`
+	ConsulServerToken  string `json:"consul_server_bootstrap_token" tfoutput:"sensitive"`
+	MeshClientLBAddr   string `json:"mesh_client_lb_address" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResourcesName := fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6))
+	region := common.Region("TERMINATING_GATEWAY_TLS")
+	tfResourcesName := common.ResourceName(fmt.Sprintf("ecs-%s", common.GenerateRandomStr(6)))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "TERMINATING_GATEWAY_TLS",
+		DisplayName:        "Terminating Gateway TLS",
 		FolderName:         "terminating-gateway-tls",
-		TerraformInputVars: getTerraformVars(tfResourcesName),
+		TerraformInputVars: getTerraformVars(tfResourcesName, region),
 		Validate:           validate(tfResourcesName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"gateways"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-west-2",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -53,12 +60,11 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		consulServerLBAddr := tfOutputs.ConsulServerLBAddr
 		meshClientLBAddr := tfOutputs.MeshClientLBAddr
-		meshClientLBAddr = strings.TrimSuffix(meshClientLBAddr, "/ui")
 
 		logger.Log(t, "Setting up the Consul client")
 		consulClient, err := common.SetupConsulClient(t, consulServerLBAddr)
@@ -4,9 +4,7 @@
 package wan
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
@@ -16,35 +14,44 @@ import (
 )
 
 type TFOutputs struct {
-	DC1ConsulServerAddr string `json:"dc1_server_url"`
-	ConsulServerToken   string `json:"bootstrap_token"`
-	DC2ConsulServerAddr string `json:"dc2_server_url"`
-	MeshClientLBAddr    string `json:"client_lb_address"`
+	DC1ConsulServerAddr string `json:"dc1_server_url" tfoutput:"required,url"`
+	ConsulServerToken   string `json:"bootstrap_token" tfoutput:"required,sensitive"`
+	DC2ConsulServerAddr string `json:"dc2_server_url" tfoutput:"required,url"`
+	MeshClientLBAddr    string `json:"client_lb_address" tfoutput:"required,url"`
 }
 
 func RegisterScenario(r scenarios.ScenarioRegistry) {
-	tfResName := common.GenerateRandomStr(4)
+	region := common.Region("WAN_FEDERATION")
+	tfResName := common.ResourceName(common.GenerateRandomStr(4))
 
 	r.Register(scenarios.ScenarioRegistration{
 		Name:               "WAN_FEDERATION",
+		DisplayName:        "WAN Federation with Mesh gateways",
 		FolderName:         "mesh-gateways",
-		TerraformInputVars: getTerraformVars(tfResName),
+		TerraformInputVars: getTerraformVars(tfResName, region),
 		Validate:           validate(tfResName),
+		Prerequisites: scenarios.Prerequisites{
+			LaunchType:    scenarios.LaunchTypeFargate,
+			Region:        region,
+			NetworkEgress: true,
+		},
+		Tags:      []string{"multi-cluster"},
+		TFOutputs: TFOutputs{},
 	})
 }
 
-func getTerraformVars(tfResName string) scenarios.TerraformInputVarsHook {
+func getTerraformVars(tfResName, region string) scenarios.TerraformInputVarsHook {
 	return func() (map[string]interface{}, error) {
 		vars := map[string]interface{}{
-			"region": "us-east-1",
+			"region": region,
 			"name":   tfResName,
 		}
 
-		publicIP, err := common.GetPublicIP()
+		ingressIP, err := common.IngressIP(common.IPv4)
 		if err != nil {
 			return nil, err
 		}
-		vars["lb_ingress_ip"] = publicIP
+		vars["lb_ingress_ip"] = ingressIP
 
 		return vars, nil
 	}
@@ -54,9 +61,8 @@ func validate(tfResName string) scenarios.ValidateHook {
 	return func(t *testing.T, data []byte) {
 		logger.Log(t, "Fetching required output terraform variables")
 
-		var tfOutputs *TFOutputs
-		require.NoError(t, json.Unmarshal(data, &tfOutputs))
-		tfOutputs.MeshClientLBAddr = strings.TrimSuffix(tfOutputs.MeshClientLBAddr, "/ui")
+		tfOutputs, err := scenarios.DecodeOutputs[TFOutputs](data)
+		require.NoError(t, err)
 
 		logger.Log(t, "Setting up the Consul clients")
 		consulClientOne, err := common.SetupConsulClient(t, tfOutputs.DC1ConsulServerAddr, common.WithToken(tfOutputs.ConsulServerToken))
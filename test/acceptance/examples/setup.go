@@ -0,0 +1,42 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package examples
+
+import (
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios"
+	apigateway "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/api-gateway"
+	clusterpeering "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/cluster-peering"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/ec2"
+	ec2tproxy "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/ec2-tproxy"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/fargate"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/hcp"
+	localityawarerouting "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/locality-aware-routing"
+	sameness "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/service-sameness"
+	terminatinggateway "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway"
+	terminatinggatewaytls "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway-tls"
+	terminatinggatewaytproxy "github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/terminating-gateway-tproxy"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/examples/scenarios/wan-federation"
+)
+
+// SetupScenarios returns a registry holding every scenario. New
+// scenarios must be registered here to be picked up by the tests
+// and the scenario catalog.
+func SetupScenarios(opts ...scenarios.RegistryOpts) scenarios.ScenarioRegistry {
+	reg := scenarios.NewScenarioRegistry(opts...)
+
+	fargate.RegisterScenario(reg)
+	ec2.RegisterScenario(reg)
+	clusterpeering.RegisterScenario(reg)
+	hcp.RegisterScenario(reg)
+	sameness.RegisterScenario(reg)
+	wan.RegisterScenario(reg)
+	localityawarerouting.RegisterScenario(reg)
+	apigateway.RegisterScenario(reg)
+	terminatinggateway.RegisterScenario(reg)
+	terminatinggatewaytls.RegisterScenario(reg)
+	terminatinggatewaytproxy.RegisterScenario(reg)
+	ec2tproxy.RegisterScenario(reg)
+
+	return reg
+}
@@ -6,6 +6,8 @@ package config
 // TestConfig holds configuration for the test suite.
 type TestConfig struct {
 	NoCleanupOnFailure      bool
+	ReuseState              string
+	ReportDir               string
 	ECSClusterARNs          []string    `json:"ecs_cluster_arns"`
 	LaunchType              string      `json:"launch_type"`
 	PrivateSubnets          interface{} `json:"private_subnets"`
@@ -0,0 +1,222 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package configentries writes the Consul config entries of tests, such as
+// service intentions, and restores the previous entries when tests complete.
+package configentries
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// acceptedCondition is the status condition that Consul sets on API
+// gateways and routes once the entry has been validated and bound.
+const acceptedCondition = "Accepted"
+
+// Manager writes, deletes and reads the config entries of a test. Every
+// change is undone when the test completes.
+type Manager struct {
+	t      *testing.T
+	client *api.Client
+	policy consulwait.RetryPolicy
+}
+
+// NewManager returns a manager of the config entries of the test that waits
+// for Consul to apply the changes according to the retry policy.
+func NewManager(t *testing.T, client *api.Client, policy consulwait.RetryPolicy) *Manager {
+	return &Manager{t: t, client: client, policy: policy}
+}
+
+// Write writes a config entry of any kind, e.g. service-defaults,
+// proxy-defaults, service-resolver, service-router, service-splitter,
+// exported-services, sameness-group or gateway routes, in the partition
+// and namespace set in the entry. It then waits until Consul reports the
+// entry as applied. The entry that existed before is restored, or the
+// new entry deleted, when the test completes.
+func (m *Manager) Write(entry api.ConfigEntry) {
+	desc := describeConfigEntry(entry.GetKind(), entry.GetName(), entry.GetPartition(), entry.GetNamespace())
+	logger.Log(m.t, fmt.Sprintf("writing config entry %s", desc))
+
+	queryOpts := configEntryQueryOpts(entry.GetPartition(), entry.GetNamespace())
+	previous, err := m.read(entry.GetKind(), entry.GetName(), queryOpts)
+	require.NoError(m.t, err)
+
+	m.t.Cleanup(func() {
+		m.restore(entry.GetKind(), entry.GetName(), previous, queryOpts)
+	})
+
+	require.NoError(m.t, m.set(entry))
+
+	var previousIndex uint64
+	if previous != nil {
+		previousIndex = previous.GetModifyIndex()
+	}
+	require.NoError(m.t, m.waitForEntry(entry.GetKind(), entry.GetName(), queryOpts, previousIndex))
+}
+
+// Delete deletes a config entry and waits until Consul no
+// longer returns it. The entry is restored when the test completes.
+func (m *Manager) Delete(kind, name string, queryOpts *api.QueryOptions) {
+	if queryOpts == nil {
+		queryOpts = &api.QueryOptions{}
+	}
+	desc := describeConfigEntry(kind, name, queryOpts.Partition, queryOpts.Namespace)
+	logger.Log(m.t, fmt.Sprintf("deleting config entry %s", desc))
+
+	previous, err := m.read(kind, name, queryOpts)
+	require.NoError(m.t, err)
+	if previous == nil {
+		return
+	}
+
+	m.t.Cleanup(func() {
+		m.restore(kind, name, previous, queryOpts)
+	})
+
+	require.NoError(m.t, m.delete(kind, name, queryOpts))
+}
+
+// Read returns the config entry or nil if it does not exist.
+func (m *Manager) Read(kind, name string, queryOpts *api.QueryOptions) api.ConfigEntry {
+	if queryOpts == nil {
+		queryOpts = &api.QueryOptions{}
+	}
+	entry, err := m.read(kind, name, queryOpts)
+	require.NoError(m.t, err)
+	return entry
+}
+
+// restore writes back the entry that existed before the test
+// changed it, or deletes the entry if there was none.
+func (m *Manager) restore(kind, name string, previous api.ConfigEntry, queryOpts *api.QueryOptions) {
+	desc := describeConfigEntry(kind, name, queryOpts.Partition, queryOpts.Namespace)
+	if previous == nil {
+		logger.Log(m.t, fmt.Sprintf("cleaning up config entry %s", desc))
+		require.NoError(m.t, m.delete(kind, name, queryOpts))
+		return
+	}
+
+	logger.Log(m.t, fmt.Sprintf("restoring config entry %s", desc))
+	require.NoError(m.t, m.set(previous))
+}
+
+func (m *Manager) read(kind, name string, queryOpts *api.QueryOptions) (api.ConfigEntry, error) {
+	var entry api.ConfigEntry
+	err := consulwait.Wait(m.policy, fmt.Sprintf("config entry %s/%s to be read", kind, name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		var err error
+		entry, _, err = m.client.ConfigEntries().Get(kind, name, opts)
+		if err != nil && isNotFound(err) {
+			entry, err = nil, nil
+		}
+		return err == nil, nil, nil, err
+	})
+	return entry, err
+}
+
+func (m *Manager) set(entry api.ConfigEntry) error {
+	writeOpts := &api.WriteOptions{Partition: entry.GetPartition(), Namespace: entry.GetNamespace()}
+	return consulwait.Wait(m.policy, fmt.Sprintf("config entry %s/%s to be written", entry.GetKind(), entry.GetName()), nil, func(*api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		_, _, err := m.client.ConfigEntries().Set(entry, writeOpts)
+		return err == nil, nil, nil, err
+	})
+}
+
+func (m *Manager) delete(kind, name string, queryOpts *api.QueryOptions) error {
+	writeOpts := &api.WriteOptions{Partition: queryOpts.Partition, Namespace: queryOpts.Namespace}
+	return consulwait.Wait(m.policy, fmt.Sprintf("config entry %s/%s to be deleted", kind, name), queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		if _, err := m.client.ConfigEntries().Delete(kind, name, writeOpts); err != nil {
+			return false, nil, nil, err
+		}
+
+		_, meta, err := m.client.ConfigEntries().Get(kind, name, opts)
+		if err != nil && isNotFound(err) {
+			return true, nil, meta, nil
+		}
+		return false, consulwait.State("config entry still exists"), meta, err
+	})
+}
+
+// waitForEntry waits until the config entry is modified after
+// previousIndex and, for API gateways and routes, accepted by Consul.
+func (m *Manager) waitForEntry(kind, name string, queryOpts *api.QueryOptions, previousIndex uint64) error {
+	what := fmt.Sprintf("config entry %s to be applied", describeConfigEntry(kind, name, queryOpts.Partition, queryOpts.Namespace))
+	return consulwait.Wait(m.policy, what, queryOpts, func(opts *api.QueryOptions) (bool, fmt.Stringer, *api.QueryMeta, error) {
+		opts.RequireConsistent = true
+		entry, meta, err := m.client.ConfigEntries().Get(kind, name, opts)
+		if err != nil {
+			if isNotFound(err) {
+				return false, consulwait.State("config entry does not exist"), meta, nil
+			}
+			return false, nil, nil, err
+		}
+
+		if entry.GetModifyIndex() <= previousIndex {
+			return false, consulwait.State(fmt.Sprintf("config entry not modified since index %d", previousIndex)), meta, nil
+		}
+
+		status, ok := configEntryStatus(entry)
+		if !ok {
+			return true, nil, meta, nil
+		}
+		for _, condition := range status.Conditions {
+			if condition.Type == acceptedCondition && condition.Status == api.ConditionStatusTrue {
+				return true, nil, meta, nil
+			}
+		}
+		return false, describeConditions(status.Conditions), meta, nil
+	})
+}
+
+// configEntryStatus returns the status of the kinds
+// of config entries that Consul reports it for.
+func configEntryStatus(entry api.ConfigEntry) (api.ConfigEntryStatus, bool) {
+	switch e := entry.(type) {
+	case *api.APIGatewayConfigEntry:
+		return e.Status, true
+	case *api.HTTPRouteConfigEntry:
+		return e.Status, true
+	case *api.TCPRouteConfigEntry:
+		return e.Status, true
+	default:
+		return api.ConfigEntryStatus{}, false
+	}
+}
+
+func configEntryQueryOpts(partition, namespace string) *api.QueryOptions {
+	return &api.QueryOptions{Partition: partition, Namespace: namespace}
+}
+
+func describeConfigEntry(kind, name, partition, namespace string) string {
+	desc := fmt.Sprintf("%s/%s", kind, name)
+	if partition != "" || namespace != "" {
+		desc += fmt.Sprintf(" in %s/%s", partition, namespace)
+	}
+	return desc
+}
+
+func describeConditions(conditions []api.Condition) consulwait.State {
+	if len(conditions) == 0 {
+		return "no status conditions"
+	}
+
+	descs := make([]string, 0, len(conditions))
+	for _, c := range conditions {
+		descs = append(descs, fmt.Sprintf("%s=%s (%s: %s)", c.Type, c.Status, c.Reason, c.Message))
+	}
+	return consulwait.State("status conditions " + strings.Join(descs, ", "))
+}
+
+// isNotFound returns true if Consul responded that the resource does not exist.
+func isNotFound(err error) bool {
+	var statusErr api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
+}
@@ -0,0 +1,130 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package configentries
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeConfigEntries(t *testing.T) (*fakes.ConfigEntries, string) {
+	fake := fakes.NewConfigEntries()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server.URL
+}
+
+// newTestManager returns a manager whose cleanups run when
+// t completes, so that tests can check what they restored.
+func newTestManager(t *testing.T, addr string) *Manager {
+	return newManagerWithPolicy(t, addr, consulwait.RetryPolicy{
+		Timeout:     5 * time.Second,
+		InitialWait: 10 * time.Millisecond,
+	})
+}
+
+func newManagerWithPolicy(t *testing.T, addr string, policy consulwait.RetryPolicy) *Manager {
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	client, err := api.NewClient(cfg)
+	require.NoError(t, err)
+	return NewManager(t, client, policy)
+}
+
+func TestWriteConfigEntry(t *testing.T) {
+	cases := map[string]struct {
+		existing *api.ServiceConfigEntry
+	}{
+		"new entry is deleted": {},
+		"existing entry is restored": {
+			existing: &api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "server", Protocol: "tcp"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake, addr := newFakeConfigEntries(t)
+			m := newTestManager(t, addr)
+
+			if c.existing != nil {
+				_, _, err := m.client.ConfigEntries().Set(c.existing, nil)
+				require.NoError(t, err)
+			}
+
+			t.Run("write", func(t *testing.T) {
+				m := newTestManager(t, addr)
+				m.Write(&api.ServiceConfigEntry{Kind: api.ServiceDefaults, Name: "server", Protocol: "http"})
+
+				entry := m.Read(api.ServiceDefaults, "server", nil)
+				require.Equal(t, "http", entry.(*api.ServiceConfigEntry).Protocol)
+			})
+
+			entry := fake.Get(api.ServiceDefaults, "server")
+			if c.existing == nil {
+				require.Nil(t, entry)
+				return
+			}
+			require.Equal(t, "tcp", entry["Protocol"])
+		})
+	}
+}
+
+func TestDeleteConfigEntry(t *testing.T) {
+	fake, addr := newFakeConfigEntries(t)
+	m := newTestManager(t, addr)
+
+	_, _, err := m.client.ConfigEntries().Set(&api.ProxyConfigEntry{Kind: api.ProxyDefaults, Name: api.ProxyConfigGlobal}, nil)
+	require.NoError(t, err)
+
+	t.Run("delete", func(t *testing.T) {
+		m := newTestManager(t, addr)
+		m.Delete(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+		require.Nil(t, m.Read(api.ProxyDefaults, api.ProxyConfigGlobal, nil))
+
+		// Deleting an entry that does not exist is a no-op.
+		m.Delete(api.ServiceDefaults, "server", nil)
+	})
+
+	require.NotNil(t, fake.Get(api.ProxyDefaults, api.ProxyConfigGlobal))
+}
+
+func TestWriteConfigEntryWaitsForAccepted(t *testing.T) {
+	fake, addr := newFakeConfigEntries(t)
+	fake.AcceptAfter = 2
+
+	m := newTestManager(t, addr)
+
+	route := &api.HTTPRouteConfigEntry{
+		Kind:    api.HTTPRoute,
+		Name:    "server",
+		Parents: []api.ResourceReference{{Kind: api.APIGateway, Name: "api-gateway"}},
+	}
+	m.Write(route)
+
+	require.Equal(t, 3, fake.Reads(api.HTTPRoute, "server"))
+}
+
+func TestWaitForConfigEntryTimeout(t *testing.T) {
+	fake, addr := newFakeConfigEntries(t)
+	fake.AcceptAfter = 1000
+
+	m := newManagerWithPolicy(t, addr, consulwait.RetryPolicy{
+		Timeout:     200 * time.Millisecond,
+		InitialWait: 10 * time.Millisecond,
+	})
+
+	_, _, err := m.client.ConfigEntries().Set(&api.TCPRouteConfigEntry{Kind: api.TCPRoute, Name: "server"}, nil)
+	require.NoError(t, err)
+
+	err = m.waitForEntry(api.TCPRoute, "server", &api.QueryOptions{}, 0)
+	var timeoutErr *consulwait.TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, "status conditions Accepted=False (Pending: )", timeoutErr.LastState.String())
+}
@@ -0,0 +1,208 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package configentries
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// IntentionSource identifies the services that an intention applies to.
+// Name defaults to all the services of the source. At most one of
+// Partition, Peer and SamenessGroup can be set.
+type IntentionSource struct {
+	Name          string
+	Namespace     string
+	Partition     string
+	Peer          string
+	SamenessGroup string
+}
+
+func (s IntentionSource) String() string {
+	name := s.Name
+	if name == "" {
+		name = "*"
+	}
+	switch {
+	case s.Peer != "":
+		return fmt.Sprintf("peer %s/%s/%s", s.Peer, s.Namespace, name)
+	case s.SamenessGroup != "":
+		return fmt.Sprintf("sameness group %s/%s/%s", s.SamenessGroup, s.Namespace, name)
+	default:
+		return fmt.Sprintf("%s/%s/%s", s.Partition, s.Namespace, name)
+	}
+}
+
+// ServiceIntentions builds the service-intentions config entry of a
+// destination service. Sources are either allowed or denied as a whole,
+// or matched against L7 permissions, which require the destination to
+// use the http protocol.
+type ServiceIntentions struct {
+	Name      string
+	Partition string
+	Namespace string
+
+	sources []*api.SourceIntention
+	err     error
+}
+
+// NewServiceIntentions returns a builder of the intentions of the service.
+func NewServiceIntentions(name, partition, namespace string) *ServiceIntentions {
+	return &ServiceIntentions{Name: name, Partition: partition, Namespace: namespace}
+}
+
+// Allow allows all requests from the source.
+func (i *ServiceIntentions) Allow(src IntentionSource) *ServiceIntentions {
+	return i.addSource(src, &api.SourceIntention{Action: api.IntentionActionAllow})
+}
+
+// Deny denies all requests from the source.
+func (i *ServiceIntentions) Deny(src IntentionSource) *ServiceIntentions {
+	return i.addSource(src, &api.SourceIntention{Action: api.IntentionActionDeny})
+}
+
+// Permissions matches the requests from the source against the permissions,
+// in order, e.g. AllowHTTP(WithPathPrefix("/admin"), WithMethods("GET")).
+// Requests that match none of the permissions fall back to the default
+// intention policy.
+func (i *ServiceIntentions) Permissions(src IntentionSource, permissions ...*api.IntentionPermission) *ServiceIntentions {
+	if len(permissions) == 0 {
+		i.setErr(fmt.Errorf("intention from %s has no permissions", src))
+		return i
+	}
+	return i.addSource(src, &api.SourceIntention{Permissions: permissions})
+}
+
+func (i *ServiceIntentions) addSource(src IntentionSource, intention *api.SourceIntention) *ServiceIntentions {
+	set := 0
+	for _, field := range []string{src.Partition, src.Peer, src.SamenessGroup} {
+		if field != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		i.setErr(fmt.Errorf("intention source %s must set only one of partition, peer and sameness group", src))
+		return i
+	}
+
+	for _, existing := range i.sources {
+		if existing.Name == sourceName(src) && existing.Namespace == src.Namespace && existing.Partition == src.Partition &&
+			existing.Peer == src.Peer && existing.SamenessGroup == src.SamenessGroup {
+			i.setErr(fmt.Errorf("duplicate intention source %s", src))
+			return i
+		}
+	}
+
+	intention.Name = sourceName(src)
+	intention.Namespace = src.Namespace
+	intention.Partition = src.Partition
+	intention.Peer = src.Peer
+	intention.SamenessGroup = src.SamenessGroup
+	intention.Type = api.IntentionSourceConsul
+	i.sources = append(i.sources, intention)
+	return i
+}
+
+func (i *ServiceIntentions) setErr(err error) {
+	if i.err == nil {
+		i.err = err
+	}
+}
+
+// ConfigEntry returns the config entry holding the intentions, or the
+// first error made while building them.
+func (i *ServiceIntentions) ConfigEntry() (*api.ServiceIntentionsConfigEntry, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+	if len(i.sources) == 0 {
+		return nil, errors.New("service intentions have no sources")
+	}
+	return &api.ServiceIntentionsConfigEntry{
+		Kind:      api.ServiceIntentions,
+		Name:      i.Name,
+		Partition: i.Partition,
+		Namespace: i.Namespace,
+		Sources:   i.sources,
+	}, nil
+}
+
+func sourceName(src IntentionSource) string {
+	if src.Name == "" {
+		return "*"
+	}
+	return src.Name
+}
+
+// HTTPMatchOpt restricts the requests that an L7 permission matches.
+type HTTPMatchOpt func(*api.IntentionHTTPPermission)
+
+// AllowHTTP returns a permission allowing the requests that match all the options.
+func AllowHTTP(opts ...HTTPMatchOpt) *api.IntentionPermission {
+	return httpPermission(api.IntentionActionAllow, opts)
+}
+
+// DenyHTTP returns a permission denying the requests that match all the options.
+func DenyHTTP(opts ...HTTPMatchOpt) *api.IntentionPermission {
+	return httpPermission(api.IntentionActionDeny, opts)
+}
+
+func httpPermission(action api.IntentionAction, opts []HTTPMatchOpt) *api.IntentionPermission {
+	match := &api.IntentionHTTPPermission{}
+	for _, opt := range opts {
+		opt(match)
+	}
+	return &api.IntentionPermission{Action: action, HTTP: match}
+}
+
+func WithPathExact(path string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.PathExact = path
+	}
+}
+
+func WithPathPrefix(prefix string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.PathPrefix = prefix
+	}
+}
+
+func WithPathRegex(regex string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.PathRegex = regex
+	}
+}
+
+// WithMethods matches requests using any of the HTTP methods.
+func WithMethods(methods ...string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.Methods = append(p.Methods, methods...)
+	}
+}
+
+// WithHeader matches requests with the header set to the value.
+func WithHeader(name, value string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.Header = append(p.Header, api.IntentionHTTPHeaderPermission{Name: name, Exact: value})
+	}
+}
+
+// WithHeaderPresent matches requests with the header set to any value.
+func WithHeaderPresent(name string) HTTPMatchOpt {
+	return func(p *api.IntentionHTTPPermission) {
+		p.Header = append(p.Header, api.IntentionHTTPHeaderPermission{Name: name, Present: true})
+	}
+}
+
+// WriteServiceIntentions writes the intentions and waits until Consul
+// applied them. The intentions that existed before are restored, or the
+// new ones deleted, when the test completes.
+func (m *Manager) WriteServiceIntentions(intentions *ServiceIntentions) {
+	entry, err := intentions.ConfigEntry()
+	require.NoError(m.t, err)
+	m.Write(entry)
+}
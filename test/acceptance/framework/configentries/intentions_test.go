@@ -0,0 +1,108 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package configentries
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceIntentions(t *testing.T) {
+	client := IntentionSource{Name: "client", Partition: "part1", Namespace: "ns1"}
+
+	cases := map[string]struct {
+		intentions *ServiceIntentions
+		expected   []*api.SourceIntention
+		err        string
+	}{
+		"allow and deny": {
+			intentions: NewServiceIntentions("server", "part2", "ns2").
+				Allow(client).
+				Deny(IntentionSource{Namespace: "ns3"}),
+			expected: []*api.SourceIntention{
+				{Name: "client", Partition: "part1", Namespace: "ns1", Action: api.IntentionActionAllow, Type: api.IntentionSourceConsul},
+				{Name: "*", Namespace: "ns3", Action: api.IntentionActionDeny, Type: api.IntentionSourceConsul},
+			},
+		},
+		"L7 permissions": {
+			intentions: NewServiceIntentions("server", "part2", "ns2").
+				Permissions(client,
+					AllowHTTP(WithPathPrefix("/allowed"), WithMethods("GET", "HEAD")),
+					AllowHTTP(WithPathExact("/health"), WithHeader("x-debug", "1"), WithHeaderPresent("x-request-id")),
+					DenyHTTP(WithPathRegex("/.*")),
+				),
+			expected: []*api.SourceIntention{{
+				Name: "client", Partition: "part1", Namespace: "ns1", Type: api.IntentionSourceConsul,
+				Permissions: []*api.IntentionPermission{
+					{Action: api.IntentionActionAllow, HTTP: &api.IntentionHTTPPermission{PathPrefix: "/allowed", Methods: []string{"GET", "HEAD"}}},
+					{Action: api.IntentionActionAllow, HTTP: &api.IntentionHTTPPermission{
+						PathExact: "/health",
+						Header: []api.IntentionHTTPHeaderPermission{
+							{Name: "x-debug", Exact: "1"},
+							{Name: "x-request-id", Present: true},
+						},
+					}},
+					{Action: api.IntentionActionDeny, HTTP: &api.IntentionHTTPPermission{PathRegex: "/.*"}},
+				},
+			}},
+		},
+		"peer and sameness group sources": {
+			intentions: NewServiceIntentions("server", "", "default").
+				Allow(IntentionSource{Name: "client", Peer: "dc2", Namespace: "default"}).
+				Allow(IntentionSource{Name: "client", SamenessGroup: "group", Namespace: "default"}),
+			expected: []*api.SourceIntention{
+				{Name: "client", Peer: "dc2", Namespace: "default", Action: api.IntentionActionAllow, Type: api.IntentionSourceConsul},
+				{Name: "client", SamenessGroup: "group", Namespace: "default", Action: api.IntentionActionAllow, Type: api.IntentionSourceConsul},
+			},
+		},
+		"no sources": {
+			intentions: NewServiceIntentions("server", "", ""),
+			err:        "service intentions have no sources",
+		},
+		"no permissions": {
+			intentions: NewServiceIntentions("server", "", "").Permissions(client),
+			err:        "intention from part1/ns1/client has no permissions",
+		},
+		"peer and partition": {
+			intentions: NewServiceIntentions("server", "", "").Allow(IntentionSource{Name: "client", Partition: "part1", Peer: "dc2"}),
+			err:        "intention source peer dc2//client must set only one of partition, peer and sameness group",
+		},
+		"duplicate source": {
+			intentions: NewServiceIntentions("server", "", "").Allow(client).Deny(client),
+			err:        "duplicate intention source part1/ns1/client",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			entry, err := c.intentions.ConfigEntry()
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, api.ServiceIntentions, entry.Kind)
+			require.Equal(t, "server", entry.Name)
+			require.Equal(t, c.expected, entry.Sources)
+		})
+	}
+}
+
+func TestWriteServiceIntentions(t *testing.T) {
+	fake, addr := newFakeConfigEntries(t)
+
+	t.Run("write", func(t *testing.T) {
+		m := newTestManager(t, addr)
+		m.WriteServiceIntentions(NewServiceIntentions("server", "", "").
+			Permissions(IntentionSource{Name: "client"}, AllowHTTP(WithPathPrefix("/allowed"))))
+
+		entry := fake.Get(api.ServiceIntentions, "server")
+		require.NotNil(t, entry)
+		require.Len(t, entry["Sources"], 1)
+	})
+
+	require.Nil(t, fake.Get(api.ServiceIntentions, "server"))
+}
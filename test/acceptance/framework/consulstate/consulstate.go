@@ -0,0 +1,532 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package consulstate records the resources of a long-lived Consul cluster
+// and deletes the ones that tests created since, so that tests can share
+// the cluster.
+package consulstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+)
+
+// configEntryKinds are the kinds of config entries that are recorded, in the
+// order they are written back. They are deleted in the reverse order so that
+// entries are deleted before the ones they reference.
+var configEntryKinds = []string{
+	api.ProxyDefaults,
+	api.MeshConfig,
+	api.ServiceDefaults,
+	api.ServiceResolver,
+	api.ServiceSplitter,
+	api.ServiceRouter,
+	api.JWTProvider,
+	api.ServiceIntentions,
+	api.IngressGateway,
+	api.TerminatingGateway,
+	api.InlineCertificate,
+	api.FileSystemCertificate,
+	api.APIGateway,
+	api.HTTPRoute,
+	api.TCPRoute,
+	api.SamenessGroup,
+	api.ExportedServices,
+	api.RateLimitIPConfig,
+}
+
+// enterpriseConfigEntryKinds are the kinds that only Consul Enterprise supports.
+var enterpriseConfigEntryKinds = map[string]bool{
+	api.SamenessGroup:     true,
+	api.RateLimitIPConfig: true,
+}
+
+// Snapshot holds the resources that existed in a Consul cluster when it
+// was recorded. Intentions are recorded as service-intentions config entries.
+type Snapshot struct {
+	// Enterprise is true if the cluster runs Consul Enterprise. With
+	// Consul CE, the snapshot holds a single, unnamed, partition and
+	// namespace.
+	Enterprise bool
+
+	// ACLs is false if the ACL system of the cluster is disabled.
+	ACLs bool
+
+	// Peering is false if cluster peering is disabled on the servers,
+	// in which case peerings are neither recorded nor deleted.
+	Peering bool
+
+	Partitions map[string]PartitionState
+}
+
+// PartitionState holds the resources of an admin partition.
+type PartitionState struct {
+	Name       string
+	Peerings   map[string]struct{}
+	Namespaces map[string]NamespaceState
+}
+
+// NamespaceState holds the resources of a namespace.
+type NamespaceState struct {
+	Name         string
+	Tokens       map[string]struct{}
+	Policies     map[string]struct{}
+	Roles        map[string]struct{}
+	AuthMethods  map[string]struct{}
+	BindingRules map[string]struct{}
+
+	// ConfigEntries are keyed by configEntryKey.
+	ConfigEntries map[string]api.ConfigEntry
+}
+
+// Preserve records the state of the cluster and restores it when the
+// test completes.
+func Preserve(t *testing.T, client *api.Client) error {
+	snapshot, err := Record(t, client)
+	if err != nil {
+		return fmt.Errorf("failed to record Consul state: %w", err)
+	}
+	t.Cleanup(func() {
+		if err := snapshot.Restore(t, client); err != nil {
+			logger.Log(t, "failed to restore Consul state:", err)
+		}
+	})
+	return nil
+}
+
+// Record records the resources of the cluster that the client talks to.
+func Record(t *testing.T, client *api.Client) (*Snapshot, error) {
+	enterprise, err := isEnterprise(client)
+	if err != nil {
+		return nil, err
+	}
+	acls, err := aclsEnabled(client)
+	if err != nil {
+		return nil, err
+	}
+	peering, err := peeringEnabled(client)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Enterprise: enterprise, ACLs: acls, Peering: peering, Partitions: make(map[string]PartitionState)}
+	partitions, err := snapshot.listPartitions(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, partition := range partitions {
+		logger.Logf(t, "recording partition state for %s", displayName(partition))
+		partState := PartitionState{Name: partition, Peerings: map[string]struct{}{}, Namespaces: make(map[string]NamespaceState)}
+
+		if snapshot.Peering {
+			peerings, _, err := client.Peerings().List(context.Background(), &api.QueryOptions{Partition: partition})
+			if err != nil {
+				return nil, err
+			}
+			for _, peering := range peerings {
+				partState.Peerings[peering.Name] = struct{}{}
+			}
+		}
+
+		namespaces, err := snapshot.listNamespaces(client, partition)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range namespaces {
+			logger.Logf(t, "  recording namespace state for %s/%s", displayName(partition), displayName(namespace))
+			nsState, err := snapshot.recordNamespace(client, partition, namespace)
+			if err != nil {
+				return nil, err
+			}
+			partState.Namespaces[namespace] = nsState
+		}
+		snapshot.Partitions[partition] = partState
+	}
+	return snapshot, nil
+}
+
+func (s *Snapshot) recordNamespace(client *api.Client, partition, namespace string) (NamespaceState, error) {
+	opts := &api.QueryOptions{Partition: partition, Namespace: namespace}
+	state := NamespaceState{
+		Name:          namespace,
+		Tokens:        map[string]struct{}{},
+		Policies:      map[string]struct{}{},
+		Roles:         map[string]struct{}{},
+		AuthMethods:   map[string]struct{}{},
+		BindingRules:  map[string]struct{}{},
+		ConfigEntries: map[string]api.ConfigEntry{},
+	}
+
+	if s.ACLs {
+		for _, resource := range aclResources(client) {
+			ids, err := resource.list(opts)
+			if err != nil {
+				return NamespaceState{}, err
+			}
+			recorded := resource.recorded(&state)
+			for _, id := range ids {
+				recorded[id] = struct{}{}
+			}
+		}
+	}
+
+	for _, kind := range s.configEntryKinds() {
+		entries, err := listConfigEntries(client, kind, opts)
+		if err != nil {
+			return NamespaceState{}, err
+		}
+		for _, entry := range entries {
+			state.ConfigEntries[configEntryKey(entry.GetKind(), entry.GetName())] = entry
+		}
+	}
+	return state, nil
+}
+
+// Restore deletes the resources that were created since the snapshot was
+// recorded and writes back the config entries that were modified or deleted.
+// Deleted peerings and ACL resources cannot be restored.
+func (s *Snapshot) Restore(t *testing.T, client *api.Client) error {
+	partitions, err := s.listPartitions(client)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, partition := range partitions {
+		partState, existingPart := s.Partitions[partition]
+		// if the partition is not a pre-existing one, then delete it and continue.
+		if !existingPart {
+			logger.Logf(t, "deleting partition %s", partition)
+			if _, err := client.Partitions().Delete(context.Background(), partition, nil); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		errs = append(errs, s.restorePeerings(t, client, partState)...)
+
+		namespaces, err := s.listNamespaces(client, partition)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, namespace := range namespaces {
+			nsState, existingNS := partState.Namespaces[namespace]
+			// if the namespace is not a pre-existing one, then delete it and continue.
+			if !existingNS {
+				logger.Logf(t, "  deleting namespace %s/%s", partition, namespace)
+				if _, err := client.Namespaces().Delete(namespace, &api.WriteOptions{Partition: partition}); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			errs = append(errs, s.restoreNamespace(t, client, partition, nsState)...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Snapshot) restorePeerings(t *testing.T, client *api.Client, state PartitionState) []error {
+	if !s.Peering {
+		return nil
+	}
+	peerings, _, err := client.Peerings().List(context.Background(), &api.QueryOptions{Partition: state.Name})
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, peering := range peerings {
+		if _, existing := state.Peerings[peering.Name]; !existing {
+			logger.Logf(t, "  deleting peering %s from %s", peering.Name, displayName(state.Name))
+			if _, err := client.Peerings().Delete(context.Background(), peering.Name, &api.WriteOptions{Partition: state.Name}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+func (s *Snapshot) restoreNamespace(t *testing.T, client *api.Client, partition string, state NamespaceState) []error {
+	qopts := &api.QueryOptions{Partition: partition, Namespace: state.Name}
+	wopts := &api.WriteOptions{Partition: partition, Namespace: state.Name}
+	where := fmt.Sprintf("%s/%s", displayName(partition), displayName(state.Name))
+
+	var errs []error
+	kinds := s.configEntryKinds()
+	for i := len(kinds) - 1; i >= 0; i-- {
+		entries, err := listConfigEntries(client, kinds[i], qopts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, entry := range entries {
+			if _, existing := state.ConfigEntries[configEntryKey(entry.GetKind(), entry.GetName())]; !existing {
+				logger.Logf(t, "    deleting config entry %s/%s from %s", entry.GetKind(), entry.GetName(), where)
+				if _, err := client.ConfigEntries().Delete(entry.GetKind(), entry.GetName(), wopts); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	for _, kind := range kinds {
+		entries, err := listConfigEntries(client, kind, qopts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		current := make(map[string]api.ConfigEntry, len(entries))
+		for _, entry := range entries {
+			current[configEntryKey(entry.GetKind(), entry.GetName())] = entry
+		}
+		for key, entry := range state.ConfigEntries {
+			if entry.GetKind() != kind {
+				continue
+			}
+			if now, ok := current[key]; ok && now.GetModifyIndex() == entry.GetModifyIndex() {
+				continue
+			}
+			logger.Logf(t, "    restoring config entry %s in %s", key, where)
+			if _, _, err := client.ConfigEntries().Set(entry, wopts); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if !s.ACLs {
+		return errs
+	}
+	// Binding rules and roles are deleted before the
+	// auth methods and policies that they reference.
+	resources := aclResources(client)
+	for i := len(resources) - 1; i >= 0; i-- {
+		resource := resources[i]
+		ids, err := resource.list(qopts)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recorded := resource.recorded(&state)
+		for _, id := range ids {
+			if _, existing := recorded[id]; !existing {
+				logger.Logf(t, "    deleting %s %s from %s", resource.name, id, where)
+				if err := resource.delete(id, wopts); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// aclResource lists and deletes the ACL resources of a type by ID.
+type aclResource struct {
+	name     string
+	list     func(*api.QueryOptions) ([]string, error)
+	delete   func(string, *api.WriteOptions) error
+	recorded func(*NamespaceState) map[string]struct{}
+}
+
+func aclResources(client *api.Client) []aclResource {
+	acl := client.ACL()
+	return []aclResource{
+		{
+			name: "policy",
+			list: func(opts *api.QueryOptions) ([]string, error) {
+				policies, _, err := acl.PolicyList(opts)
+				ids := make([]string, 0, len(policies))
+				for _, p := range policies {
+					ids = append(ids, p.ID)
+				}
+				return ids, err
+			},
+			delete: func(id string, opts *api.WriteOptions) error {
+				_, err := acl.PolicyDelete(id, opts)
+				return err
+			},
+			recorded: func(s *NamespaceState) map[string]struct{} { return s.Policies },
+		},
+		{
+			name: "auth method",
+			list: func(opts *api.QueryOptions) ([]string, error) {
+				methods, _, err := acl.AuthMethodList(opts)
+				ids := make([]string, 0, len(methods))
+				for _, m := range methods {
+					ids = append(ids, m.Name)
+				}
+				return ids, err
+			},
+			delete: func(id string, opts *api.WriteOptions) error {
+				_, err := acl.AuthMethodDelete(id, opts)
+				return err
+			},
+			recorded: func(s *NamespaceState) map[string]struct{} { return s.AuthMethods },
+		},
+		{
+			name: "role",
+			list: func(opts *api.QueryOptions) ([]string, error) {
+				roles, _, err := acl.RoleList(opts)
+				ids := make([]string, 0, len(roles))
+				for _, r := range roles {
+					ids = append(ids, r.ID)
+				}
+				return ids, err
+			},
+			delete: func(id string, opts *api.WriteOptions) error {
+				_, err := acl.RoleDelete(id, opts)
+				return err
+			},
+			recorded: func(s *NamespaceState) map[string]struct{} { return s.Roles },
+		},
+		{
+			name: "binding rule",
+			list: func(opts *api.QueryOptions) ([]string, error) {
+				rules, _, err := acl.BindingRuleList("", opts)
+				ids := make([]string, 0, len(rules))
+				for _, r := range rules {
+					ids = append(ids, r.ID)
+				}
+				return ids, err
+			},
+			delete: func(id string, opts *api.WriteOptions) error {
+				_, err := acl.BindingRuleDelete(id, opts)
+				return err
+			},
+			recorded: func(s *NamespaceState) map[string]struct{} { return s.BindingRules },
+		},
+		{
+			name: "token",
+			list: func(opts *api.QueryOptions) ([]string, error) {
+				tokens, _, err := acl.TokenList(opts)
+				ids := make([]string, 0, len(tokens))
+				for _, tok := range tokens {
+					ids = append(ids, tok.AccessorID)
+				}
+				return ids, err
+			},
+			delete: func(id string, opts *api.WriteOptions) error {
+				_, err := acl.TokenDelete(id, opts)
+				return err
+			},
+			recorded: func(s *NamespaceState) map[string]struct{} { return s.Tokens },
+		},
+	}
+}
+
+func (s *Snapshot) listPartitions(client *api.Client) ([]string, error) {
+	if !s.Enterprise {
+		return []string{""}, nil
+	}
+	partitions, _, err := client.Partitions().List(context.Background(), nil)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		names = append(names, p.Name)
+	}
+	return names, nil
+}
+
+func (s *Snapshot) listNamespaces(client *api.Client, partition string) ([]string, error) {
+	if !s.Enterprise {
+		return []string{""}, nil
+	}
+	namespaces, _, err := client.Namespaces().List(&api.QueryOptions{Partition: partition})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		names = append(names, ns.Name)
+	}
+	return names, nil
+}
+
+func (s *Snapshot) configEntryKinds() []string {
+	if s.Enterprise {
+		return configEntryKinds
+	}
+	kinds := make([]string, 0, len(configEntryKinds))
+	for _, kind := range configEntryKinds {
+		if !enterpriseConfigEntryKinds[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// listConfigEntries lists the config entries of the kind, treating
+// kinds that the version of the servers does not know as empty.
+func listConfigEntries(client *api.Client, kind string, opts *api.QueryOptions) ([]api.ConfigEntry, error) {
+	entries, _, err := client.ConfigEntries().List(kind, opts)
+	if isStatus(err, http.StatusBadRequest) && strings.Contains(err.Error(), "invalid config entry kind") {
+		return nil, nil
+	}
+	return entries, err
+}
+
+// isEnterprise detects the edition of Consul from the admin partitions
+// endpoint, which Consul CE does not serve.
+func isEnterprise(client *api.Client) (bool, error) {
+	_, _, err := client.Partitions().List(context.Background(), nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case isStatus(err, http.StatusNotFound):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to detect the Consul edition: %w", err)
+	}
+}
+
+// peeringEnabled detects whether cluster peering is enabled from the
+// peerings endpoint, which fails when peering is disabled in the config
+// of the servers and is not served by versions of Consul before 1.13.
+func peeringEnabled(client *api.Client) (bool, error) {
+	_, _, err := client.Peerings().List(context.Background(), nil)
+	switch {
+	case err == nil:
+		return true, nil
+	case isStatus(err, http.StatusNotFound) || strings.Contains(err.Error(), "peering must be enabled"):
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to detect whether peering is enabled: %w", err)
+	}
+}
+
+func aclsEnabled(client *api.Client) (bool, error) {
+	_, _, err := client.ACL().TokenReadSelf(nil)
+	if err != nil && strings.Contains(err.Error(), "ACL support disabled") {
+		return false, nil
+	}
+	if err != nil && !isStatus(err, http.StatusForbidden) {
+		return false, fmt.Errorf("failed to detect whether ACLs are enabled: %w", err)
+	}
+	return true, nil
+}
+
+func isStatus(err error, code int) bool {
+	var statusErr api.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == code
+}
+
+func configEntryKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// displayName names the unnamed partition and namespace of Consul CE.
+func displayName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
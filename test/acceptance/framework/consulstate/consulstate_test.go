@@ -0,0 +1,283 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package consulstate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCollection is a type of resource served by fakeConsul.
+type fakeCollection struct {
+	listPath string
+	itemPath string
+	idField  string
+}
+
+var (
+	partitions   = fakeCollection{"/v1/partitions", "/v1/partition/", "Name"}
+	namespaces   = fakeCollection{"/v1/namespaces", "/v1/namespace/", "Name"}
+	tokens       = fakeCollection{"/v1/acl/tokens", "/v1/acl/token/", "AccessorID"}
+	policies     = fakeCollection{"/v1/acl/policies", "/v1/acl/policy/", "ID"}
+	roles        = fakeCollection{"/v1/acl/roles", "/v1/acl/role/", "ID"}
+	authMethods  = fakeCollection{"/v1/acl/auth-methods", "/v1/acl/auth-method/", "Name"}
+	bindingRules = fakeCollection{"/v1/acl/binding-rules", "/v1/acl/binding-rule/", "ID"}
+	peerings     = fakeCollection{"/v1/peerings", "/v1/peering/", "Name"}
+)
+
+func configEntries(kind string) fakeCollection {
+	return fakeCollection{"/v1/config/" + kind, "/v1/config/" + kind + "/", "Name"}
+}
+
+var fakeCollections = append([]fakeCollection{partitions, namespaces, tokens, policies, roles, authMethods, bindingRules, peerings},
+	func() []fakeCollection {
+		var c []fakeCollection
+		for _, kind := range configEntryKinds {
+			c = append(c, configEntries(kind))
+		}
+		return c
+	}()...)
+
+type fakeScope struct {
+	collection fakeCollection
+	partition  string
+	namespace  string
+}
+
+// fakeConsul serves the list and delete endpoints of Consul resources,
+// scoped by the partition and namespace query parameters, and the
+// config entry write endpoint.
+type fakeConsul struct {
+	enterprise bool
+	acls       bool
+
+	// peeringDisabled fails the requests to the peering endpoints
+	// like servers whose config disables peering.
+	peeringDisabled bool
+
+	mu        sync.Mutex
+	index     uint64
+	resources map[fakeScope]map[string]map[string]any
+}
+
+func newFakeConsul(t *testing.T, enterprise, acls bool) (*fakeConsul, *api.Client) {
+	f := &fakeConsul{enterprise: enterprise, acls: acls, index: 1, resources: make(map[fakeScope]map[string]map[string]any)}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	return f, client
+}
+
+func (f *fakeConsul) scope(c fakeCollection, partition, namespace string) fakeScope {
+	switch c {
+	case partitions:
+		return fakeScope{collection: c}
+	case namespaces, peerings:
+		return fakeScope{collection: c, partition: partition}
+	}
+	return fakeScope{collection: c, partition: partition, namespace: namespace}
+}
+
+func (f *fakeConsul) add(c fakeCollection, partition, namespace string, resource map[string]any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	scope := f.scope(c, partition, namespace)
+	if f.resources[scope] == nil {
+		f.resources[scope] = make(map[string]map[string]any)
+	}
+	f.index++
+	resource["ModifyIndex"] = f.index
+	f.resources[scope][resource[c.idField].(string)] = resource
+}
+
+func (f *fakeConsul) ids(c fakeCollection, partition, namespace string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for id := range f.resources[f.scope(c, partition, namespace)] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (f *fakeConsul) get(c fakeCollection, partition, namespace, id string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resources[f.scope(c, partition, namespace)][id]
+}
+
+func (f *fakeConsul) serve(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	partition, namespace := query.Get("partition"), query.Get("ns")
+	if f.enterprise && partition == "" {
+		partition = "default"
+	}
+	if f.enterprise && namespace == "" {
+		namespace = "default"
+	}
+
+	switch path := req.URL.Path; {
+	case !f.enterprise && (strings.HasPrefix(path, "/v1/partition") || strings.HasPrefix(path, "/v1/namespace")):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case strings.HasPrefix(path, "/v1/acl/") && !f.acls:
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("ACL support disabled"))
+		return
+	case strings.HasPrefix(path, "/v1/peering") && f.peeringDisabled:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("peering must be enabled in config"))
+		return
+	case path == "/v1/acl/token/self":
+		_, _ = w.Write([]byte(`{"AccessorID": "self"}`))
+		return
+	case path == "/v1/config" && req.Method == http.MethodPut:
+		var entry map[string]any
+		_ = json.NewDecoder(req.Body).Decode(&entry)
+		f.add(configEntries(strings.ToLower(entry["Kind"].(string))), partition, namespace, entry)
+		_, _ = w.Write([]byte("true"))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range fakeCollections {
+		scope := f.scope(c, partition, namespace)
+		switch {
+		case req.URL.Path == c.listPath && req.Method == http.MethodGet:
+			list := []map[string]any{}
+			for _, resource := range f.resources[scope] {
+				list = append(list, resource)
+			}
+			w.Header().Set("X-Consul-Index", "1")
+			_ = json.NewEncoder(w).Encode(list)
+			return
+		case strings.HasPrefix(req.URL.Path, c.itemPath) && req.Method == http.MethodDelete:
+			id := strings.TrimPrefix(req.URL.Path, c.itemPath)
+			if _, ok := f.resources[scope][id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.resources[scope], id)
+			_, _ = w.Write([]byte("true"))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write([]byte("invalid config entry kind"))
+}
+
+func TestRecordAndRestore(t *testing.T) {
+	cases := map[string]struct {
+		enterprise      bool
+		acls            bool
+		peeringDisabled bool
+		partition       string
+		namespace       string
+	}{
+		"CE":                   {partition: "", namespace: "", acls: true},
+		"CE without ACLs":      {partition: "", namespace: ""},
+		"CE without peering":   {partition: "", namespace: "", acls: true, peeringDisabled: true},
+		"Enterprise":           {enterprise: true, acls: true, partition: "default", namespace: "default"},
+		"Enterprise partition": {enterprise: true, acls: true, partition: "part1", namespace: "ns1"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake, client := newFakeConsul(t, c.enterprise, c.acls)
+			fake.peeringDisabled = c.peeringDisabled
+			part, ns := c.partition, c.namespace
+			if c.enterprise {
+				fake.add(partitions, "", "", map[string]any{"Name": "default"})
+				fake.add(namespaces, "default", "", map[string]any{"Name": "default"})
+				fake.add(partitions, "", "", map[string]any{"Name": "part1"})
+				fake.add(namespaces, "part1", "", map[string]any{"Name": "default"})
+				fake.add(namespaces, "part1", "", map[string]any{"Name": "ns1"})
+			}
+			if c.acls {
+				fake.add(tokens, part, ns, map[string]any{"AccessorID": "bootstrap"})
+				fake.add(policies, part, ns, map[string]any{"ID": "global-management"})
+				fake.add(authMethods, part, ns, map[string]any{"Name": "iam"})
+			}
+			fake.add(peerings, part, "", map[string]any{"Name": "dc2"})
+			fake.add(configEntries(api.ProxyDefaults), part, ns, map[string]any{"Kind": api.ProxyDefaults, "Name": api.ProxyConfigGlobal})
+			fake.add(configEntries(api.ServiceDefaults), part, ns, map[string]any{"Kind": api.ServiceDefaults, "Name": "server", "Protocol": "tcp"})
+			fake.add(configEntries(api.ServiceIntentions), part, ns, map[string]any{"Kind": api.ServiceIntentions, "Name": "server"})
+
+			snapshot, err := Record(t, client)
+			require.NoError(t, err)
+			require.Equal(t, c.enterprise, snapshot.Enterprise)
+			require.Equal(t, c.acls, snapshot.ACLs)
+			require.Equal(t, !c.peeringDisabled, snapshot.Peering)
+
+			// The test modifies the cluster.
+			if c.acls {
+				fake.add(tokens, part, ns, map[string]any{"AccessorID": "client"})
+				fake.add(policies, part, ns, map[string]any{"ID": "client-policy"})
+				fake.add(roles, part, ns, map[string]any{"ID": "client-role"})
+				fake.add(authMethods, part, ns, map[string]any{"Name": "iam-test"})
+				fake.add(bindingRules, part, ns, map[string]any{"ID": "client-rule"})
+			}
+			fake.add(peerings, part, "", map[string]any{"Name": "dc3"})
+			fake.add(configEntries(api.ServiceDefaults), part, ns, map[string]any{"Kind": api.ServiceDefaults, "Name": "server", "Protocol": "http"})
+			fake.add(configEntries(api.ServiceRouter), part, ns, map[string]any{"Kind": api.ServiceRouter, "Name": "server"})
+			fake.add(configEntries(api.HTTPRoute), part, ns, map[string]any{"Kind": api.HTTPRoute, "Name": "route"})
+			require.NoError(t, func() error {
+				_, err := client.ConfigEntries().Delete(api.ServiceIntentions, "server", &api.WriteOptions{Partition: part, Namespace: ns})
+				return err
+			}())
+			if c.enterprise {
+				fake.add(partitions, "", "", map[string]any{"Name": "part2"})
+				fake.add(namespaces, "part1", "", map[string]any{"Name": "ns2"})
+			}
+
+			require.NoError(t, snapshot.Restore(t, client))
+
+			if c.acls {
+				require.Equal(t, []string{"bootstrap"}, fake.ids(tokens, part, ns))
+				require.Equal(t, []string{"global-management"}, fake.ids(policies, part, ns))
+				require.Empty(t, fake.ids(roles, part, ns))
+				require.Equal(t, []string{"iam"}, fake.ids(authMethods, part, ns))
+				require.Empty(t, fake.ids(bindingRules, part, ns))
+			}
+			if c.peeringDisabled {
+				require.ElementsMatch(t, []string{"dc2", "dc3"}, fake.ids(peerings, part, ""))
+			} else {
+				require.Equal(t, []string{"dc2"}, fake.ids(peerings, part, ""))
+			}
+			require.Empty(t, fake.ids(configEntries(api.ServiceRouter), part, ns))
+			require.Empty(t, fake.ids(configEntries(api.HTTPRoute), part, ns))
+			require.Equal(t, "tcp", fake.get(configEntries(api.ServiceDefaults), part, ns, "server")["Protocol"])
+			require.NotNil(t, fake.get(configEntries(api.ServiceIntentions), part, ns, "server"))
+			require.NotNil(t, fake.get(configEntries(api.ProxyDefaults), part, ns, api.ProxyConfigGlobal))
+			if c.enterprise {
+				require.ElementsMatch(t, []string{"default", "part1"}, fake.ids(partitions, "", ""))
+				require.ElementsMatch(t, []string{"default", "ns1"}, fake.ids(namespaces, "part1", ""))
+			}
+		})
+	}
+}
+
+func TestRecordEditionDetectionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+	_, err = Record(t, client)
+	require.ErrorContains(t, err, "failed to detect the Consul edition")
+}
@@ -0,0 +1,257 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package consulstate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+)
+
+const (
+	// loginDescriptionPrefix starts the description of the tokens that
+	// Consul creates on login, followed by the login metadata as JSON.
+	loginDescriptionPrefix = "token created via login"
+
+	// The login metadata that consul-ecs sets to link tokens to ECS tasks.
+	taskIDMetaKey  = "consul.hashicorp.com/task-id"
+	clusterMetaKey = "consul.hashicorp.com/cluster"
+
+	defaultTokenLeakTimeout = 5 * time.Minute
+	tokenLeakCheckInterval  = 10 * time.Second
+)
+
+// LoginToken is an ACL token that an ECS task obtained by logging in with
+// an auth method. It is linked to the task by the login metadata.
+type LoginToken struct {
+	AccessorID        string
+	Partition         string
+	Namespace         string
+	AuthMethod        string
+	ServiceIdentities []string
+	TaskID            string
+	Cluster           string
+	CreateTime        time.Time
+}
+
+func (tok LoginToken) String() string {
+	return fmt.Sprintf("token %s (services %v, auth method %s) of task %s in %s/%s",
+		tok.AccessorID, tok.ServiceIdentities, tok.AuthMethod, tok.TaskID, displayName(tok.Partition), displayName(tok.Namespace))
+}
+
+// ListLoginTokens lists the tokens created by logins in all the partitions
+// and namespaces of the cluster.
+func ListLoginTokens(client *api.Client) ([]LoginToken, error) {
+	enterprise, err := isEnterprise(client)
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Enterprise: enterprise}
+	partitions, err := s.listPartitions(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []LoginToken
+	for _, partition := range partitions {
+		namespaces, err := s.listNamespaces(client, partition)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespace := range namespaces {
+			tokens, _, err := client.ACL().TokenList(&api.QueryOptions{Partition: partition, Namespace: namespace})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, loginTokens(partition, namespace, tokens)...)
+		}
+	}
+	return result, nil
+}
+
+// ParseLoginTokens returns the login tokens of the output of the token list
+// endpoint, e.g. of a curl run in the task of a Consul server that the tests
+// cannot reach. The tokens are assumed to be in the default partition and
+// namespace.
+func ParseLoginTokens(data []byte) ([]LoginToken, error) {
+	var tokens []*api.ACLTokenListEntry
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse the token list: %w", err)
+	}
+	return loginTokens("", "", tokens), nil
+}
+
+func loginTokens(partition, namespace string, tokens []*api.ACLTokenListEntry) []LoginToken {
+	var result []LoginToken
+	for _, tok := range tokens {
+		if tok.AuthMethod == "" {
+			continue
+		}
+		result = append(result, newLoginToken(partition, namespace, tok))
+	}
+	return result
+}
+
+func newLoginToken(partition, namespace string, tok *api.ACLTokenListEntry) LoginToken {
+	login := LoginToken{
+		AccessorID: tok.AccessorID,
+		Partition:  partition,
+		Namespace:  namespace,
+		AuthMethod: tok.AuthMethod,
+		CreateTime: tok.CreateTime,
+	}
+	for _, identity := range tok.ServiceIdentities {
+		login.ServiceIdentities = append(login.ServiceIdentities, identity.ServiceName)
+	}
+
+	var meta map[string]string
+	if metaJSON, ok := strings.CutPrefix(tok.Description, loginDescriptionPrefix+": "); ok {
+		_ = json.Unmarshal([]byte(metaJSON), &meta)
+	}
+	login.TaskID = meta[taskIDMetaKey]
+	login.Cluster = meta[clusterMetaKey]
+	return login
+}
+
+// LoginTokenLister lists the login tokens of a cluster.
+type LoginTokenLister func() ([]LoginToken, error)
+
+// TaskStopTimeFunc returns the time at which the task of the cluster
+// stopped, the zero time if it is still running, or ecsapi.ErrTaskNotFound.
+type TaskStopTimeFunc func(cluster, taskID string) (time.Time, error)
+
+// TokenLeakCheck checks that the login tokens of the tasks of the services
+// are deleted at most Grace after the tasks stopped.
+type TokenLeakCheck struct {
+	// Services are the names of the service identities of the checked tokens.
+	Services []string
+
+	Grace        time.Duration
+	TaskStopTime TaskStopTimeFunc
+
+	// Timeout bounds the time to wait for the tasks to stop and for
+	// the grace period to elapse. It defaults to five minutes.
+	Timeout time.Duration
+}
+
+// TokenLeak is a token that outlived its task by more than the grace period.
+type TokenLeak struct {
+	Token LoginToken
+
+	// StoppedAt is the time at which the task stopped,
+	// or the zero time if ECS no longer knows the task.
+	StoppedAt time.Time
+}
+
+func (l TokenLeak) String() string {
+	if l.StoppedAt.IsZero() {
+		return fmt.Sprintf("%s, which ECS no longer knows", l.Token)
+	}
+	return fmt.Sprintf("%s, which stopped at %s", l.Token, l.StoppedAt.Format(time.RFC3339))
+}
+
+// Wait waits until the tokens of the services are deleted or outlive their
+// task by more than the grace period. It returns an error listing the leaked
+// tokens, or the tokens that remain pending when the timeout elapses.
+func (c TokenLeakCheck) Wait(t *testing.T, client *api.Client) error {
+	return c.WaitWith(t, func() ([]LoginToken, error) {
+		return ListLoginTokens(client)
+	})
+}
+
+// WaitWith is like Wait but lists the tokens with list.
+func (c TokenLeakCheck) WaitWith(t *testing.T, list LoginTokenLister) error {
+	if len(c.Services) == 0 {
+		return errors.New("the token leak check has no services")
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultTokenLeakTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		tokens, err := list()
+		if err != nil {
+			return err
+		}
+		leaks, pending, err := c.classify(tokens, time.Now())
+		if err != nil {
+			return err
+		}
+		if len(leaks) > 0 {
+			return fmt.Errorf("%d tokens outlived their task by more than %s:\n%s", len(leaks), c.Grace, describeList(leaks))
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("timed out after %s waiting for %d tokens to be deleted:\n%s", timeout, len(pending), describeList(pending))
+		}
+		logger.Logf(t, "waiting for %d tokens of %v to be deleted", len(pending), c.Services)
+		time.Sleep(min(tokenLeakCheckInterval, time.Until(deadline)))
+	}
+}
+
+// classify returns the tokens of the services that leaked and the ones
+// whose task is still running or stopped less than the grace period ago.
+func (c TokenLeakCheck) classify(tokens []LoginToken, now time.Time) ([]TokenLeak, []LoginToken, error) {
+	services := make(map[string]bool, len(c.Services))
+	for _, name := range c.Services {
+		services[name] = true
+	}
+
+	var (
+		leaks   []TokenLeak
+		pending []LoginToken
+	)
+	for _, tok := range tokens {
+		if !tokenOfServices(tok, services) {
+			continue
+		}
+		if tok.TaskID == "" {
+			return nil, nil, fmt.Errorf("%s is not linked to an ECS task", tok)
+		}
+
+		stoppedAt, err := c.TaskStopTime(tok.Cluster, tok.TaskID)
+		switch {
+		case errors.Is(err, ecsapi.ErrTaskNotFound):
+			// ECS forgets tasks about an hour after they stopped.
+			leaks = append(leaks, TokenLeak{Token: tok})
+		case err != nil:
+			return nil, nil, fmt.Errorf("failed to get the status of task %s: %w", tok.TaskID, err)
+		case stoppedAt.IsZero() || now.Sub(stoppedAt) <= c.Grace:
+			pending = append(pending, tok)
+		default:
+			leaks = append(leaks, TokenLeak{Token: tok, StoppedAt: stoppedAt})
+		}
+	}
+	return leaks, pending, nil
+}
+
+func tokenOfServices(tok LoginToken, services map[string]bool) bool {
+	for _, name := range tok.ServiceIdentities {
+		if services[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func describeList[T fmt.Stringer](items []T) string {
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, "  - "+item.String())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
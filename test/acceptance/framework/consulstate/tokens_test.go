@@ -0,0 +1,121 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package consulstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
+	"github.com/stretchr/testify/require"
+)
+
+func loginToken(id, service, taskID string) map[string]any {
+	return map[string]any{
+		"AccessorID":        id,
+		"AuthMethod":        "iam-ecs-service-token",
+		"Description":       fmt.Sprintf(`token created via login: {"consul.hashicorp.com/cluster":"cluster","consul.hashicorp.com/task-id":%q}`, taskID),
+		"ServiceIdentities": []map[string]any{{"ServiceName": service}},
+		"CreateTime":        time.Now().Add(-time.Hour),
+	}
+}
+
+func TestListLoginTokens(t *testing.T) {
+	fake, client := newFakeConsul(t, true, true)
+	fake.add(partitions, "", "", map[string]any{"Name": "default"})
+	fake.add(partitions, "", "", map[string]any{"Name": "part1"})
+	fake.add(namespaces, "default", "", map[string]any{"Name": "default"})
+	fake.add(namespaces, "part1", "", map[string]any{"Name": "ns1"})
+	fake.add(tokens, "default", "default", map[string]any{"AccessorID": "bootstrap"})
+	fake.add(tokens, "part1", "ns1", loginToken("client-token", "client", "task1"))
+
+	result, err := ListLoginTokens(client)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t, "client-token", result[0].AccessorID)
+	require.Equal(t, "part1", result[0].Partition)
+	require.Equal(t, "ns1", result[0].Namespace)
+	require.Equal(t, []string{"client"}, result[0].ServiceIdentities)
+	require.Equal(t, "task1", result[0].TaskID)
+	require.Equal(t, "cluster", result[0].Cluster)
+}
+
+func TestParseLoginTokens(t *testing.T) {
+	data, err := json.Marshal([]map[string]any{{"AccessorID": "bootstrap"}, loginToken("client-token", "client", "task1")})
+	require.NoError(t, err)
+
+	result, err := ParseLoginTokens(data)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t, "client-token", result[0].AccessorID)
+	require.Equal(t, []string{"client"}, result[0].ServiceIdentities)
+	require.Equal(t, "task1", result[0].TaskID)
+
+	_, err = ParseLoginTokens([]byte("ACL not found"))
+	require.ErrorContains(t, err, "failed to parse the token list")
+}
+
+func TestTokenLeakCheckClassify(t *testing.T) {
+	now := time.Now()
+	stopTimes := map[string]time.Time{
+		"running":         {},
+		"stopped-recent":  now.Add(-30 * time.Second),
+		"stopped-earlier": now.Add(-5 * time.Minute),
+	}
+	check := TokenLeakCheck{
+		Services: []string{"client", "server"},
+		Grace:    time.Minute,
+		TaskStopTime: func(cluster, taskID string) (time.Time, error) {
+			require.Equal(t, "cluster", cluster)
+			stoppedAt, ok := stopTimes[taskID]
+			if !ok {
+				return time.Time{}, ecsapi.ErrTaskNotFound
+			}
+			return stoppedAt, nil
+		},
+	}
+
+	tokens := []LoginToken{
+		{AccessorID: "a", ServiceIdentities: []string{"client"}, TaskID: "running", Cluster: "cluster"},
+		{AccessorID: "b", ServiceIdentities: []string{"server"}, TaskID: "stopped-recent", Cluster: "cluster"},
+		{AccessorID: "c", ServiceIdentities: []string{"server"}, TaskID: "stopped-earlier", Cluster: "cluster"},
+		{AccessorID: "d", ServiceIdentities: []string{"client"}, TaskID: "forgotten", Cluster: "cluster"},
+		{AccessorID: "e", ServiceIdentities: []string{"other"}, TaskID: "stopped-earlier", Cluster: "cluster"},
+	}
+	leaks, pending, err := check.classify(tokens, now)
+	require.NoError(t, err)
+	require.Equal(t, []TokenLeak{
+		{Token: tokens[2], StoppedAt: stopTimes["stopped-earlier"]},
+		{Token: tokens[3]},
+	}, leaks)
+	require.Equal(t, []LoginToken{tokens[0], tokens[1]}, pending)
+
+	_, _, err = check.classify([]LoginToken{{AccessorID: "f", ServiceIdentities: []string{"client"}}}, now)
+	require.ErrorContains(t, err, "is not linked to an ECS task")
+}
+
+func TestTokenLeakCheckWait(t *testing.T) {
+	fake, client := newFakeConsul(t, false, true)
+	fake.add(tokens, "", "", loginToken("leaked", "client", "task1"))
+
+	stoppedAt := time.Now().Add(-10 * time.Minute)
+	check := TokenLeakCheck{
+		Services: []string{"client"},
+		Grace:    time.Minute,
+		TaskStopTime: func(string, string) (time.Time, error) {
+			return stoppedAt, nil
+		},
+	}
+	err := check.Wait(t, client)
+	require.ErrorContains(t, err, "1 tokens outlived their task by more than 1m0s")
+	require.ErrorContains(t, err, "token leaked (services [client], auth method iam-ecs-service-token) of task task1 in default/default")
+
+	_, err = client.ACL().TokenDelete("leaked", nil)
+	require.NoError(t, err)
+	require.NoError(t, check.Wait(t, client))
+
+	require.EqualError(t, TokenLeakCheck{}.Wait(t, client), "the token leak check has no services")
+}
@@ -0,0 +1,166 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package consulwait waits for the state of Consul to converge, using
+// blocking queries to react as soon as the state changes and a retry
+// policy with backoff for errors and queries that do not block.
+package consulwait
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// RetryPolicy controls how long and how often the state
+// of Consul is checked before giving up.
+type RetryPolicy struct {
+	// Timeout is the total time allowed for the state to converge.
+	Timeout time.Duration
+
+	// InitialWait is the time to wait before the first retry. It is
+	// multiplied by Multiplier after every attempt, up to MaxWait.
+	InitialWait time.Duration
+	MaxWait     time.Duration
+	Multiplier  float64
+
+	// Jitter is the fraction, between 0 and 1, of every wait that
+	// is randomized so that concurrent callers spread their requests.
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns the policy used unless another one is given.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Timeout:     3 * time.Minute,
+		InitialWait: time.Second,
+		MaxWait:     10 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// Backoff returns the time to wait before the given retry, starting at 0.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	wait := float64(p.InitialWait)
+	if p.Multiplier > 1 {
+		wait *= math.Pow(p.Multiplier, float64(attempt))
+	}
+	if p.MaxWait > 0 && wait > float64(p.MaxWait) {
+		wait = float64(p.MaxWait)
+	}
+
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		wait += wait * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// TimeoutError is returned when the state of Consul does not
+// converge before the retry policy's timeout elapses.
+type TimeoutError struct {
+	// What describes the state that was waited for.
+	What    string
+	Timeout time.Duration
+
+	// LastState is the last state observed before the timeout
+	// and LastErr the last error returned when querying it.
+	LastState fmt.Stringer
+	LastErr   error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timed out after %s waiting for %s", e.Timeout, e.What)
+	if e.LastState != nil {
+		msg += fmt.Sprintf(", last observed state: %s", e.LastState)
+	}
+	if e.LastErr != nil {
+		msg += fmt.Sprintf(", last error: %s", e.LastErr)
+	}
+	return msg
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.LastErr
+}
+
+// State is a plain description of the state of Consul.
+type State string
+
+func (s State) String() string {
+	return string(s)
+}
+
+// QueryFunc queries Consul using the given options and reports whether the
+// awaited state was reached along with a description of the observed state.
+type QueryFunc func(opts *api.QueryOptions) (done bool, state fmt.Stringer, meta *api.QueryMeta, err error)
+
+// Wait runs query until it reports that the awaited state was reached or
+// the policy's timeout elapses. Once a query returns an index, the
+// following ones are blocking queries that return as soon as the state
+// changes. Errors and queries that do not support blocking are retried
+// with the policy's backoff. The returned *TimeoutError holds the last
+// state observed.
+func Wait(policy RetryPolicy, what string, queryOpts *api.QueryOptions, query QueryFunc) error {
+	deadline := time.Now().Add(policy.Timeout)
+
+	var (
+		index     uint64
+		attempt   int
+		lastState fmt.Stringer
+		lastErr   error
+	)
+	for {
+		opts := &api.QueryOptions{}
+		if queryOpts != nil {
+			*opts = *queryOpts
+		}
+
+		remaining := time.Until(deadline)
+		if index > 0 && remaining > 0 {
+			opts.WaitIndex = index
+			opts.WaitTime = remaining
+		}
+
+		// Consul adds up to WaitTime/16 of jitter to blocking queries.
+		ctx, cancel := context.WithTimeout(context.Background(), remaining+remaining/16+5*time.Second)
+		done, state, meta, err := query(opts.WithContext(ctx))
+		cancel()
+
+		if err == nil {
+			if done {
+				return nil
+			}
+			lastState, lastErr = state, nil
+		} else {
+			lastErr = err
+		}
+
+		if !time.Now().Before(deadline) {
+			return &TimeoutError{What: what, Timeout: policy.Timeout, LastState: lastState, LastErr: lastErr}
+		}
+
+		if err == nil && meta != nil && meta.LastIndex > index {
+			index = meta.LastIndex
+			attempt = 0
+			continue
+		}
+
+		// The index going backwards means that the state was reset,
+		// e.g. after a snapshot restore, so start over without blocking.
+		if err == nil && meta != nil && meta.LastIndex < index {
+			index = 0
+		}
+
+		wait := policy.Backoff(attempt)
+		if remaining := time.Until(deadline); wait > remaining {
+			wait = remaining
+		}
+		time.Sleep(wait)
+		attempt++
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package consulwait
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialWait: time.Second, MaxWait: 10 * time.Second, Multiplier: 2}
+
+	var waits []time.Duration
+	for attempt := 0; attempt < 6; attempt++ {
+		waits = append(waits, policy.Backoff(attempt))
+	}
+	require.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, waits)
+
+	policy.Jitter = 0.5
+	for attempt := 0; attempt < 100; attempt++ {
+		wait := policy.Backoff(0)
+		require.GreaterOrEqual(t, wait, 500*time.Millisecond)
+		require.LessOrEqual(t, wait, 1500*time.Millisecond)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ecsapi calls the ECS API on behalf of the tests, the scenarios
+// and the other packages of the framework. It only depends on the AWS SDK
+// and on ecsexec, so that any package can use it.
+package ecsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+)
+
+const (
+	// describeTasksBatchSize is the maximum number of tasks
+	// that a DescribeTasks request accepts.
+	describeTasksBatchSize = 100
+
+	// defaultExecTimeout bounds the commands run with ECS Exec
+	// whose context has no deadline.
+	defaultExecTimeout = 2 * time.Minute
+)
+
+// ErrTaskNotFound is returned when ECS does not know a task,
+// e.g. because it stopped more than an hour ago.
+var ErrTaskNotFound = errors.New("task not found")
+
+// API is the part of the ECS API that the tests and the scenarios use.
+// It is implemented by *ecs.Client, and by mocks in unit tests.
+type API interface {
+	ListTasks(ctx context.Context, params *ecs.ListTasksInput, optFns ...func(*ecs.Options)) (*ecs.ListTasksOutput, error)
+	DescribeTasks(ctx context.Context, params *ecs.DescribeTasksInput, optFns ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error)
+	StopTask(ctx context.Context, params *ecs.StopTaskInput, optFns ...func(*ecs.Options)) (*ecs.StopTaskOutput, error)
+	UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error)
+	ExecuteCommand(ctx context.Context, params *ecs.ExecuteCommandInput, optFns ...func(*ecs.Options)) (*ecs.ExecuteCommandOutput, error)
+}
+
+// NewClient returns a client of the ECS API of the region, built from
+// the default AWS configuration. The region of the configuration, e.g.
+// from AWS_REGION, is used if region is empty.
+func NewClient(ctx context.Context, region string) (API, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+	return ecs.NewFromConfig(cfg), nil
+}
+
+// ListTasksFilter selects the tasks listed by ListTasks.
+type ListTasksFilter struct {
+	Family      string
+	ServiceName string
+
+	// DesiredStatus defaults to RUNNING, like in the ECS API.
+	DesiredStatus types.DesiredStatus
+}
+
+// ListTasks returns the ARNs of the tasks of the cluster that match the
+// filter, going through all the pages of the response.
+func ListTasks(ctx context.Context, client API, clusterARN string, filter ListTasksFilter) ([]string, error) {
+	input := &ecs.ListTasksInput{
+		Cluster:       aws.String(clusterARN),
+		DesiredStatus: filter.DesiredStatus,
+	}
+	if filter.Family != "" {
+		input.Family = aws.String(filter.Family)
+	}
+	if filter.ServiceName != "" {
+		input.ServiceName = aws.String(filter.ServiceName)
+	}
+
+	taskARNs := make([]string, 0)
+	paginator := ecs.NewListTasksPaginator(client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the tasks of %s: %w", clusterARN, err)
+		}
+		taskARNs = append(taskARNs, page.TaskArns...)
+	}
+	return taskARNs, nil
+}
+
+// DescribeTasks describes the tasks of the cluster, in batches of 100. Tasks
+// that ECS does not know are listed in the failures of the output.
+func DescribeTasks(ctx context.Context, client API, clusterARN string, taskARNs ...string) (*ecs.DescribeTasksOutput, error) {
+	out := &ecs.DescribeTasksOutput{}
+	for start := 0; start < len(taskARNs); start += describeTasksBatchSize {
+		batch := taskARNs[start:min(start+describeTasksBatchSize, len(taskARNs))]
+		res, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(clusterARN),
+			Tasks:   batch,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe the tasks %v of %s: %w", batch, clusterARN, err)
+		}
+		out.Tasks = append(out.Tasks, res.Tasks...)
+		out.Failures = append(out.Failures, res.Failures...)
+	}
+	return out, nil
+}
+
+// TaskStoppedAt returns the time at which the task stopped, the zero time if
+// it has not stopped yet, or ErrTaskNotFound if ECS does not know it.
+func TaskStoppedAt(ctx context.Context, client API, clusterARN, taskID string) (time.Time, error) {
+	tasks, err := DescribeTasks(ctx, client, clusterARN, taskID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(tasks.Tasks) == 0 {
+		return time.Time{}, fmt.Errorf("task %s: %w", taskID, ErrTaskNotFound)
+	}
+	if stoppedAt := tasks.Tasks[0].StoppedAt; stoppedAt != nil {
+		return *stoppedAt, nil
+	}
+	return time.Time{}, nil
+}
+
+// StopTask stops the task of the cluster with the reason.
+func StopTask(ctx context.Context, client API, clusterARN, taskARN, reason string) error {
+	_, err := client.StopTask(ctx, &ecs.StopTaskInput{
+		Cluster: aws.String(clusterARN),
+		Task:    aws.String(taskARN),
+		Reason:  aws.String(reason),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stop the task %s: %w", taskARN, err)
+	}
+	return nil
+}
+
+// UpdateServiceDesiredCount scales the service of the cluster to the
+// desired number of tasks.
+func UpdateServiceDesiredCount(ctx context.Context, client API, clusterARN, service string, desiredCount int32) error {
+	_, err := client.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster:      aws.String(clusterARN),
+		Service:      aws.String(service),
+		DesiredCount: aws.Int32(desiredCount),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the service %s: %w", service, err)
+	}
+	return nil
+}
+
+// Exec runs the command in the container of the task with ECS Exec and
+// returns its output and exit code. Unless ctx has a deadline, the command
+// is given two minutes to complete, including the start of the session.
+func Exec(ctx context.Context, client API, clusterARN, taskARN, container, command string) (*ecsexec.Result, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultExecTimeout)
+		defer cancel()
+	}
+	return ecsexec.Run(ctx, client, clusterARN, taskARN, container, command)
+}
+
+// ExecuteCommand runs the command like Exec and returns its output, stdout
+// followed by stderr. Like the aws CLI, it does not fail when the command
+// exits with a non-zero code; use Exec to check the exit code.
+func ExecuteCommand(ctx context.Context, client API, clusterARN, taskARN, container, command string) (string, error) {
+	res, err := Exec(ctx, client, clusterARN, taskARN, container, command)
+	if err != nil {
+		return "", err
+	}
+	return res.Stdout + res.Stderr, nil
+}
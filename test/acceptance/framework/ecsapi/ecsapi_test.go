@@ -0,0 +1,116 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package ecsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+const testClusterARN = "arn:aws:ecs:us-east-1:123456789012:cluster/cluster"
+
+// mockECS mocks the calls of the ECS API that have a function.
+// The other calls panic.
+type mockECS struct {
+	API
+	listTasks     func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error)
+	describeTasks func(*ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error)
+}
+
+func (m *mockECS) ListTasks(_ context.Context, params *ecs.ListTasksInput, _ ...func(*ecs.Options)) (*ecs.ListTasksOutput, error) {
+	return m.listTasks(params)
+}
+
+func (m *mockECS) DescribeTasks(_ context.Context, params *ecs.DescribeTasksInput, _ ...func(*ecs.Options)) (*ecs.DescribeTasksOutput, error) {
+	return m.describeTasks(params)
+}
+
+func TestListTasks(t *testing.T) {
+	pages := map[string]*ecs.ListTasksOutput{
+		"":      {TaskArns: []string{"task/1", "task/2"}, NextToken: aws.String("page2")},
+		"page2": {TaskArns: []string{"task/3"}},
+	}
+	var inputs []*ecs.ListTasksInput
+	client := &mockECS{listTasks: func(in *ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+		inputs = append(inputs, in)
+		return pages[aws.ToString(in.NextToken)], nil
+	}}
+
+	taskARNs, err := ListTasks(context.Background(), client, testClusterARN, ListTasksFilter{ServiceName: "server", DesiredStatus: types.DesiredStatusStopped})
+	require.NoError(t, err)
+	require.Equal(t, []string{"task/1", "task/2", "task/3"}, taskARNs)
+
+	require.Len(t, inputs, 2)
+	for _, in := range inputs {
+		require.Equal(t, testClusterARN, aws.ToString(in.Cluster))
+		require.Equal(t, "server", aws.ToString(in.ServiceName))
+		require.Nil(t, in.Family)
+		require.Equal(t, types.DesiredStatusStopped, in.DesiredStatus)
+	}
+
+	client.listTasks = func(*ecs.ListTasksInput) (*ecs.ListTasksOutput, error) {
+		return nil, errors.New("access denied")
+	}
+	_, err = ListTasks(context.Background(), client, testClusterARN, ListTasksFilter{Family: "server"})
+	require.EqualError(t, err, "failed to list the tasks of "+testClusterARN+": access denied")
+}
+
+func TestDescribeTasksBatches(t *testing.T) {
+	var batches []int
+	client := &mockECS{describeTasks: func(in *ecs.DescribeTasksInput) (*ecs.DescribeTasksOutput, error) {
+		batches = append(batches, len(in.Tasks))
+		out := &ecs.DescribeTasksOutput{}
+		for _, arn := range in.Tasks {
+			out.Tasks = append(out.Tasks, types.Task{TaskArn: aws.String(arn)})
+		}
+		return out, nil
+	}}
+
+	var taskARNs []string
+	for i := 0; i < 250; i++ {
+		taskARNs = append(taskARNs, fmt.Sprintf("task/%d", i))
+	}
+	out, err := DescribeTasks(context.Background(), client, testClusterARN, taskARNs...)
+	require.NoError(t, err)
+	require.Len(t, out.Tasks, 250)
+	require.Equal(t, []int{100, 100, 50}, batches)
+}
+
+func TestTaskCalls(t *testing.T) {
+	fake := fakes.NewECS(t,
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/1", Family: "client"},
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/2", Family: "server"},
+	)
+	client := fake.Client()
+	ctx := context.Background()
+	taskARN := "arn:aws:ecs:us-east-1:123456789012:task/cluster/2"
+
+	stoppedAt, err := TaskStoppedAt(ctx, client, testClusterARN, "2")
+	require.NoError(t, err)
+	require.True(t, stoppedAt.IsZero())
+
+	require.NoError(t, StopTask(ctx, client, testClusterARN, taskARN, "testing"))
+	stoppedAt, err = TaskStoppedAt(ctx, client, testClusterARN, "2")
+	require.NoError(t, err)
+	require.False(t, stoppedAt.IsZero())
+
+	stopped, err := ListTasks(ctx, client, testClusterARN, ListTasksFilter{Family: "server", DesiredStatus: types.DesiredStatusStopped})
+	require.NoError(t, err)
+	require.Equal(t, []string{taskARN}, stopped)
+
+	_, err = TaskStoppedAt(ctx, client, testClusterARN, "3")
+	require.ErrorIs(t, err, ErrTaskNotFound)
+
+	require.NoError(t, UpdateServiceDesiredCount(ctx, client, testClusterARN, "server", 2))
+	count, _ := fake.DesiredCount("server")
+	require.Equal(t, int32(2), count)
+}
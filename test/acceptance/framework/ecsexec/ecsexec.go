@@ -0,0 +1,323 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package ecsexec runs commands in the containers of ECS tasks with ECS Exec.
+// It starts the session with the ExecuteCommand call of the ECS API and
+// speaks the protocol of the SSM session data channel over its websocket,
+// so neither the aws CLI nor the session manager plugin is needed.
+package ecsexec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// clientVersion is the version of the session manager plugin
+	// whose protocol the client speaks.
+	clientVersion = "1.2.0.0"
+
+	// exitCodeMarker precedes the exit code of the command in its output.
+	exitCodeMarker = "ecsexec-exit-code="
+
+	// The size of the terminal of the session. The output of commands
+	// is not wrapped, but some commands format their output for it.
+	terminalCols = 400
+	terminalRows = 100
+)
+
+var exitCodePattern = regexp.MustCompile(exitCodeMarker + `(\d+)\n?`)
+
+// ExecuteCommandAPI is the call of the ECS API that starts ECS Exec sessions.
+type ExecuteCommandAPI interface {
+	ExecuteCommand(ctx context.Context, params *ecs.ExecuteCommandInput, optFns ...func(*ecs.Options)) (*ecs.ExecuteCommandOutput, error)
+}
+
+// Result is the outcome of a command.
+type Result struct {
+	Stdout string
+
+	// Stderr is only set by agents that send it separately. Otherwise
+	// the command runs in a terminal, which merges it into Stdout.
+	Stderr string
+
+	ExitCode int
+}
+
+// Run runs the command in the container of the task and waits for it to
+// exit. The command runs through /bin/sh, which reports its exit code, so
+// the container must have a shell. The line endings of the terminal of the
+// session are converted to \n.
+func Run(ctx context.Context, client ExecuteCommandAPI, clusterARN, taskARN, container, command string) (*Result, error) {
+	out, err := client.ExecuteCommand(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(clusterARN),
+		Task:        aws.String(taskARN),
+		Container:   aws.String(container),
+		Command:     aws.String(wrapCommand(command)),
+		Interactive: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start a session in the container %s of %s: %w", container, taskARN, err)
+	}
+	if out.Session == nil {
+		return nil, fmt.Errorf("no session in the container %s of %s", container, taskARN)
+	}
+
+	s := &session{
+		id:     aws.ToString(out.Session.SessionId),
+		url:    aws.ToString(out.Session.StreamUrl),
+		token:  aws.ToString(out.Session.TokenValue),
+		frames: make(map[int64]*message),
+	}
+	if err := s.run(ctx); err != nil {
+		return nil, fmt.Errorf("session %s in the container %s of %s: %w", s.id, container, taskARN, err)
+	}
+	return s.result()
+}
+
+// wrapCommand makes the shell print the exit code of the command after its output.
+func wrapCommand(command string) string {
+	quoted := strings.ReplaceAll(command, "'", `'\''`)
+	return fmt.Sprintf(`/bin/sh -c '%s; echo "%s$?"'`, quoted, exitCodeMarker)
+}
+
+// session is the client side of the data channel of an ECS Exec session.
+type session struct {
+	id, url, token string
+
+	ws *websocket.Conn
+
+	// inputSequence is the sequence number of the next input message.
+	inputSequence int64
+
+	// outputSequence is the sequence number of the next output message to
+	// process. Messages that arrive early are held in frames until then.
+	outputSequence int64
+	frames         map[int64]*message
+
+	stdout, stderr bytes.Buffer
+	closedOutput   string
+	closed         bool
+}
+
+func (s *session) run(ctx context.Context) error {
+	cfg, err := websocket.NewConfig(s.url, "http://localhost")
+	if err != nil {
+		return fmt.Errorf("invalid stream URL: %w", err)
+	}
+	if s.ws, err = cfg.DialContext(ctx); err != nil {
+		return fmt.Errorf("failed to open the data channel: %w", err)
+	}
+
+	// Reads do not take a context, so the connection is closed when it is done.
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			_ = s.ws.Close()
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		wg.Wait()
+		_ = s.ws.Close()
+	}()
+
+	open, _ := json.Marshal(map[string]string{
+		"MessageSchemaVersion": "1.0",
+		"RequestId":            newUUID(),
+		"TokenValue":           s.token,
+		"ClientId":             newUUID(),
+		"ClientVersion":        clientVersion,
+	})
+	if err := websocket.Message.Send(s.ws, string(open)); err != nil {
+		return fmt.Errorf("failed to open the data channel: %w", err)
+	}
+
+	for !s.closed {
+		var data []byte
+		if err := websocket.Message.Receive(s.ws, &data); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read from the data channel: %w", err)
+		}
+		msg, err := unmarshalMessage(data)
+		if err != nil {
+			return err
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *session) handle(msg *message) error {
+	switch msg.Type {
+	case messageTypeOutputStreamData:
+		if err := s.acknowledge(msg); err != nil {
+			return err
+		}
+		if msg.SequenceNumber < s.outputSequence {
+			// The agent resends the messages it got no acknowledgement for in time.
+			return nil
+		}
+		s.frames[msg.SequenceNumber] = msg
+		for {
+			next, ok := s.frames[s.outputSequence]
+			if !ok {
+				return nil
+			}
+			delete(s.frames, s.outputSequence)
+			s.outputSequence++
+			if err := s.process(next); err != nil {
+				return err
+			}
+		}
+	case messageTypeChannelClosed:
+		var closed struct {
+			Output string
+		}
+		_ = json.Unmarshal(msg.Payload, &closed)
+		s.closedOutput = closed.Output
+		s.closed = true
+	}
+	// Acknowledgements of the input are not checked, since the
+	// agent does not get more than a couple of input messages.
+	return nil
+}
+
+func (s *session) process(msg *message) error {
+	switch msg.PayloadType {
+	case payloadTypeHandshakeRequest:
+		return s.handshake(msg.Payload)
+	case payloadTypeHandshakeComplete:
+		size, _ := json.Marshal(map[string]int{"cols": terminalCols, "rows": terminalRows})
+		return s.send(payloadTypeTerminalSize, size)
+	case payloadTypeOutput:
+		s.stdout.Write(msg.Payload)
+	case payloadTypeStdErr:
+		s.stderr.Write(msg.Payload)
+	}
+	return nil
+}
+
+type clientAction struct {
+	ActionType   string
+	ActionStatus int             `json:",omitempty"`
+	Error        string          `json:",omitempty"`
+	ActionResult json.RawMessage `json:",omitempty"`
+}
+
+// handshake accepts the session type requested by the agent. Encrypting the
+// session with KMS is not supported, so sessions that require it fail.
+func (s *session) handshake(payload []byte) error {
+	var req struct {
+		RequestedClientActions []clientAction
+	}
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return fmt.Errorf("invalid handshake request: %w", err)
+	}
+
+	resp := struct {
+		ClientVersion          string
+		ProcessedClientActions []clientAction
+		Errors                 []string
+	}{ClientVersion: clientVersion}
+	for _, action := range req.RequestedClientActions {
+		switch action.ActionType {
+		case "SessionType":
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions, clientAction{ActionType: action.ActionType, ActionStatus: 1})
+		default:
+			return fmt.Errorf("the agent requested the unsupported %s action", action.ActionType)
+		}
+	}
+	payload, _ = json.Marshal(resp)
+	return s.send(payloadTypeHandshakeResponse, payload)
+}
+
+func (s *session) acknowledge(msg *message) error {
+	payload, _ := json.Marshal(map[string]any{
+		"AcknowledgedMessageType":           msg.Type,
+		"AcknowledgedMessageId":             msg.ID,
+		"AcknowledgedMessageSequenceNumber": msg.SequenceNumber,
+		"IsSequentialMessage":               true,
+	})
+	return s.write(&message{
+		Type:          messageTypeAcknowledge,
+		SchemaVersion: 1,
+		CreatedDate:   time.Now(),
+		Flags:         3,
+		ID:            newUUID(),
+		Payload:       payload,
+	})
+}
+
+func (s *session) send(payloadType payloadType, payload []byte) error {
+	msg := &message{
+		Type:           messageTypeInputStreamData,
+		SchemaVersion:  1,
+		CreatedDate:    time.Now(),
+		SequenceNumber: s.inputSequence,
+		ID:             newUUID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+	if s.inputSequence == 0 {
+		msg.Flags = flagSYN
+	}
+	s.inputSequence++
+	return s.write(msg)
+}
+
+func (s *session) write(msg *message) error {
+	data, err := msg.marshal()
+	if err != nil {
+		return err
+	}
+	if err := websocket.Message.Send(s.ws, data); err != nil {
+		return fmt.Errorf("failed to write to the data channel: %w", err)
+	}
+	return nil
+}
+
+// result splits the exit code that the shell printed from the output.
+func (s *session) result() (*Result, error) {
+	stdout := strings.ReplaceAll(s.stdout.String(), "\r\n", "\n")
+	loc := exitCodePattern.FindAllStringSubmatchIndex(stdout, -1)
+	if len(loc) == 0 {
+		msg := fmt.Sprintf("session %s ended before the command exited", s.id)
+		if s.closedOutput != "" {
+			msg += ": " + s.closedOutput
+		}
+		return nil, fmt.Errorf("%s, output: %q", msg, stdout)
+	}
+	last := loc[len(loc)-1]
+	exitCode, _ := strconv.Atoi(stdout[last[2]:last[3]])
+	return &Result{
+		Stdout:   stdout[:last[0]] + stdout[last[1]:],
+		Stderr:   strings.ReplaceAll(s.stderr.String(), "\r\n", "\n"),
+		ExitCode: exitCode,
+	}, nil
+}
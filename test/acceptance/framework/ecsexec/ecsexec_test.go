@@ -0,0 +1,296 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package ecsexec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// fakeOutput is a chunk of the output of a command.
+type fakeOutput struct {
+	payloadType payloadType
+	data        string
+}
+
+// fakeAgent plays the part of the SSM agent in the data channel of a
+// session. After the handshake, it sends the output of the command with
+// the line endings of a terminal, followed by the exit code printed by the
+// shell that wraps the command, and closes the channel.
+type fakeAgent struct {
+	t     *testing.T
+	token string
+
+	// commands maps the commands to their output and exit code.
+	commands map[string]fakeCommand
+
+	// requestedActions are the actions of the handshake request.
+	// They default to the session type.
+	requestedActions []string
+
+	// reorder sends the output messages in reverse order and twice.
+	reorder bool
+
+	// hang keeps the channel open without sending the output.
+	hang bool
+
+	acks atomic.Int32
+}
+
+type fakeCommand struct {
+	output   []fakeOutput
+	exitCode int
+}
+
+// ExecuteCommand starts a session whose data channel is served by the agent.
+func (a *fakeAgent) ExecuteCommand(_ context.Context, in *ecs.ExecuteCommandInput, _ ...func(*ecs.Options)) (*ecs.ExecuteCommandOutput, error) {
+	require.True(a.t, in.Interactive)
+	command := aws.ToString(in.Command)
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		a.serve(ws, command)
+	}))
+	a.t.Cleanup(server.Close)
+	return &ecs.ExecuteCommandOutput{Session: &types.Session{
+		SessionId:  aws.String("ecs-execute-command-0123"),
+		StreamUrl:  aws.String("ws" + strings.TrimPrefix(server.URL, "http")),
+		TokenValue: aws.String(a.token),
+	}}, nil
+}
+
+func (a *fakeAgent) serve(ws *websocket.Conn, command string) {
+	var open struct {
+		TokenValue string
+	}
+	if err := websocket.JSON.Receive(ws, &open); err != nil || open.TokenValue != a.token {
+		return
+	}
+
+	sequence := int64(0)
+	newOutput := func(payloadType payloadType, payload string) *message {
+		msg := &message{
+			Type:           messageTypeOutputStreamData,
+			SchemaVersion:  1,
+			CreatedDate:    time.Now(),
+			SequenceNumber: sequence,
+			ID:             newUUID(),
+			PayloadType:    payloadType,
+			Payload:        []byte(payload),
+		}
+		sequence++
+		return msg
+	}
+
+	actions := a.requestedActions
+	if len(actions) == 0 {
+		actions = []string{"SessionType"}
+	}
+	var requested []clientAction
+	for _, action := range actions {
+		requested = append(requested, clientAction{ActionType: action})
+	}
+	handshake, _ := json.Marshal(map[string]any{"AgentVersion": "3.3.0.0", "RequestedClientActions": requested})
+	a.send(ws, newOutput(payloadTypeHandshakeRequest, string(handshake)))
+	resp := a.receiveInput(ws, payloadTypeHandshakeResponse)
+	if resp == nil {
+		return
+	}
+	a.send(ws, newOutput(payloadTypeHandshakeComplete, "{}"))
+	if a.receiveInput(ws, payloadTypeTerminalSize) == nil {
+		return
+	}
+	if a.hang {
+		a.drain(ws)
+		return
+	}
+
+	inner, ok := strings.CutPrefix(command, `/bin/sh -c '`)
+	require.True(a.t, ok, "unwrapped command %s", command)
+	inner, ok = strings.CutSuffix(inner, `; echo "`+exitCodeMarker+`$?"'`)
+	require.True(a.t, ok, "unwrapped command %s", command)
+	cmd := a.commands[strings.ReplaceAll(inner, `'\''`, "'")]
+
+	var messages []*message
+	for _, out := range cmd.output {
+		messages = append(messages, newOutput(out.payloadType, strings.ReplaceAll(out.data, "\n", "\r\n")))
+	}
+	messages = append(messages, newOutput(payloadTypeOutput, exitCodeMarker+strconv.Itoa(cmd.exitCode)+"\r\n"))
+	if a.reorder {
+		for i := len(messages) - 1; i >= 0; i-- {
+			a.send(ws, messages[i])
+			a.send(ws, messages[i])
+		}
+	} else {
+		for _, msg := range messages {
+			a.send(ws, msg)
+		}
+	}
+
+	closed, _ := json.Marshal(map[string]any{"MessageType": messageTypeChannelClosed, "Output": ""})
+	a.send(ws, &message{Type: messageTypeChannelClosed, SchemaVersion: 1, CreatedDate: time.Now(), ID: newUUID(), Payload: closed})
+	a.drain(ws)
+}
+
+// drain reads the messages of the client until it closes the connection.
+func (a *fakeAgent) drain(ws *websocket.Conn) {
+	var data []byte
+	for websocket.Message.Receive(ws, &data) == nil {
+	}
+}
+
+func (a *fakeAgent) send(ws *websocket.Conn, msg *message) {
+	data, err := msg.marshal()
+	require.NoError(a.t, err)
+	_ = websocket.Message.Send(ws, data)
+}
+
+// receiveInput returns the next input message, which must have the payload
+// type, and counts the acknowledgements of the output on the way.
+func (a *fakeAgent) receiveInput(ws *websocket.Conn, payloadType payloadType) *message {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return nil
+		}
+		msg, err := unmarshalMessage(data)
+		require.NoError(a.t, err)
+		if msg.Type == messageTypeAcknowledge {
+			a.acks.Add(1)
+			continue
+		}
+		require.Equal(a.t, messageTypeInputStreamData, msg.Type)
+		require.Equal(a.t, payloadType, msg.PayloadType)
+		return msg
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := map[string]struct {
+		output  []fakeOutput
+		exit    int
+		reorder bool
+		result  *Result
+	}{
+		"output and exit code": {
+			output: []fakeOutput{{payloadTypeOutput, `{"code": 200}`}},
+			result: &Result{Stdout: `{"code": 200}`},
+		},
+		"failing command": {
+			output: []fakeOutput{{payloadTypeOutput, "curl: (7) Failed to connect to localhost port 1234\n"}},
+			exit:   7,
+			result: &Result{Stdout: "curl: (7) Failed to connect to localhost port 1234\n", ExitCode: 7},
+		},
+		"separate stderr": {
+			output: []fakeOutput{{payloadTypeOutput, "line 1\n"}, {payloadTypeStdErr, "warning\n"}, {payloadTypeOutput, "line 2\n"}},
+			exit:   1,
+			result: &Result{Stdout: "line 1\nline 2\n", Stderr: "warning\n", ExitCode: 1},
+		},
+		"out of order and resent messages": {
+			output:  []fakeOutput{{payloadTypeOutput, "a"}, {payloadTypeOutput, "b"}, {payloadTypeOutput, "c\n"}},
+			reorder: true,
+			result:  &Result{Stdout: "abc\n"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			command := `/bin/sh -c "curl localhost:1234"`
+			agent := &fakeAgent{
+				t:        t,
+				token:    "token",
+				commands: map[string]fakeCommand{command: {output: c.output, exitCode: c.exit}},
+				reorder:  c.reorder,
+			}
+			result, err := Run(context.Background(), agent, "cluster", "task", "basic", command)
+			require.NoError(t, err)
+			require.Equal(t, c.result, result)
+			require.Positive(t, agent.acks.Load())
+		})
+	}
+}
+
+func TestRunQuotesCommands(t *testing.T) {
+	agent := &fakeAgent{t: t, token: "token", commands: map[string]fakeCommand{
+		`echo 'it'"'"'s'`: {output: []fakeOutput{{payloadTypeOutput, "it's\n"}}},
+	}}
+	result, err := Run(context.Background(), agent, "cluster", "task", "basic", `echo 'it'"'"'s'`)
+	require.NoError(t, err)
+	require.Equal(t, "it's\n", result.Stdout)
+}
+
+func TestRunErrors(t *testing.T) {
+	agent := &fakeAgent{t: t, token: "token", requestedActions: []string{"SessionType", "KMSEncryption"}}
+	_, err := Run(context.Background(), agent, "cluster", "task", "basic", "true")
+	require.ErrorContains(t, err, "session ecs-execute-command-0123 in the container basic of task: the agent requested the unsupported KMSEncryption action")
+
+	agent = &fakeAgent{t: t, token: "token", hang: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = Run(ctx, agent, "cluster", "task", "basic", "true")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The agent closes the channel without running the command.
+	_, err = Run(context.Background(), wrongToken{&fakeAgent{t: t, token: "token"}}, "cluster", "task", "basic", "true")
+	require.ErrorContains(t, err, "session ecs-execute-command-0123 ended before the command exited")
+
+	_, err = Run(context.Background(), failingAPI{}, "cluster", "task", "basic", "true")
+	require.EqualError(t, err, "failed to start a session in the container basic of task: TargetNotConnectedException")
+}
+
+// wrongToken starts sessions whose data channel rejects the token.
+type wrongToken struct {
+	*fakeAgent
+}
+
+func (w wrongToken) ExecuteCommand(ctx context.Context, in *ecs.ExecuteCommandInput, optFns ...func(*ecs.Options)) (*ecs.ExecuteCommandOutput, error) {
+	out, err := w.fakeAgent.ExecuteCommand(ctx, in, optFns...)
+	if err == nil {
+		out.Session.TokenValue = aws.String("wrong")
+	}
+	return out, err
+}
+
+type failingAPI struct{}
+
+func (failingAPI) ExecuteCommand(context.Context, *ecs.ExecuteCommandInput, ...func(*ecs.Options)) (*ecs.ExecuteCommandOutput, error) {
+	return nil, errors.New("TargetNotConnectedException")
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := &message{
+		Type:           messageTypeOutputStreamData,
+		SchemaVersion:  1,
+		CreatedDate:    time.UnixMilli(1700000000123),
+		SequenceNumber: 42,
+		Flags:          flagSYN,
+		ID:             "0b1c2d3e-4f50-4617-8293-a4b5c6d7e8f9",
+		PayloadType:    payloadTypeOutput,
+		Payload:        []byte("hello"),
+	}
+	data, err := msg.marshal()
+	require.NoError(t, err)
+	require.Len(t, data, headerLength+payloadLengthSize+len("hello"))
+	// The least significant half of the message ID comes first.
+	require.Equal(t, []byte{0x82, 0x93, 0xa4, 0xb5, 0xc6, 0xd7, 0xe8, 0xf9}, data[64:72])
+
+	decoded, err := unmarshalMessage(data)
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+
+	data[len(data)-1] = 'O'
+	_, err = unmarshalMessage(data)
+	require.ErrorContains(t, err, "invalid digest")
+}
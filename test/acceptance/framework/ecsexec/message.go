@@ -0,0 +1,165 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package ecsexec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The types of the messages of the data channel.
+const (
+	messageTypeInputStreamData  = "input_stream_data"
+	messageTypeOutputStreamData = "output_stream_data"
+	messageTypeAcknowledge      = "acknowledge"
+	messageTypeChannelClosed    = "channel_closed"
+)
+
+// payloadType is the type of the payload of a stream data message.
+type payloadType uint32
+
+const (
+	payloadTypeOutput            payloadType = 1
+	payloadTypeTerminalSize      payloadType = 3
+	payloadTypeHandshakeRequest  payloadType = 5
+	payloadTypeHandshakeResponse payloadType = 6
+	payloadTypeHandshakeComplete payloadType = 7
+	payloadTypeStdErr            payloadType = 11
+)
+
+const (
+	// The fields of the header of a message, in order, and their sizes.
+	headerLengthSize   = 4
+	messageTypeSize    = 32
+	schemaVersionSize  = 4
+	createdDateSize    = 8
+	sequenceNumberSize = 8
+	flagsSize          = 8
+	messageIDSize      = 16
+	payloadDigestSize  = 32
+	payloadTypeSize    = 4
+	payloadLengthSize  = 4
+
+	// headerLength is the value of the header length field, which
+	// counts the fields from the header length to the payload type.
+	headerLength = headerLengthSize + messageTypeSize + schemaVersionSize + createdDateSize +
+		sequenceNumberSize + flagsSize + messageIDSize + payloadDigestSize + payloadTypeSize
+
+	// flagSYN marks the first message of a stream.
+	flagSYN = 1
+)
+
+// message is a binary message of the data channel of a session, as
+// exchanged between the session manager plugin and the SSM agent.
+type message struct {
+	Type           string
+	SchemaVersion  uint32
+	CreatedDate    time.Time
+	SequenceNumber int64
+	Flags          uint64
+	ID             string
+	PayloadType    payloadType
+	Payload        []byte
+}
+
+func (m *message) marshal() ([]byte, error) {
+	if len(m.Type) > messageTypeSize {
+		return nil, fmt.Errorf("message type %q is too long", m.Type)
+	}
+	id, err := marshalUUID(m.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(headerLength + payloadLengthSize + len(m.Payload))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(headerLength))
+	// The message type is padded with spaces.
+	buf.WriteString(m.Type + strings.Repeat(" ", messageTypeSize-len(m.Type)))
+	_ = binary.Write(&buf, binary.BigEndian, m.SchemaVersion)
+	_ = binary.Write(&buf, binary.BigEndian, uint64(m.CreatedDate.UnixMilli()))
+	_ = binary.Write(&buf, binary.BigEndian, m.SequenceNumber)
+	_ = binary.Write(&buf, binary.BigEndian, m.Flags)
+	buf.Write(id)
+	digest := sha256.Sum256(m.Payload)
+	buf.Write(digest[:])
+	_ = binary.Write(&buf, binary.BigEndian, uint32(m.PayloadType))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(m.Payload)))
+	buf.Write(m.Payload)
+	return buf.Bytes(), nil
+}
+
+func unmarshalMessage(data []byte) (*message, error) {
+	if len(data) < headerLengthSize {
+		return nil, errors.New("message is too short")
+	}
+	hl := int(binary.BigEndian.Uint32(data))
+	if hl < headerLength || len(data) < hl+payloadLengthSize {
+		return nil, fmt.Errorf("invalid header length %d for a message of %d bytes", hl, len(data))
+	}
+
+	r := bytes.NewReader(data[headerLengthSize:])
+	m := &message{}
+	msgType := make([]byte, messageTypeSize)
+	_, _ = r.Read(msgType)
+	m.Type = strings.TrimRight(string(bytes.TrimRight(msgType, "\x00")), " ")
+	var createdDate uint64
+	_ = binary.Read(r, binary.BigEndian, &m.SchemaVersion)
+	_ = binary.Read(r, binary.BigEndian, &createdDate)
+	m.CreatedDate = time.UnixMilli(int64(createdDate))
+	_ = binary.Read(r, binary.BigEndian, &m.SequenceNumber)
+	_ = binary.Read(r, binary.BigEndian, &m.Flags)
+	id := make([]byte, messageIDSize)
+	_, _ = r.Read(id)
+	m.ID = unmarshalUUID(id)
+	digest := make([]byte, payloadDigestSize)
+	_, _ = r.Read(digest)
+	_ = binary.Read(r, binary.BigEndian, (*uint32)(&m.PayloadType))
+
+	payloadLength := int(binary.BigEndian.Uint32(data[hl:]))
+	start := hl + payloadLengthSize
+	if len(data) < start+payloadLength {
+		return nil, fmt.Errorf("message of %d bytes is too short for a payload of %d bytes", len(data), payloadLength)
+	}
+	m.Payload = data[start : start+payloadLength]
+	if sum := sha256.Sum256(m.Payload); !bytes.Equal(sum[:], digest) {
+		return nil, fmt.Errorf("invalid digest of the payload of message %s", m.ID)
+	}
+	return m, nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return formatUUID(b[:])
+}
+
+func formatUUID(b []byte) string {
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// marshalUUID encodes the UUID the way the session manager plugin does,
+// with its least significant half first.
+func marshalUUID(id string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(b) != messageIDSize {
+		return nil, fmt.Errorf("invalid message ID %q", id)
+	}
+	return append(b[8:], b[:8]...), nil
+}
+
+func unmarshalUUID(b []byte) string {
+	return formatUUID(append(append([]byte{}, b[8:]...), b[:8]...))
+}
@@ -0,0 +1,95 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package fakes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ConfigEntries is an http.Handler serving the config entry endpoints of
+// Consul. API gateways and routes are reported as accepted once they have
+// been read more than AcceptAfter times since they were written.
+type ConfigEntries struct {
+	AcceptAfter int
+
+	mu      sync.Mutex
+	index   uint64
+	entries map[string]map[string]any
+	reads   map[string]int
+}
+
+// NewConfigEntries returns a fake with no config entries.
+func NewConfigEntries() *ConfigEntries {
+	return &ConfigEntries{
+		index:   1,
+		entries: make(map[string]map[string]any),
+		reads:   make(map[string]int),
+	}
+}
+
+// Get returns the entry as it was written, or nil if it does not exist.
+func (f *ConfigEntries) Get(kind, name string) map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.entries[kind+"/"+name]
+}
+
+// Reads returns the number of times the entry was read since it was written.
+func (f *ConfigEntries) Reads(kind, name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reads[kind+"/"+name]
+}
+
+func (f *ConfigEntries) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(req.URL.Path, "/v1/config/")
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+
+	switch req.Method {
+	case http.MethodPut:
+		var entry map[string]any
+		if err := json.NewDecoder(req.Body).Decode(&entry); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.index++
+		entry["ModifyIndex"] = f.index
+		key = strings.ToLower(entry["Kind"].(string)) + "/" + entry["Name"].(string)
+		delete(entry, "Status")
+		f.entries[key] = entry
+		f.reads[key] = 0
+		_, _ = w.Write([]byte("true"))
+	case http.MethodDelete:
+		f.index++
+		delete(f.entries, key)
+		_, _ = w.Write([]byte("true"))
+	default:
+		entry, ok := f.entries[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		kind := strings.SplitN(key, "/", 2)[0]
+		if kind == api.HTTPRoute || kind == api.TCPRoute || kind == api.APIGateway {
+			f.reads[key]++
+			status := api.ConditionStatusFalse
+			if f.reads[key] > f.AcceptAfter {
+				status = api.ConditionStatusTrue
+			}
+			entry["Status"] = map[string]any{
+				"Conditions": []map[string]any{{"Type": "Accepted", "Status": status, "Reason": "Pending"}},
+			}
+		}
+		_ = json.NewEncoder(w).Encode(entry)
+	}
+}
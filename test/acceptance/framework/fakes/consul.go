@@ -0,0 +1,273 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package fakes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+// ServiceInstance is a service instance registered in the fake Consul.
+type ServiceInstance struct {
+	ID      string
+	Service string
+	Node    string
+
+	// Partition and Namespace default to "default".
+	Partition string
+	Namespace string
+
+	Kind  api.ServiceKind
+	Port  int
+	Proxy *api.AgentServiceConnectProxyConfig
+
+	// Status is the status of the single health check of the instance.
+	// It defaults to passing.
+	Status string
+}
+
+// Consul is a fake of the catalog and health endpoints of the Consul HTTP
+// API. It supports blocking queries: a query for an index that is not older
+// than the current one returns when the state changes or the wait elapses.
+type Consul struct {
+	script
+
+	URL string
+
+	// MaxWait caps the wait of blocking queries. Since the scripted changes
+	// happen on requests, it lets clients that block on the current index
+	// poll again. It defaults to no cap.
+	MaxWait time.Duration
+
+	mu        sync.Mutex
+	index     uint64
+	instances map[string]ServiceInstance
+	changed   chan struct{}
+}
+
+// NewConsul starts a fake Consul that is stopped when the test completes.
+func NewConsul(t *testing.T, instances ...ServiceInstance) *Consul {
+	c := &Consul{
+		index:     1,
+		instances: make(map[string]ServiceInstance),
+		changed:   make(chan struct{}),
+	}
+	for _, inst := range instances {
+		c.instances[inst.ID] = withInstanceDefaults(inst)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/catalog/services", c.serveCatalogServices)
+	mux.HandleFunc("/v1/catalog/service/", c.serveCatalogService)
+	mux.HandleFunc("/v1/health/service/", c.serveHealthService)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	c.URL = server.URL
+	return c
+}
+
+// Client returns a Consul client of the fake.
+func (c *Consul) Client(t *testing.T) *api.Client {
+	client, err := api.NewClient(&api.Config{Address: c.URL})
+	require.NoError(t, err)
+	return client
+}
+
+// Register adds or replaces the instance.
+func (c *Consul) Register(inst ServiceInstance) {
+	c.update(func() {
+		c.instances[inst.ID] = withInstanceDefaults(inst)
+	})
+}
+
+// Deregister removes the instance with the ID.
+func (c *Consul) Deregister(id string) {
+	c.update(func() {
+		delete(c.instances, id)
+	})
+}
+
+// SetStatus sets the status of the health check of the instance with the ID.
+func (c *Consul) SetStatus(id, status string) {
+	c.update(func() {
+		if inst, ok := c.instances[id]; ok {
+			inst.Status = status
+			c.instances[id] = inst
+		}
+	})
+}
+
+// After runs the change when the polls-th request from now is received,
+// before it is served, e.g.
+//
+//	consul.After(3, func() { consul.SetStatus("server-1", api.HealthPassing) })
+func (c *Consul) After(polls int, change func()) {
+	c.after(polls, change)
+}
+
+// FailNext makes the next n requests fail with the HTTP status.
+func (c *Consul) FailNext(n, status int) {
+	c.failNext(n, failure{status: status, body: http.StatusText(status)})
+}
+
+func (c *Consul) update(change func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	change()
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func withInstanceDefaults(inst ServiceInstance) ServiceInstance {
+	if inst.Partition == "" {
+		inst.Partition = "default"
+	}
+	if inst.Namespace == "" {
+		inst.Namespace = "default"
+	}
+	if inst.Node == "" {
+		inst.Node = "node"
+	}
+	if inst.Status == "" {
+		inst.Status = api.HealthPassing
+	}
+	return inst
+}
+
+// serve counts the poll, waits for the state to change if the request is a
+// blocking query, and responds with the result of list for the instances
+// of the partition and namespace of the request.
+func (c *Consul) serve(w http.ResponseWriter, req *http.Request, list func([]ServiceInstance) any) {
+	if f, failed := c.poll(); failed {
+		http.Error(w, f.body, f.status)
+		return
+	}
+
+	query := req.URL.Query()
+	waitIndex, _ := strconv.ParseUint(query.Get("index"), 10, 64)
+	wait, err := time.ParseDuration(query.Get("wait"))
+	if err != nil {
+		wait = 5 * time.Minute
+	}
+	if c.MaxWait > 0 {
+		wait = min(wait, c.MaxWait)
+	}
+	timeout := time.After(wait)
+
+	c.mu.Lock()
+	for waitIndex > 0 && c.index <= waitIndex {
+		changed := c.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-req.Context().Done():
+			return
+		}
+		c.mu.Lock()
+		if c.changed == changed {
+			// The wait elapsed without changes.
+			break
+		}
+	}
+
+	partition, namespace := orDefault(query.Get("partition")), orDefault(query.Get("ns"))
+	var instances []ServiceInstance
+	for _, inst := range c.instances {
+		if inst.Partition == partition && inst.Namespace == namespace {
+			instances = append(instances, inst)
+		}
+	}
+	index := c.index
+	c.mu.Unlock()
+
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	_ = json.NewEncoder(w).Encode(list(instances))
+}
+
+func (c *Consul) serveCatalogServices(w http.ResponseWriter, req *http.Request) {
+	c.serve(w, req, func(instances []ServiceInstance) any {
+		services := map[string][]string{}
+		for _, inst := range instances {
+			services[inst.Service] = []string{}
+		}
+		return services
+	})
+}
+
+func (c *Consul) serveCatalogService(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/catalog/service/")
+	c.serve(w, req, func(instances []ServiceInstance) any {
+		services := []*api.CatalogService{}
+		for _, inst := range instances {
+			if inst.Service == name {
+				services = append(services, &api.CatalogService{
+					Node:         inst.Node,
+					ServiceID:    inst.ID,
+					ServiceName:  inst.Service,
+					ServicePort:  inst.Port,
+					ServiceProxy: inst.Proxy,
+					Partition:    inst.Partition,
+					Namespace:    inst.Namespace,
+				})
+			}
+		}
+		return services
+	})
+}
+
+func (c *Consul) serveHealthService(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/v1/health/service/")
+	passingOnly := req.URL.Query().Has("passing")
+	c.serve(w, req, func(instances []ServiceInstance) any {
+		entries := []*api.ServiceEntry{}
+		for _, inst := range instances {
+			if inst.Service != name || (passingOnly && inst.Status != api.HealthPassing) {
+				continue
+			}
+			entries = append(entries, &api.ServiceEntry{
+				Node: &api.Node{Node: inst.Node, Partition: inst.Partition},
+				Service: &api.AgentService{
+					ID:        inst.ID,
+					Service:   inst.Service,
+					Kind:      inst.Kind,
+					Port:      inst.Port,
+					Proxy:     inst.Proxy,
+					Partition: inst.Partition,
+					Namespace: inst.Namespace,
+				},
+				Checks: api.HealthChecks{{
+					Node:        inst.Node,
+					CheckID:     "service:" + inst.ID,
+					Name:        "service check",
+					Status:      inst.Status,
+					ServiceID:   inst.ID,
+					ServiceName: inst.Service,
+					Partition:   inst.Partition,
+					Namespace:   inst.Namespace,
+				}},
+			})
+		}
+		return entries
+	})
+}
+
+func orDefault(value string) string {
+	if value == "" {
+		return "default"
+	}
+	return value
+}
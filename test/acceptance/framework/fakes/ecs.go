@@ -0,0 +1,358 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package fakes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+const (
+	// ecsTargetPrefix starts the X-Amz-Target header of the requests
+	// to the ECS API, followed by the name of the operation.
+	ecsTargetPrefix = "AmazonEC2ContainerServiceV20141113."
+
+	defaultECSPageSize = 100
+)
+
+// Task is a task of the fake ECS cluster.
+type Task struct {
+	ARN    string
+	Family string
+
+	// Service is the name of the ECS service that started the task, if any.
+	Service string
+
+	// LastStatus and DesiredStatus default to RUNNING.
+	LastStatus    string
+	DesiredStatus string
+
+	StoppedAt     time.Time
+	StoppedReason string
+}
+
+// ECS is a fake of the ListTasks, DescribeTasks, StopTask and UpdateService
+// operations of the ECS API for a single cluster.
+type ECS struct {
+	script
+
+	URL string
+
+	// PageSize is the number of tasks returned by ListTasks when the
+	// request does not set maxResults. It defaults to 100.
+	PageSize int
+
+	mu            sync.Mutex
+	tasks         []Task
+	desiredCounts map[string]int32
+}
+
+// NewECS starts a fake ECS API that is stopped when the test completes.
+func NewECS(t *testing.T, tasks ...Task) *ECS {
+	e := &ECS{desiredCounts: make(map[string]int32)}
+	for _, task := range tasks {
+		e.tasks = append(e.tasks, withTaskDefaults(task))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(e.serve))
+	t.Cleanup(server.Close)
+	e.URL = server.URL
+	return e
+}
+
+// Client returns an ECS client of the fake. The client does not retry,
+// so that failures scripted with FailNext reach the caller.
+func (e *ECS) Client() *ecs.Client {
+	return ecs.New(ecs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(e.URL),
+		Credentials:  aws.AnonymousCredentials{},
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+// AddTask adds or replaces the task with the ARN of task.
+func (e *ECS) AddTask(task Task) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	task = withTaskDefaults(task)
+	for i := range e.tasks {
+		if e.tasks[i].ARN == task.ARN {
+			e.tasks[i] = task
+			return
+		}
+	}
+	e.tasks = append(e.tasks, task)
+}
+
+// SetTaskStatus sets the last status of the task. Setting it to STOPPED
+// also sets the desired status and the time at which the task stopped.
+func (e *ECS) SetTaskStatus(arn, status string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.tasks {
+		if e.tasks[i].ARN == arn {
+			e.tasks[i].LastStatus = status
+			if status == "STOPPED" {
+				e.tasks[i].DesiredStatus = status
+				e.tasks[i].StoppedAt = time.Now()
+			}
+		}
+	}
+}
+
+// Task returns the task with the ARN.
+func (e *ECS) Task(arn string) (Task, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.find(arn)
+	if i < 0 {
+		return Task{}, false
+	}
+	return e.tasks[i], true
+}
+
+// DesiredCount returns the desired count last set with UpdateService.
+func (e *ECS) DesiredCount(service string) (int32, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	count, ok := e.desiredCounts[service]
+	return count, ok
+}
+
+// After runs the change when the polls-th request from now is received,
+// before it is served, e.g.
+//
+//	fake.After(3, func() { fake.SetTaskStatus(arn, "RUNNING") })
+func (e *ECS) After(polls int, change func()) {
+	e.after(polls, change)
+}
+
+// FailNext makes the next n requests fail with the ECS error type,
+// e.g. ClusterNotFoundException.
+func (e *ECS) FailNext(n int, errorType string) {
+	body, _ := json.Marshal(map[string]string{"__type": errorType, "message": "fake " + errorType})
+	e.failNext(n, failure{status: http.StatusBadRequest, body: string(body)})
+}
+
+func withTaskDefaults(task Task) Task {
+	if task.LastStatus == "" {
+		task.LastStatus = "RUNNING"
+	}
+	if task.DesiredStatus == "" {
+		task.DesiredStatus = "RUNNING"
+	}
+	return task
+}
+
+// find returns the index of the task whose ARN or ID is arn, or -1.
+func (e *ECS) find(arn string) int {
+	for i, task := range e.tasks {
+		if task.ARN == arn || strings.HasSuffix(task.ARN, "/"+arn) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (e *ECS) serve(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if f, failed := e.poll(); failed {
+		w.WriteHeader(f.status)
+		_, _ = w.Write([]byte(f.body))
+		return
+	}
+
+	var (
+		resp any
+		err  error
+	)
+	switch op := strings.TrimPrefix(req.Header.Get("X-Amz-Target"), ecsTargetPrefix); op {
+	case "ListTasks":
+		resp, err = decodeAndServe(req, e.listTasks)
+	case "DescribeTasks":
+		resp, err = decodeAndServe(req, e.describeTasks)
+	case "StopTask":
+		resp, err = decodeAndServe(req, e.stopTask)
+	case "UpdateService":
+		resp, err = decodeAndServe(req, e.updateService)
+	default:
+		err = fmt.Errorf("unsupported operation %q", op)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"__type": "InvalidParameterException", "message": err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func decodeAndServe[In any](req *http.Request, op func(In) (any, error)) (any, error) {
+	var in In
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		return nil, err
+	}
+	return op(in)
+}
+
+type ecsTask struct {
+	TaskARN           string   `json:"taskArn"`
+	TaskDefinitionARN string   `json:"taskDefinitionArn"`
+	Group             string   `json:"group,omitempty"`
+	LastStatus        string   `json:"lastStatus"`
+	DesiredStatus     string   `json:"desiredStatus"`
+	StoppedAt         *float64 `json:"stoppedAt,omitempty"`
+	StoppedReason     string   `json:"stoppedReason,omitempty"`
+}
+
+func newECSTask(task Task) ecsTask {
+	t := ecsTask{
+		TaskARN:           task.ARN,
+		TaskDefinitionARN: "arn:aws:ecs:us-east-1:123456789012:task-definition/" + task.Family + ":1",
+		LastStatus:        task.LastStatus,
+		DesiredStatus:     task.DesiredStatus,
+		StoppedReason:     task.StoppedReason,
+	}
+	if task.Service != "" {
+		t.Group = "service:" + task.Service
+	}
+	if !task.StoppedAt.IsZero() {
+		stoppedAt := float64(task.StoppedAt.UnixMilli()) / 1000
+		t.StoppedAt = &stoppedAt
+	}
+	return t
+}
+
+type listTasksInput struct {
+	Family        string `json:"family"`
+	ServiceName   string `json:"serviceName"`
+	DesiredStatus string `json:"desiredStatus"`
+	MaxResults    int    `json:"maxResults"`
+	NextToken     string `json:"nextToken"`
+}
+
+// listTasks pages through the tasks of the family or service that have the
+// desired status, RUNNING by default. The next token is the offset of the
+// next page.
+func (e *ECS) listTasks(in listTasksInput) (any, error) {
+	desiredStatus := in.DesiredStatus
+	if desiredStatus == "" {
+		desiredStatus = "RUNNING"
+	}
+	pageSize := in.MaxResults
+	if pageSize == 0 {
+		pageSize = e.PageSize
+	}
+	if pageSize == 0 {
+		pageSize = defaultECSPageSize
+	}
+	offset := 0
+	if in.NextToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(in.NextToken); err != nil {
+			return nil, fmt.Errorf("invalid next token %q", in.NextToken)
+		}
+	}
+
+	e.mu.Lock()
+	var arns []string
+	for _, task := range e.tasks {
+		if (in.Family == "" || task.Family == in.Family) &&
+			(in.ServiceName == "" || task.Service == in.ServiceName) &&
+			task.DesiredStatus == desiredStatus {
+			arns = append(arns, task.ARN)
+		}
+	}
+	e.mu.Unlock()
+	sort.Strings(arns)
+
+	out := struct {
+		TaskARNs  []string `json:"taskArns"`
+		NextToken string   `json:"nextToken,omitempty"`
+	}{TaskARNs: []string{}}
+	if offset < len(arns) {
+		end := min(offset+pageSize, len(arns))
+		out.TaskARNs = arns[offset:end]
+		if end < len(arns) {
+			out.NextToken = strconv.Itoa(end)
+		}
+	}
+	return out, nil
+}
+
+type describeTasksInput struct {
+	Tasks []string `json:"tasks"`
+}
+
+func (e *ECS) describeTasks(in describeTasksInput) (any, error) {
+	type taskFailure struct {
+		ARN    string `json:"arn"`
+		Reason string `json:"reason"`
+	}
+	out := struct {
+		Tasks    []ecsTask     `json:"tasks"`
+		Failures []taskFailure `json:"failures"`
+	}{Tasks: []ecsTask{}, Failures: []taskFailure{}}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, arn := range in.Tasks {
+		if i := e.find(arn); i >= 0 {
+			out.Tasks = append(out.Tasks, newECSTask(e.tasks[i]))
+		} else {
+			out.Failures = append(out.Failures, taskFailure{ARN: arn, Reason: "MISSING"})
+		}
+	}
+	return out, nil
+}
+
+type stopTaskInput struct {
+	Task   string `json:"task"`
+	Reason string `json:"reason"`
+}
+
+// stopTask stops the task right away, without the intermediate
+// statuses that ECS goes through.
+func (e *ECS) stopTask(in stopTaskInput) (any, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	i := e.find(in.Task)
+	if i < 0 {
+		return nil, fmt.Errorf("the referenced task %s was not found", in.Task)
+	}
+	task := &e.tasks[i]
+	task.DesiredStatus = "STOPPED"
+	task.LastStatus = "STOPPED"
+	task.StoppedAt = time.Now()
+	task.StoppedReason = in.Reason
+	return map[string]ecsTask{"task": newECSTask(*task)}, nil
+}
+
+type updateServiceInput struct {
+	Service      string `json:"service"`
+	DesiredCount *int32 `json:"desiredCount"`
+}
+
+func (e *ECS) updateService(in updateServiceInput) (any, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if in.DesiredCount != nil {
+		e.desiredCounts[in.Service] = *in.DesiredCount
+	}
+	return map[string]any{"service": map[string]any{
+		"serviceName":  in.Service,
+		"desiredCount": e.desiredCounts[in.Service],
+	}}, nil
+}
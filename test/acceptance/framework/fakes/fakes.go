@@ -0,0 +1,89 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package fakes provides fakes of the Consul HTTP API and of the ECS API
+// built on httptest, so that the helpers that poll them can be tested
+// without credentials. The state of the fakes is scripted with changes
+// that happen after a number of polls, e.g. an instance becoming healthy
+// on the third request, and with failures of the next requests.
+package fakes
+
+import (
+	"sync"
+)
+
+// script counts the requests, i.e. polls, made to a fake and holds the
+// changes and failures scheduled for the next ones.
+type script struct {
+	mu       sync.Mutex
+	polls    int
+	changes  []scheduledChange
+	failures []failure
+}
+
+type scheduledChange struct {
+	poll   int
+	change func()
+}
+
+type failure struct {
+	status int
+	body   string
+}
+
+// after schedules the change to run when the poll-th request from now is
+// received, before the request is served.
+func (s *script) after(polls int, change func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, scheduledChange{poll: s.polls + polls, change: change})
+}
+
+// failNext makes the next n requests fail.
+func (s *script) failNext(n int, f failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, f)
+	}
+}
+
+// poll counts a request and runs the changes that are due. It returns the
+// failure to respond with, if any, which may have been scheduled by the
+// changes. The changes run without holding the lock of the script so that
+// they can use the methods of the fake.
+func (s *script) poll() (failure, bool) {
+	s.mu.Lock()
+	s.polls++
+	var due []func()
+	pending := s.changes[:0]
+	for _, c := range s.changes {
+		if c.poll <= s.polls {
+			due = append(due, c.change)
+		} else {
+			pending = append(pending, c)
+		}
+	}
+	s.changes = pending
+	s.mu.Unlock()
+
+	for _, change := range due {
+		change()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) == 0 {
+		return failure{}, false
+	}
+	f := s.failures[0]
+	s.failures = s.failures[1:]
+	return f, true
+}
+
+// Polls returns the number of requests received.
+func (s *script) Polls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.polls
+}
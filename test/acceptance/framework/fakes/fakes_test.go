@@ -0,0 +1,120 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package fakes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsul(t *testing.T) {
+	consul := NewConsul(t,
+		ServiceInstance{ID: "server-1", Service: "server", Status: api.HealthCritical},
+		ServiceInstance{ID: "client-1", Service: "client", Namespace: "ns1"},
+	)
+	client := consul.Client(t)
+	consul.After(3, func() { consul.SetStatus("server-1", api.HealthPassing) })
+
+	for poll := 1; poll <= 3; poll++ {
+		entries, _, err := client.Health().Service("server", "", true, nil)
+		require.NoError(t, err)
+		if poll < 3 {
+			require.Empty(t, entries, "poll %d", poll)
+		} else {
+			require.Len(t, entries, 1)
+		}
+	}
+	require.Equal(t, 3, consul.Polls())
+
+	services, _, err := client.Catalog().Services(&api.QueryOptions{Namespace: "ns1"})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{"client": {}}, services)
+
+	consul.FailNext(1, http.StatusInternalServerError)
+	_, _, err = client.Catalog().Services(nil)
+	require.ErrorContains(t, err, "500")
+	_, _, err = client.Catalog().Services(nil)
+	require.NoError(t, err)
+}
+
+func TestConsulBlockingQuery(t *testing.T) {
+	consul := NewConsul(t, ServiceInstance{ID: "server-1", Service: "server"})
+	client := consul.Client(t)
+
+	instances, meta, err := client.Catalog().Service("server", "", nil)
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+
+	// A blocking query returns once the wait elapses without changes...
+	start := time.Now()
+	_, blocked, err := client.Catalog().Service("server", "", &api.QueryOptions{WaitIndex: meta.LastIndex, WaitTime: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.Equal(t, meta.LastIndex, blocked.LastIndex)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// ...or as soon as the state changes.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		consul.Deregister("server-1")
+	}()
+	instances, changed, err := client.Catalog().Service("server", "", &api.QueryOptions{WaitIndex: meta.LastIndex, WaitTime: time.Minute})
+	require.NoError(t, err)
+	require.Empty(t, instances)
+	require.Greater(t, changed.LastIndex, meta.LastIndex)
+}
+
+func TestECS(t *testing.T) {
+	fake := NewECS(t,
+		Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/1", Family: "server", Service: "server"},
+		Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/2", Family: "server", Service: "server"},
+		Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/3", Family: "server", Service: "server", LastStatus: "STOPPED", DesiredStatus: "STOPPED"},
+		Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/4", Family: "client"},
+	)
+	fake.PageSize = 1
+	client := fake.Client()
+	ctx := context.Background()
+
+	page, err := client.ListTasks(ctx, &ecs.ListTasksInput{Cluster: aws.String("cluster"), Family: aws.String("server")})
+	require.NoError(t, err)
+	require.Equal(t, []string{"arn:aws:ecs:us-east-1:123456789012:task/cluster/1"}, page.TaskArns)
+	page, err = client.ListTasks(ctx, &ecs.ListTasksInput{Cluster: aws.String("cluster"), Family: aws.String("server"), NextToken: page.NextToken})
+	require.NoError(t, err)
+	require.Equal(t, []string{"arn:aws:ecs:us-east-1:123456789012:task/cluster/2"}, page.TaskArns)
+	require.Nil(t, page.NextToken)
+
+	stopped, err := client.ListTasks(ctx, &ecs.ListTasksInput{ServiceName: aws.String("server"), DesiredStatus: types.DesiredStatusStopped})
+	require.NoError(t, err)
+	require.Equal(t, []string{"arn:aws:ecs:us-east-1:123456789012:task/cluster/3"}, stopped.TaskArns)
+
+	_, err = client.StopTask(ctx, &ecs.StopTaskInput{Task: aws.String("1"), Reason: aws.String("test")})
+	require.NoError(t, err)
+	described, err := client.DescribeTasks(ctx, &ecs.DescribeTasksInput{Tasks: []string{"1", "5"}})
+	require.NoError(t, err)
+	require.Len(t, described.Tasks, 1)
+	require.Equal(t, "STOPPED", aws.ToString(described.Tasks[0].LastStatus))
+	require.Equal(t, "test", aws.ToString(described.Tasks[0].StoppedReason))
+	require.NotNil(t, described.Tasks[0].StoppedAt)
+	require.Equal(t, "service:server", aws.ToString(described.Tasks[0].Group))
+	require.Equal(t, "MISSING", aws.ToString(described.Failures[0].Reason))
+
+	_, err = client.UpdateService(ctx, &ecs.UpdateServiceInput{Service: aws.String("server"), DesiredCount: aws.Int32(3)})
+	require.NoError(t, err)
+	count, ok := fake.DesiredCount("server")
+	require.True(t, ok)
+	require.Equal(t, int32(3), count)
+
+	fake.FailNext(1, "ClusterNotFoundException")
+	_, err = client.ListTasks(ctx, &ecs.ListTasksInput{})
+	var notFound *types.ClusterNotFoundException
+	require.True(t, errors.As(err, &notFound), "unexpected error %v", err)
+}
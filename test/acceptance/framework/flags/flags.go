@@ -25,6 +25,8 @@ const (
 	// by go test to specify build tags.
 	flagTFTags      = "tf-tags"
 	flagTFOutputDir = "tf-output-dir"
+	flagReuseState  = "reuse-state"
+	flagReportDir   = "report-dir"
 
 	setupTerraformDir = "../../setup-terraform"
 )
@@ -39,6 +41,8 @@ type TestFlags struct {
 	flagLogGroupName       string
 	flagTFTags             string
 	flagTFOutputDir        string
+	flagReuseState         string
+	flagReportDir          string
 
 	once sync.Once
 }
@@ -62,6 +66,9 @@ func (t *TestFlags) init() {
 	flag.StringVar(&t.flagLogGroupName, flagLogGroupName, "", "CloudWatch log group name.")
 	flag.StringVar(&t.flagTFTags, flagTFTags, "", "Tags to add to resources. In TF var form, e.g. '{key=val,key2=val2}'.")
 	flag.StringVar(&t.flagTFOutputDir, flagTFOutputDir, setupTerraformDir, "The directory of the setup terraform state for the tests.")
+	flag.StringVar(&t.flagReuseState, flagReuseState, "",
+		"Path to the terraform working directory of an existing deployment of a test, e.g. one left behind with -no-cleanup-on-failure. If set, the test deploying that directory skips terraform apply and destroy and only validates the deployment recorded in its state. The other tests are skipped.")
+	flag.StringVar(&t.flagReportDir, flagReportDir, "", "Directory to write the JUnit XML and JSON reports of the tests to. No report is written if empty.")
 }
 
 func (t *TestFlags) Validate() error {
@@ -133,6 +140,8 @@ func (t *TestFlags) TestConfigFromFlags() (*config.TestConfig, error) {
 	}
 
 	cfg.NoCleanupOnFailure = t.flagNoCleanupOnFailure
+	cfg.ReuseState = t.flagReuseState
+	cfg.ReportDir = t.flagReportDir
 
 	return &cfg, nil
 }
@@ -0,0 +1,284 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+)
+
+const (
+	// defaultProbeContainer is the container of the mesh tasks that runs the probes.
+	defaultProbeContainer = "basic"
+
+	// defaultAllowedOutput is part of the response of fake-service, which
+	// the mesh tasks run, to a request that was allowed through the mesh.
+	defaultAllowedOutput = `"code": 200`
+)
+
+// Connection is a request from the source task to the destination task.
+type Connection struct {
+	Source      *MeshTask
+	Destination *MeshTask
+}
+
+func (c Connection) String() string {
+	return fmt.Sprintf("%s -> %s", c.Source, c.Destination)
+}
+
+// ConnectivityTarget returns the address that the source task calls
+// to reach the destination task, or an empty string if the source
+// has no route to the destination and the pair cannot be probed.
+type ConnectivityTarget func(src, dst *MeshTask) string
+
+// VirtualTarget targets the virtual address of the destination service,
+// which tasks with transparent proxy enabled can call in any namespace
+// and partition.
+func VirtualTarget(_, dst *MeshTask) string {
+	return fmt.Sprintf("http://%s.virtual.%s.ns.%s.ap.consul", dst.Name, dst.Namespace, dst.Partition)
+}
+
+// StaticTargets targets the addresses listed for each connection, e.g. the
+// local addresses that the explicit upstreams of the source tasks bind to.
+// Pairs that are not listed are not probed.
+func StaticTargets(targets map[Connection]string) ConnectivityTarget {
+	return func(src, dst *MeshTask) string {
+		return targets[Connection{Source: src, Destination: dst}]
+	}
+}
+
+// ConnectivityMatrix describes which of the tasks are expected to be able
+// to call each other. Every pair of tasks that is not allowed is expected
+// to be denied, e.g. by the lack of an intention or of an exported-services
+// config entry.
+type ConnectivityMatrix struct {
+	Tasks   []*MeshTask
+	Allowed []Connection
+
+	// Target returns the address that the probes call.
+	// It defaults to VirtualTarget.
+	Target ConnectivityTarget
+
+	// Container is the container of the source task that probes the
+	// destination. It defaults to the basic container of the tasks.
+	Container string
+
+	// AllowedOutput is the output of a probe that was allowed. It
+	// defaults to the success response of fake-service. Probes whose
+	// curl command exits with a non-zero code are never allowed.
+	AllowedOutput string
+
+	// DeniedOutput is the output expected of the probes of the listed
+	// connections when they are denied, e.g. the error that curl reports
+	// when the proxy of the destination resets the connection. The probes
+	// of these connections that are neither allowed nor denied this way
+	// are reported as errors. Any other output of the probes of the
+	// connections that are not listed counts as a deny.
+	DeniedOutput map[Connection]string
+
+	// Exec runs the probe command in the source task.
+	// It defaults to MeshTask.Exec.
+	Exec func(src *MeshTask, container, command string) (*ecsexec.Result, error)
+}
+
+// ConnectivityResult is the outcome of probing a single connection.
+type ConnectivityResult struct {
+	Connection
+
+	// Probed is false if the source has no route to the destination.
+	Probed   bool
+	Allowed  bool
+	Expected bool
+	Output   string
+	Err      error
+}
+
+// Unexpected returns true if the connection was not probed successfully
+// or if its outcome differs from the one expected.
+func (r ConnectivityResult) Unexpected() bool {
+	return r.Err != nil || r.Allowed != r.Expected
+}
+
+func (r ConnectivityResult) cell() string {
+	switch {
+	case r.Err != nil:
+		return "ERROR"
+	case r.Allowed && !r.Expected:
+		return "ALLOW (expected deny)"
+	case !r.Allowed && r.Expected:
+		return "DENY (expected allow)"
+	case !r.Probed:
+		return "no route"
+	case r.Allowed:
+		return "allow"
+	default:
+		return "deny"
+	}
+}
+
+// ConnectivityDiff holds the results of probing every pair of tasks of
+// a matrix and reports the ones that differ from the expected matrix.
+type ConnectivityDiff struct {
+	tasks   []*MeshTask
+	Results []ConnectivityResult
+}
+
+// Empty returns true if every connection matches the expected matrix.
+func (d *ConnectivityDiff) Empty() bool {
+	for _, r := range d.Results {
+		if r.Unexpected() {
+			return false
+		}
+	}
+	return true
+}
+
+// UnexpectedAllows returns the connections that were allowed but expected to be denied.
+func (d *ConnectivityDiff) UnexpectedAllows() []ConnectivityResult {
+	return d.filter(func(r ConnectivityResult) bool { return r.Err == nil && r.Allowed && !r.Expected })
+}
+
+// UnexpectedDenies returns the connections that were denied but expected to be allowed.
+func (d *ConnectivityDiff) UnexpectedDenies() []ConnectivityResult {
+	return d.filter(func(r ConnectivityResult) bool { return r.Err == nil && !r.Allowed && r.Expected })
+}
+
+// Errors returns the connections that could not be probed.
+func (d *ConnectivityDiff) Errors() []ConnectivityResult {
+	return d.filter(func(r ConnectivityResult) bool { return r.Err != nil })
+}
+
+func (d *ConnectivityDiff) filter(keep func(ConnectivityResult) bool) []ConnectivityResult {
+	var results []ConnectivityResult
+	for _, r := range d.Results {
+		if keep(r) {
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// String renders the observed matrix, with a row per source and a column
+// per destination, followed by the output of the unexpected probes.
+func (d *ConnectivityDiff) String() string {
+	results := make(map[Connection]ConnectivityResult, len(d.Results))
+	for _, r := range d.Results {
+		results[r.Connection] = r
+	}
+
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "SOURCE \\ DESTINATION")
+	for _, dst := range d.tasks {
+		fmt.Fprintf(w, "\t%s", dst)
+	}
+	fmt.Fprintln(w)
+	for _, src := range d.tasks {
+		fmt.Fprint(w, src.String())
+		for _, dst := range d.tasks {
+			cell := "-"
+			if r, ok := results[Connection{Source: src, Destination: dst}]; ok {
+				cell = r.cell()
+			}
+			fmt.Fprintf(w, "\t%s", cell)
+		}
+		fmt.Fprintln(w)
+	}
+	_ = w.Flush()
+
+	for _, r := range d.Results {
+		switch {
+		case r.Err != nil:
+			fmt.Fprintf(&sb, "%s: %s\n", r.Connection, r.Err)
+		case r.Unexpected() && r.Probed:
+			fmt.Fprintf(&sb, "%s: %s\n", r.Connection, strings.Join(strings.Fields(r.Output), " "))
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Probe calls every destination from every other task once
+// and compares the outcomes with the expected matrix.
+func (m ConnectivityMatrix) Probe() *ConnectivityDiff {
+	container := m.Container
+	if container == "" {
+		container = defaultProbeContainer
+	}
+	allowedOutput := m.AllowedOutput
+	if allowedOutput == "" {
+		allowedOutput = defaultAllowedOutput
+	}
+	target := m.Target
+	if target == nil {
+		target = VirtualTarget
+	}
+	exec := m.Exec
+	if exec == nil {
+		exec = func(src *MeshTask, container, command string) (*ecsexec.Result, error) {
+			return src.Exec(container, command)
+		}
+	}
+
+	allowed := make(map[Connection]bool, len(m.Allowed))
+	for _, c := range m.Allowed {
+		allowed[c] = true
+	}
+
+	diff := &ConnectivityDiff{tasks: m.Tasks}
+	for _, src := range m.Tasks {
+		for _, dst := range m.Tasks {
+			if src == dst {
+				continue
+			}
+
+			result := ConnectivityResult{
+				Connection: Connection{Source: src, Destination: dst},
+				Expected:   allowed[Connection{Source: src, Destination: dst}],
+			}
+			if addr := target(src, dst); addr != "" {
+				result.Probed = true
+				res, err := exec(src, container, fmt.Sprintf(`/bin/sh -c "curl %s"`, addr))
+				if err != nil {
+					result.Err = err
+				} else {
+					result.Output = res.Stdout + res.Stderr
+					result.Allowed = res.ExitCode == 0 && strings.Contains(res.Stdout, allowedOutput)
+					denied, ok := m.DeniedOutput[result.Connection]
+					if ok && !result.Allowed && !strings.Contains(result.Output, denied) {
+						result.Err = fmt.Errorf("expected the output to contain %q or %q, got %q", allowedOutput, denied, result.Output)
+					}
+				}
+			}
+			diff.Results = append(diff.Results, result)
+		}
+	}
+	return diff
+}
+
+// Verify probes the matrix until every connection matches the expected
+// matrix or the timer expires, and returns the last observed diff.
+// Intentions and config entries take a while to reach the proxies,
+// so the first probes can differ from the expected matrix.
+func (m ConnectivityMatrix) Verify(t *testing.T, timer *retry.Timer) *ConnectivityDiff {
+	deadline := time.Now().Add(timer.Timeout)
+	for {
+		diff := m.Probe()
+		if diff.Empty() {
+			logger.Log(t, "observed expected connectivity between the tasks")
+			return diff
+		}
+		if time.Now().Add(timer.Wait).After(deadline) {
+			return diff
+		}
+		logger.Log(t, fmt.Sprintf("unexpected connectivity between the tasks, retrying in %s:\n%s", timer.Wait, diff))
+		time.Sleep(timer.Wait)
+	}
+}
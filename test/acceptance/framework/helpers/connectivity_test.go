@@ -0,0 +1,203 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMesh answers the probes of the connectivity matrix as
+// if the allowed connections had intentions.
+type fakeMesh struct {
+	mu sync.Mutex
+	// allowed maps the allowed connections to the exit code of curl.
+	allowed  map[string]int
+	failing  map[string]error
+	commands []string
+}
+
+func (m *fakeMesh) exec(src *MeshTask, container, command string) (*ecsexec.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.commands = append(m.commands, src.Name+" "+container+" "+command)
+	for dst, err := range m.failing {
+		if strings.Contains(command, dst) {
+			return nil, err
+		}
+	}
+	for conn, exitCode := range m.allowed {
+		parts := strings.SplitN(conn, "->", 2)
+		if parts[0] == src.Name && strings.Contains(command, "http://"+parts[1]+".") {
+			return &ecsexec.Result{Stdout: `{"name": "server", "code": 200}`, ExitCode: exitCode}, nil
+		}
+	}
+	return &ecsexec.Result{Stdout: "curl: (52) Empty reply from server", ExitCode: 52}, nil
+}
+
+func (m *fakeMesh) allow(conn string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allowed[conn] = 0
+}
+
+func TestConnectivityMatrixProbe(t *testing.T) {
+	client := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "part1", Namespace: "ns1"}}
+	server := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "server", Partition: "part2", Namespace: "ns2"}}
+	admin := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "admin", Partition: "default", Namespace: "default"}}
+	tasks := []*MeshTask{client, server, admin}
+
+	cases := map[string]struct {
+		allowed  []Connection
+		denied   map[Connection]string
+		mesh     map[string]int
+		failing  map[string]error
+		allows   []Connection
+		denies   []Connection
+		errors   []Connection
+		expected string
+	}{
+		"matches": {
+			allowed: []Connection{{client, server}, {admin, server}},
+			mesh:    map[string]int{"client->server": 0, "admin->server": 0},
+		},
+		"unexpected allow and deny": {
+			allowed: []Connection{{client, server}},
+			mesh:    map[string]int{"server->client": 0},
+			allows:  []Connection{{server, client}},
+			denies:  []Connection{{client, server}},
+			expected: `SOURCE \ DESTINATION   part1/ns1/client       part2/ns2/server       default/default/admin
+part1/ns1/client       -                      DENY (expected allow)  deny
+part2/ns2/server       ALLOW (expected deny)  -                      deny
+default/default/admin  deny                   deny                   -
+part1/ns1/client -> part2/ns2/server: curl: (52) Empty reply from server
+part2/ns2/server -> part1/ns1/client: {"name": "server", "code": 200}`,
+		},
+		"expected denied output": {
+			allowed: []Connection{{client, server}},
+			denied:  map[Connection]string{{admin, server}: "curl: (52) Empty reply from server"},
+			mesh:    map[string]int{"client->server": 0},
+		},
+		"unexpected denied output": {
+			denied: map[Connection]string{{client, server}: "curl: (56) Recv failure: Connection reset by peer"},
+			errors: []Connection{{client, server}},
+			expected: `SOURCE \ DESTINATION   part1/ns1/client  part2/ns2/server  default/default/admin
+part1/ns1/client       -                 ERROR             deny
+part2/ns2/server       deny              -                 deny
+default/default/admin  deny              deny              -
+part1/ns1/client -> part2/ns2/server: expected the output to contain "\"code\": 200" or "curl: (56) Recv failure: Connection reset by peer", got "curl: (52) Empty reply from server"`,
+		},
+		"failing curl": {
+			// curl times out after part of the response.
+			allowed: []Connection{{client, server}},
+			mesh:    map[string]int{"client->server": 28},
+			denies:  []Connection{{client, server}},
+		},
+		"probe error": {
+			failing: map[string]error{"admin.virtual": errors.New("TargetNotConnectedException")},
+			errors:  []Connection{{client, admin}, {server, admin}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			mesh := &fakeMesh{allowed: c.mesh, failing: c.failing}
+			matrix := ConnectivityMatrix{Tasks: tasks, Allowed: c.allowed, DeniedOutput: c.denied, Target: VirtualTarget, Exec: mesh.exec}
+
+			diff := matrix.Probe()
+			require.Len(t, diff.Results, 6)
+			require.Equal(t, c.allows, connections(diff.UnexpectedAllows()))
+			require.Equal(t, c.denies, connections(diff.UnexpectedDenies()))
+			require.Equal(t, c.errors, connections(diff.Errors()))
+			require.Equal(t, len(c.allows)+len(c.denies)+len(c.errors) == 0, diff.Empty())
+			if c.expected != "" {
+				require.Equal(t, c.expected, diff.String())
+			}
+		})
+	}
+}
+
+func TestConnectivityMatrixStaticTargets(t *testing.T) {
+	client := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "default", Namespace: "ns1"}}
+	server := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "server", Partition: "default", Namespace: "ns2"}}
+
+	var commands []string
+	matrix := ConnectivityMatrix{
+		Tasks:   []*MeshTask{client, server},
+		Allowed: []Connection{{client, server}},
+		Target:  StaticTargets(map[Connection]string{{client, server}: "localhost:1234"}),
+		Exec: func(src *MeshTask, container, command string) (*ecsexec.Result, error) {
+			commands = append(commands, container+" "+command)
+			return &ecsexec.Result{Stdout: `"code": 200`}, nil
+		},
+	}
+
+	diff := matrix.Probe()
+	require.True(t, diff.Empty(), diff.String())
+	require.Equal(t, []string{`basic /bin/sh -c "curl localhost:1234"`}, commands)
+	require.Contains(t, diff.String(), "no route")
+}
+
+func TestConnectivityMatrixDefaultTarget(t *testing.T) {
+	client := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "default", Namespace: "ns1"}}
+	server := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "server", Partition: "default", Namespace: "ns2"}}
+
+	var commands []string
+	matrix := ConnectivityMatrix{
+		Tasks: []*MeshTask{client, server},
+		Exec: func(src *MeshTask, container, command string) (*ecsexec.Result, error) {
+			commands = append(commands, command)
+			return &ecsexec.Result{Stdout: "curl: (52) Empty reply from server", ExitCode: 52}, nil
+		},
+	}
+
+	diff := matrix.Probe()
+	require.True(t, diff.Empty(), diff.String())
+	require.Equal(t, []string{
+		`/bin/sh -c "curl http://server.virtual.ns2.ns.default.ap.consul"`,
+		`/bin/sh -c "curl http://client.virtual.ns1.ns.default.ap.consul"`,
+	}, commands)
+}
+
+func TestConnectivityMatrixVerifyRetries(t *testing.T) {
+	client := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "default", Namespace: "default"}}
+	server := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "server", Partition: "default", Namespace: "default"}}
+
+	mesh := &fakeMesh{allowed: map[string]int{}}
+	matrix := ConnectivityMatrix{
+		Tasks:   []*MeshTask{client, server},
+		Allowed: []Connection{{client, server}},
+		Target:  VirtualTarget,
+		Exec: func(src *MeshTask, container, command string) (*ecsexec.Result, error) {
+			// The intention reaches the proxies after the first probe.
+			defer mesh.allow("client->server")
+			return mesh.exec(src, container, command)
+		},
+	}
+
+	diff := matrix.Verify(t, &retry.Timer{Timeout: 5 * time.Second, Wait: 10 * time.Millisecond})
+	require.True(t, diff.Empty(), diff.String())
+	require.Len(t, mesh.commands, 4)
+
+	// The last diff is returned once the timer expires.
+	matrix.Allowed = nil
+	diff = matrix.Verify(t, &retry.Timer{Timeout: 50 * time.Millisecond, Wait: 10 * time.Millisecond})
+	require.Equal(t, []Connection{{client, server}}, connections(diff.UnexpectedAllows()))
+}
+
+func connections(results []ConnectivityResult) []Connection {
+	var conns []Connection
+	for _, r := range results {
+		conns = append(conns, r.Connection)
+	}
+	return conns
+}
@@ -0,0 +1,42 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+const testClusterARN = "arn:aws:ecs:us-east-1:123456789012:cluster/cluster"
+
+func TestMeshTaskARN(t *testing.T) {
+	fake := fakes.NewECS(t,
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/1", Family: "client"},
+		fakes.Task{ARN: "arn:aws:ecs:us-east-1:123456789012:task/cluster/2", Family: "server"},
+	)
+	client := fake.Client()
+
+	task := NewMeshTask(t, MeshTaskConfig{
+		ConsulClient: fakes.NewConsul(t).Client(t),
+		Name:         "server",
+		Partition:    "default",
+		Namespace:    "default",
+		Region:       "us-east-1",
+		ClusterARN:   testClusterARN,
+		ECSClient:    client,
+	})
+	taskARN, err := task.TaskARN()
+	require.NoError(t, err)
+	require.Equal(t, "arn:aws:ecs:us-east-1:123456789012:task/cluster/2", taskARN)
+
+	// The ARN of a task is only looked up once.
+	fake.FailNext(1, "ServerException")
+	_, err = task.TaskARN()
+	require.NoError(t, err)
+	other := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", ClusterARN: testClusterARN, ECSClient: client}}
+	_, err = other.TaskARN()
+	require.ErrorContains(t, err, "ServerException")
+}
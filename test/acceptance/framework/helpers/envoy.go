@@ -0,0 +1,467 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+)
+
+// defaultEnvoyAdminAddr is the address of the admin API
+// of the Envoy proxy that consul-dataplane runs.
+const defaultEnvoyAdminAddr = "127.0.0.1:19000"
+
+// EnvoyAdmin fetches the state of the Envoy proxy of a mesh task from its
+// admin API. The consul-dataplane image has no shell, so the requests are
+// made with curl from another container of the task, which shares the
+// network namespace of consul-dataplane.
+type EnvoyAdmin struct {
+	Task *MeshTask
+
+	// Container is the container that runs curl. It defaults to "basic".
+	Container string
+
+	// Addr is the address of the admin API. It defaults to 127.0.0.1:19000.
+	Addr string
+
+	// Exec runs the command in the container of the task.
+	// It defaults to MeshTask.Exec.
+	Exec func(task *MeshTask, container, command string) (*ecsexec.Result, error)
+}
+
+// EnvoyAdmin returns a client of the admin API of the task's Envoy proxy.
+func (task *MeshTask) EnvoyAdmin() *EnvoyAdmin {
+	return &EnvoyAdmin{Task: task}
+}
+
+// EnvoySocketAddress is an IP address and port.
+type EnvoySocketAddress struct {
+	Address   string `json:"address"`
+	PortValue int    `json:"port_value"`
+}
+
+func (a EnvoySocketAddress) String() string {
+	return net.JoinHostPort(a.Address, strconv.Itoa(a.PortValue))
+}
+
+type EnvoyAddress struct {
+	SocketAddress EnvoySocketAddress `json:"socket_address"`
+}
+
+// EnvoyClusterStatus is a cluster listed by the /clusters endpoint
+// along with the endpoints of the cluster.
+type EnvoyClusterStatus struct {
+	Name         string            `json:"name"`
+	HostStatuses []EnvoyHostStatus `json:"host_statuses"`
+}
+
+// HealthyHosts returns the addresses of the healthy endpoints of the cluster.
+func (c EnvoyClusterStatus) HealthyHosts() []string {
+	var hosts []string
+	for _, h := range c.HostStatuses {
+		if h.HealthStatus.EDSHealthStatus == "HEALTHY" {
+			hosts = append(hosts, h.Address.SocketAddress.String())
+		}
+	}
+	return hosts
+}
+
+type EnvoyHostStatus struct {
+	Address      EnvoyAddress `json:"address"`
+	HealthStatus struct {
+		EDSHealthStatus string `json:"eds_health_status"`
+	} `json:"health_status"`
+}
+
+// EnvoyListenerStatus is a listener listed by the /listeners endpoint.
+type EnvoyListenerStatus struct {
+	Name         string       `json:"name"`
+	LocalAddress EnvoyAddress `json:"local_address"`
+}
+
+// EnvoyConfigDump holds the clusters and listeners of the /config_dump
+// endpoint, both static and dynamic.
+type EnvoyConfigDump struct {
+	Clusters  []EnvoyCluster
+	Listeners []EnvoyListener
+}
+
+// Cluster returns the cluster with the name.
+func (d *EnvoyConfigDump) Cluster(name string) (EnvoyCluster, bool) {
+	for _, c := range d.Clusters {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return EnvoyCluster{}, false
+}
+
+// ListenerAt returns the listener bound to the address, e.g. 127.0.0.1:1234.
+func (d *EnvoyConfigDump) ListenerAt(addr string) (EnvoyListener, bool) {
+	for _, l := range d.Listeners {
+		if l.Address.SocketAddress.String() == addr {
+			return l, true
+		}
+	}
+	return EnvoyListener{}, false
+}
+
+// PublicListener returns the listener that accepts the inbound
+// connections of the mesh, which enforces the intentions.
+func (d *EnvoyConfigDump) PublicListener() (EnvoyListener, bool) {
+	for _, l := range d.Listeners {
+		if strings.HasPrefix(l.Name, "public_listener:") {
+			return l, true
+		}
+	}
+	return EnvoyListener{}, false
+}
+
+type EnvoyCluster struct {
+	Name            string                `json:"name"`
+	Type            string                `json:"type"`
+	TransportSocket *EnvoyTransportSocket `json:"transport_socket"`
+}
+
+// SubjectAltNames returns the SANs, i.e. SPIFFE IDs, that the certificates
+// of the endpoints of the cluster must match, or nil without TLS.
+func (c EnvoyCluster) SubjectAltNames() []string {
+	if c.TransportSocket == nil {
+		return nil
+	}
+	validation := c.TransportSocket.TypedConfig.CommonTLSContext.ValidationContext
+	var sans []string
+	for _, m := range validation.MatchTypedSubjectAltNames {
+		sans = append(sans, m.Matcher.Exact)
+	}
+	for _, m := range validation.MatchSubjectAltNames {
+		sans = append(sans, m.Exact)
+	}
+	return sans
+}
+
+type EnvoyTransportSocket struct {
+	Name        string          `json:"name"`
+	TypedConfig EnvoyTLSContext `json:"typed_config"`
+}
+
+type EnvoyTLSContext struct {
+	Type             string                `json:"@type"`
+	SNI              string                `json:"sni"`
+	CommonTLSContext EnvoyCommonTLSContext `json:"common_tls_context"`
+}
+
+type EnvoyCommonTLSContext struct {
+	ValidationContext EnvoyValidationContext `json:"validation_context"`
+}
+
+type EnvoyValidationContext struct {
+	MatchTypedSubjectAltNames []EnvoySANMatcher `json:"match_typed_subject_alt_names"`
+	// MatchSubjectAltNames is set by versions of Consul that
+	// predate typed matchers.
+	MatchSubjectAltNames []EnvoyStringMatcher `json:"match_subject_alt_names"`
+}
+
+type EnvoySANMatcher struct {
+	SANType string             `json:"san_type"`
+	Matcher EnvoyStringMatcher `json:"matcher"`
+}
+
+type EnvoyStringMatcher struct {
+	Exact  string `json:"exact"`
+	Prefix string `json:"prefix"`
+}
+
+type EnvoyListener struct {
+	Name         string             `json:"name"`
+	Address      EnvoyAddress       `json:"address"`
+	FilterChains []EnvoyFilterChain `json:"filter_chains"`
+}
+
+// FilterNames returns the names of the network filters of the listener
+// and of the HTTP filters of its HTTP connection managers.
+func (l EnvoyListener) FilterNames() []string {
+	var names []string
+	for _, chain := range l.FilterChains {
+		for _, f := range chain.Filters {
+			names = append(names, f.Name)
+			for _, h := range f.TypedConfig.HTTPFilters {
+				names = append(names, h.Name)
+			}
+		}
+	}
+	return names
+}
+
+// HasRBAC reports whether the listener enforces intentions with a
+// network or HTTP RBAC filter.
+func (l EnvoyListener) HasRBAC() bool {
+	for _, name := range l.FilterNames() {
+		if name == "envoy.filters.network.rbac" || name == "envoy.filters.http.rbac" {
+			return true
+		}
+	}
+	return false
+}
+
+// TCPProxyClusters returns the clusters that the TCP proxy filters of
+// the listener forward to. HTTP listeners route to clusters through
+// route configurations instead.
+func (l EnvoyListener) TCPProxyClusters() []string {
+	var clusters []string
+	for _, chain := range l.FilterChains {
+		for _, f := range chain.Filters {
+			if f.TypedConfig.Cluster != "" {
+				clusters = append(clusters, f.TypedConfig.Cluster)
+			}
+		}
+	}
+	return clusters
+}
+
+type EnvoyFilterChain struct {
+	Filters []EnvoyFilter `json:"filters"`
+}
+
+type EnvoyFilter struct {
+	Name        string            `json:"name"`
+	TypedConfig EnvoyFilterConfig `json:"typed_config"`
+}
+
+// EnvoyFilterConfig holds the fields of the filter types that the
+// proxies of mesh tasks use.
+type EnvoyFilterConfig struct {
+	Type string `json:"@type"`
+
+	// Cluster is the cluster of a TCP proxy filter.
+	Cluster string `json:"cluster"`
+
+	// HTTPFilters are the filters of an HTTP connection manager.
+	HTTPFilters []EnvoyFilter `json:"http_filters"`
+}
+
+// ConfigDump fetches and parses the /config_dump endpoint.
+func (a *EnvoyAdmin) ConfigDump() (*EnvoyConfigDump, error) {
+	var raw struct {
+		Configs []json.RawMessage `json:"configs"`
+	}
+	if err := a.get("/config_dump", &raw); err != nil {
+		return nil, err
+	}
+
+	dump := &EnvoyConfigDump{}
+	for _, config := range raw.Configs {
+		var typed struct {
+			Type string `json:"@type"`
+
+			StaticClusters []struct {
+				Cluster EnvoyCluster `json:"cluster"`
+			} `json:"static_clusters"`
+			DynamicActiveClusters []struct {
+				Cluster EnvoyCluster `json:"cluster"`
+			} `json:"dynamic_active_clusters"`
+
+			StaticListeners []struct {
+				Listener EnvoyListener `json:"listener"`
+			} `json:"static_listeners"`
+			DynamicListeners []struct {
+				ActiveState *struct {
+					Listener EnvoyListener `json:"listener"`
+				} `json:"active_state"`
+			} `json:"dynamic_listeners"`
+		}
+		if err := json.Unmarshal(config, &typed); err != nil {
+			return nil, fmt.Errorf("failed to parse the config dump: %w", err)
+		}
+
+		switch {
+		case strings.HasSuffix(typed.Type, ".ClustersConfigDump"):
+			for _, c := range typed.StaticClusters {
+				dump.Clusters = append(dump.Clusters, c.Cluster)
+			}
+			for _, c := range typed.DynamicActiveClusters {
+				dump.Clusters = append(dump.Clusters, c.Cluster)
+			}
+		case strings.HasSuffix(typed.Type, ".ListenersConfigDump"):
+			for _, l := range typed.StaticListeners {
+				dump.Listeners = append(dump.Listeners, l.Listener)
+			}
+			for _, l := range typed.DynamicListeners {
+				// Listeners that are still warming up or failed to update have no active state.
+				if l.ActiveState != nil {
+					dump.Listeners = append(dump.Listeners, l.ActiveState.Listener)
+				}
+			}
+		}
+	}
+	return dump, nil
+}
+
+// Clusters fetches the clusters of the proxy and their endpoints.
+func (a *EnvoyAdmin) Clusters() ([]EnvoyClusterStatus, error) {
+	var resp struct {
+		ClusterStatuses []EnvoyClusterStatus `json:"cluster_statuses"`
+	}
+	if err := a.get("/clusters?format=json", &resp); err != nil {
+		return nil, err
+	}
+	return resp.ClusterStatuses, nil
+}
+
+// Listeners fetches the listeners of the proxy.
+func (a *EnvoyAdmin) Listeners() ([]EnvoyListenerStatus, error) {
+	var resp struct {
+		ListenerStatuses []EnvoyListenerStatus `json:"listener_statuses"`
+	}
+	if err := a.get("/listeners?format=json", &resp); err != nil {
+		return nil, err
+	}
+	return resp.ListenerStatuses, nil
+}
+
+func (a *EnvoyAdmin) get(path string, v any) error {
+	container := a.Container
+	if container == "" {
+		container = defaultProbeContainer
+	}
+	addr := a.Addr
+	if addr == "" {
+		addr = defaultEnvoyAdminAddr
+	}
+	exec := a.Exec
+	if exec == nil {
+		exec = func(task *MeshTask, container, command string) (*ecsexec.Result, error) {
+			return task.Exec(container, command)
+		}
+	}
+
+	res, err := exec(a.Task, container, fmt.Sprintf(`/bin/sh -c "curl -sS -f '%s%s'"`, addr, path))
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s from the Envoy admin API of %s: %w", path, a.Task, err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("failed to fetch %s from the Envoy admin API of %s, curl exited with %d: %s",
+			path, a.Task, res.ExitCode, strings.TrimSpace(res.Stdout+res.Stderr))
+	}
+	if err := json.Unmarshal([]byte(res.Stdout), v); err != nil {
+		return fmt.Errorf("failed to parse the response to %s from the Envoy admin API of %s: %w", path, a.Task, err)
+	}
+	return nil
+}
+
+// Upstream is an entry of the upstreams input variable of the mesh-task module.
+type Upstream struct {
+	DestinationName      string
+	DestinationNamespace string
+	DestinationPartition string
+	DestinationPeer      string
+
+	Datacenter string
+
+	// LocalBindAddress defaults to 127.0.0.1.
+	LocalBindAddress string
+	LocalBindPort    int
+
+	// MeshGatewayMode is the mode of the meshGateway field.
+	MeshGatewayMode string
+}
+
+func (u Upstream) String() string {
+	name := u.DestinationName
+	if u.DestinationNamespace != "" {
+		name = u.DestinationNamespace + "/" + name
+	}
+	if u.DestinationPeer != "" {
+		name = u.DestinationPeer + "/" + name
+	} else if u.DestinationPartition != "" {
+		name = u.DestinationPartition + "/" + name
+	}
+	return name
+}
+
+// CheckUpstreams fetches the configuration of the proxy and returns how it
+// differs from the upstreams: every upstream must have a listener bound to
+// its local address that forwards to a cluster of the destination, which
+// verifies the SPIFFE ID of the destination and has healthy endpoints.
+func (a *EnvoyAdmin) CheckUpstreams(upstreams ...Upstream) ([]string, error) {
+	dump, err := a.ConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := a.Clusters()
+	if err != nil {
+		return nil, err
+	}
+	return checkUpstreams(dump, clusters, upstreams), nil
+}
+
+func checkUpstreams(dump *EnvoyConfigDump, clusters []EnvoyClusterStatus, upstreams []Upstream) []string {
+	statuses := make(map[string]EnvoyClusterStatus, len(clusters))
+	for _, c := range clusters {
+		statuses[c.Name] = c
+	}
+
+	var problems []string
+	for _, u := range upstreams {
+		addr := localBindAddr(u.LocalBindAddress, u.LocalBindPort)
+
+		listener, ok := dump.ListenerAt(addr)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("upstream %s: no listener bound to %s", u, addr))
+			continue
+		}
+
+		cluster, ok := upstreamCluster(dump, listener, u)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("upstream %s: no cluster for the listener %s", u, listener.Name))
+			continue
+		}
+
+		if !hasSuffix(cluster.SubjectAltNames(), "/svc/"+u.DestinationName) {
+			problems = append(problems, fmt.Sprintf("upstream %s: cluster %s does not verify the SPIFFE ID of %s, SANs %v",
+				u, cluster.Name, u.DestinationName, cluster.SubjectAltNames()))
+		}
+		if len(statuses[cluster.Name].HealthyHosts()) == 0 {
+			problems = append(problems, fmt.Sprintf("upstream %s: cluster %s has no healthy endpoints", u, cluster.Name))
+		}
+	}
+	return problems
+}
+
+// upstreamCluster returns the cluster that the listener of the upstream
+// forwards to. The names of the clusters of upstreams start with the name
+// and namespace of the destination service.
+func upstreamCluster(dump *EnvoyConfigDump, listener EnvoyListener, u Upstream) (EnvoyCluster, bool) {
+	for _, name := range listener.TCPProxyClusters() {
+		if c, ok := dump.Cluster(name); ok {
+			return c, true
+		}
+	}
+
+	namespace := u.DestinationNamespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	prefix := u.DestinationName + "." + namespace + "."
+	for _, c := range dump.Clusters {
+		if strings.HasPrefix(c.Name, prefix) {
+			return c, true
+		}
+	}
+	return EnvoyCluster{}, false
+}
+
+func hasSuffix(values []string, suffix string) bool {
+	for _, v := range values {
+		if strings.HasSuffix(v, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testServerCluster = "server.default.dc1.internal.11111111-2222-3333-4444-555555555555.consul"
+
+	testConfigDump = `{"configs": [
+  {"@type": "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump", "bootstrap": {}},
+  {"@type": "type.googleapis.com/envoy.admin.v3.ClustersConfigDump",
+   "static_clusters": [{"cluster": {"name": "local_agent", "type": "STATIC"}}],
+   "dynamic_active_clusters": [
+    {"version_info": "1", "cluster": {"name": "local_app", "type": "STATIC"}},
+    {"version_info": "1", "cluster": {
+      "name": "` + testServerCluster + `", "type": "EDS",
+      "transport_socket": {"name": "tls", "typed_config": {
+        "@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+        "sni": "` + testServerCluster + `",
+        "common_tls_context": {"validation_context": {"match_typed_subject_alt_names": [
+          {"san_type": "URI", "matcher": {"exact": "spiffe://11111111-2222-3333-4444-555555555555.consul/ns/default/dc/dc1/svc/server"}}
+        ]}}
+      }}
+    }}
+   ]},
+  {"@type": "type.googleapis.com/envoy.admin.v3.ListenersConfigDump",
+   "dynamic_listeners": [
+    {"name": "public_listener:10.0.0.5:20000", "active_state": {"listener": {
+      "name": "public_listener:10.0.0.5:20000",
+      "address": {"socket_address": {"address": "10.0.0.5", "port_value": 20000}},
+      "filter_chains": [{"filters": [
+        {"name": "envoy.filters.network.rbac", "typed_config": {"@type": "type.googleapis.com/envoy.extensions.filters.network.rbac.v3.RBAC"}},
+        {"name": "envoy.filters.network.tcp_proxy", "typed_config": {"@type": "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy", "cluster": "local_app"}}
+      ]}]
+    }}},
+    {"name": "server:127.0.0.1:1234", "active_state": {"listener": {
+      "name": "server:127.0.0.1:1234",
+      "address": {"socket_address": {"address": "127.0.0.1", "port_value": 1234}},
+      "filter_chains": [{"filters": [
+        {"name": "envoy.filters.network.tcp_proxy", "typed_config": {"@type": "type.googleapis.com/envoy.extensions.filters.network.tcp_proxy.v3.TcpProxy", "cluster": "` + testServerCluster + `"}}
+      ]}]
+    }}},
+    {"name": "warming:127.0.0.1:5678", "warming_state": {}}
+   ]}
+]}`
+
+	testClusters = `{"cluster_statuses": [
+  {"name": "local_app", "host_statuses": [{"address": {"socket_address": {"address": "127.0.0.1", "port_value": 9090}}, "health_status": {"eds_health_status": "HEALTHY"}}]},
+  {"name": "` + testServerCluster + `", "host_statuses": [
+    {"address": {"socket_address": {"address": "10.0.1.7", "port_value": 20000}}, "health_status": {"eds_health_status": "HEALTHY"}},
+    {"address": {"socket_address": {"address": "10.0.1.8", "port_value": 20000}}, "health_status": {"eds_health_status": "UNHEALTHY"}}
+  ]}
+]}`
+
+	testListeners = `{"listener_statuses": [
+  {"name": "public_listener:10.0.0.5:20000", "local_address": {"socket_address": {"address": "10.0.0.5", "port_value": 20000}}},
+  {"name": "server:127.0.0.1:1234", "local_address": {"socket_address": {"address": "127.0.0.1", "port_value": 1234}}}
+]}`
+)
+
+// fakeEnvoyAdmin returns an EnvoyAdmin whose commands answer with the
+// responses of the paths. Responses that are errors of curl, e.g.
+// "curl: (7) ...", are returned with the exit code of the error.
+func fakeEnvoyAdmin(responses map[string]string) *EnvoyAdmin {
+	task := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "default", Namespace: "default"}}
+	return &EnvoyAdmin{
+		Task: task,
+		Exec: func(_ *MeshTask, container, command string) (*ecsexec.Result, error) {
+			for path, resp := range responses {
+				if command == fmt.Sprintf(`/bin/sh -c "curl -sS -f '127.0.0.1:19000%s'"`, path) && container == "basic" {
+					res := &ecsexec.Result{Stdout: resp}
+					_, _ = fmt.Sscanf(resp, "curl: (%d)", &res.ExitCode)
+					return res, nil
+				}
+			}
+			return nil, errors.New("unexpected command " + command)
+		},
+	}
+}
+
+func TestEnvoyAdmin(t *testing.T) {
+	admin := fakeEnvoyAdmin(map[string]string{
+		"/config_dump":           testConfigDump,
+		"/clusters?format=json":  testClusters,
+		"/listeners?format=json": testListeners,
+	})
+
+	dump, err := admin.ConfigDump()
+	require.NoError(t, err)
+	require.Len(t, dump.Clusters, 3)
+	require.Len(t, dump.Listeners, 2)
+
+	cluster, ok := dump.Cluster(testServerCluster)
+	require.True(t, ok)
+	require.Equal(t, "EDS", cluster.Type)
+	require.Equal(t, testServerCluster, cluster.TransportSocket.TypedConfig.SNI)
+	require.Equal(t, []string{"spiffe://11111111-2222-3333-4444-555555555555.consul/ns/default/dc/dc1/svc/server"}, cluster.SubjectAltNames())
+
+	public, ok := dump.PublicListener()
+	require.True(t, ok)
+	require.True(t, public.HasRBAC())
+	require.Equal(t, []string{"envoy.filters.network.rbac", "envoy.filters.network.tcp_proxy"}, public.FilterNames())
+
+	upstream, ok := dump.ListenerAt("127.0.0.1:1234")
+	require.True(t, ok)
+	require.False(t, upstream.HasRBAC())
+	require.Equal(t, []string{testServerCluster}, upstream.TCPProxyClusters())
+
+	clusters, err := admin.Clusters()
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+	require.Equal(t, []string{"10.0.1.7:20000"}, clusters[1].HealthyHosts())
+
+	listeners, err := admin.Listeners()
+	require.NoError(t, err)
+	require.Equal(t, []EnvoyListenerStatus{
+		{Name: "public_listener:10.0.0.5:20000", LocalAddress: EnvoyAddress{EnvoySocketAddress{Address: "10.0.0.5", PortValue: 20000}}},
+		{Name: "server:127.0.0.1:1234", LocalAddress: EnvoyAddress{EnvoySocketAddress{Address: "127.0.0.1", PortValue: 1234}}},
+	}, listeners)
+}
+
+func TestEnvoyAdminErrors(t *testing.T) {
+	admin := fakeEnvoyAdmin(map[string]string{
+		"/config_dump":           "curl: (7) Failed to connect to 127.0.0.1 port 19000\n",
+		"/listeners?format=json": "Starting session with SessionId: ecs-execute-command-0123",
+	})
+
+	_, err := admin.ConfigDump()
+	require.EqualError(t, err, "failed to fetch /config_dump from the Envoy admin API of default/default/client, curl exited with 7: curl: (7) Failed to connect to 127.0.0.1 port 19000")
+
+	_, err = admin.Listeners()
+	require.ErrorContains(t, err, "failed to parse the response to /listeners?format=json from the Envoy admin API of default/default/client")
+
+	_, err = admin.Clusters()
+	require.ErrorContains(t, err, "failed to fetch /clusters?format=json from the Envoy admin API of default/default/client")
+}
+
+func TestCheckUpstreams(t *testing.T) {
+	admin := fakeEnvoyAdmin(map[string]string{
+		"/config_dump":          testConfigDump,
+		"/clusters?format=json": testClusters,
+	})
+
+	cases := map[string]struct {
+		upstreams []Upstream
+		problems  []string
+	}{
+		"matching upstream": {
+			upstreams: []Upstream{{DestinationName: "server", LocalBindPort: 1234}},
+		},
+		"missing listener": {
+			upstreams: []Upstream{{DestinationName: "server", DestinationNamespace: "ns1", LocalBindPort: 5678}},
+			problems:  []string{"upstream ns1/server: no listener bound to 127.0.0.1:5678"},
+		},
+		"unexpected destination": {
+			upstreams: []Upstream{{DestinationName: "other", DestinationPartition: "part1", LocalBindPort: 1234}},
+			problems: []string{
+				"upstream part1/other: cluster " + testServerCluster + " does not verify the SPIFFE ID of other, " +
+					"SANs [spiffe://11111111-2222-3333-4444-555555555555.consul/ns/default/dc/dc1/svc/server]",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			problems, err := admin.CheckUpstreams(c.upstreams...)
+			require.NoError(t, err)
+			require.Equal(t, c.problems, problems)
+		})
+	}
+}
+
+func TestCheckUpstreamsWithoutHealthyEndpoints(t *testing.T) {
+	admin := fakeEnvoyAdmin(map[string]string{"/config_dump": testConfigDump})
+
+	dump, err := admin.ConfigDump()
+	require.NoError(t, err)
+	problems := checkUpstreams(dump, nil, []Upstream{{DestinationName: "server", LocalBindPort: 1234}})
+	require.Equal(t, []string{"upstream server: cluster " + testServerCluster + " has no healthy endpoints"}, problems)
+}
@@ -4,31 +4,41 @@
 package helpers
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/shell"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/config"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulstate"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
 )
 
 // ExecuteRemoteCommand executes a command inside a container in the task specified
-// by taskARN.
+// by taskARN and returns its output.
 func ExecuteRemoteCommand(t *testing.T, testConfig *config.TestConfig, clusterARN, taskARN, container, command string) (string, error) {
-	return shell.RunCommandAndGetOutputE(t, shell.Command{
-		Command: "aws",
-		Args: []string{
-			"ecs",
-			"execute-command",
-			"--region",
-			testConfig.Region,
-			"--cluster",
-			clusterARN,
-			"--task",
-			taskARN,
-			fmt.Sprintf("--container=%s", container),
-			"--command",
-			command,
-			"--interactive",
-		},
-	})
+	client, err := ecsapi.NewClient(context.Background(), testConfig.Region)
+	if err != nil {
+		return "", err
+	}
+	return ecsapi.ExecuteCommand(context.Background(), client, clusterARN, taskARN, container, command)
+}
+
+// ListServerLoginTokens lists the login tokens of the Consul server that runs
+// in the task specified by taskARN, for tests that cannot reach the server.
+// The tokens are listed with curl from the consul-server container, which
+// holds the bootstrap token.
+func ListServerLoginTokens(t *testing.T, testConfig *config.TestConfig, clusterARN, taskARN string) ([]consulstate.LoginToken, error) {
+	client, err := ecsapi.NewClient(context.Background(), testConfig.Region)
+	if err != nil {
+		return nil, err
+	}
+	res, err := ecsapi.Exec(context.Background(), client, clusterARN, taskARN, "consul-server",
+		`/bin/sh -c 'curl -sS -f -H "X-Consul-Token: $CONSUL_HTTP_TOKEN" localhost:8500/v1/acl/tokens'`)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("failed to list the tokens, curl exited with %d: %s", res.ExitCode, res.Stdout+res.Stderr)
+	}
+	return consulstate.ParseLoginTokens([]byte(res.Stdout))
 }
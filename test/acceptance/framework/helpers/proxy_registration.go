@@ -0,0 +1,173 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// defaultPublicListenerPort and defaultReadinessPort are the defaults of the
+	// envoy_public_listener_port and envoy_readiness_port inputs of the
+	// mesh-task module.
+	defaultPublicListenerPort = 20000
+	defaultReadinessPort      = 22000
+
+	// readyListenerName is the name of the listener that
+	// consul-dataplane adds to Envoy for readiness checks.
+	readyListenerName = "envoy_ready_listener"
+)
+
+// ProxyInputs are the inputs of the mesh-task module that consul-ecs
+// turns into the registration of the sidecar proxy of the task.
+type ProxyInputs struct {
+	Upstreams []Upstream
+
+	// PublicListenerPort is the envoy_public_listener_port input.
+	// It defaults to 20000.
+	PublicListenerPort int
+
+	// ReadinessPort is the envoy_readiness_port input. It defaults to 22000.
+	// consul-ecs does not register it in the catalog: it passes it to
+	// consul-dataplane, which binds the ready listener of Envoy to it, so it
+	// is compared with the listeners of the proxy instead.
+	ReadinessPort int
+
+	// MeshGatewayMode is the proxy.meshGateway.mode field of
+	// the consul_ecs_config input.
+	MeshGatewayMode string
+}
+
+// CheckProxyRegistration reads the registrations of the sidecar proxy of
+// every instance of the task from the catalog and returns how they differ
+// from the inputs, one line per field. Upstreams are matched by their local
+// bind address. The readiness port is read from the Envoy admin API.
+func (task *MeshTask) CheckProxyRegistration(inputs ProxyInputs) ([]string, error) {
+	return task.checkProxyRegistration(inputs, task.EnvoyAdmin())
+}
+
+func (task *MeshTask) checkProxyRegistration(inputs ProxyInputs, envoy *EnvoyAdmin) ([]string, error) {
+	name := task.Name + "-sidecar-proxy"
+	entries, _, err := task.ConsulClient.Health().Service(name, "", false, task.QueryOpts())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the registration of %s/%s/%s: %w", task.Partition, task.Namespace, name, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s/%s/%s is not registered", task.Partition, task.Namespace, name)
+	}
+
+	var problems []string
+	for _, entry := range entries {
+		for _, diff := range task.diffProxyRegistration(entry.Service, inputs) {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Service.ID, diff))
+		}
+	}
+
+	listeners, err := envoy.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, diffReadinessPort(listeners, inputs.ReadinessPort)...), nil
+}
+
+// diffReadinessPort compares the port of the ready listener
+// of the proxy with the envoy_readiness_port input.
+func diffReadinessPort(listeners []EnvoyListenerStatus, port int) []string {
+	if port == 0 {
+		port = defaultReadinessPort
+	}
+	for _, l := range listeners {
+		if l.Name == readyListenerName {
+			if actual := l.LocalAddress.SocketAddress.PortValue; actual != port {
+				return []string{fmt.Sprintf("envoy: readinessPort: expected %q, got %q", fmt.Sprint(port), fmt.Sprint(actual))}
+			}
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("envoy: readinessPort: expected %q, got no %s", fmt.Sprint(port), readyListenerName)}
+}
+
+func (task *MeshTask) diffProxyRegistration(svc *api.AgentService, inputs ProxyInputs) []string {
+	var diffs []string
+	diff := func(field string, expected, actual any) {
+		if expected != actual {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %q, got %q", field, fmt.Sprint(expected), fmt.Sprint(actual)))
+		}
+	}
+
+	diff("kind", string(api.ServiceKindConnectProxy), string(svc.Kind))
+	port := inputs.PublicListenerPort
+	if port == 0 {
+		port = defaultPublicListenerPort
+	}
+	diff("publicListenerPort", port, svc.Port)
+
+	proxy := svc.Proxy
+	if proxy == nil {
+		return append(diffs, "proxy: expected the proxy configuration, got none")
+	}
+	diff("destinationServiceName", task.Name, proxy.DestinationServiceName)
+	diff("meshGateway.mode", inputs.MeshGatewayMode, string(proxy.MeshGateway.Mode))
+
+	registered := make(map[string]api.Upstream, len(proxy.Upstreams))
+	for _, u := range proxy.Upstreams {
+		registered[localBindAddr(u.LocalBindAddress, u.LocalBindPort)] = u
+	}
+	expected := make(map[string]bool, len(inputs.Upstreams))
+	for _, u := range inputs.Upstreams {
+		addr := localBindAddr(u.LocalBindAddress, u.LocalBindPort)
+		expected[addr] = true
+		actual, ok := registered[addr]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("upstreams[%s]: expected %s, got none", addr, u))
+			continue
+		}
+
+		field := func(name string) string { return fmt.Sprintf("upstreams[%s].%s", addr, name) }
+		diff(field("destinationName"), u.DestinationName, actual.DestinationName)
+		diff(field("destinationPeer"), u.DestinationPeer, actual.DestinationPeer)
+		diff(field("datacenter"), u.Datacenter, actual.Datacenter)
+		diff(field("meshGateway.mode"), u.MeshGatewayMode, string(actual.MeshGateway.Mode))
+		// Consul defaults the namespace and partition of upstreams to the
+		// ones of the proxy. Upstreams of peers have no partition.
+		diff(field("destinationNamespace"), orDefault(u.DestinationNamespace, task.Namespace), orDefault(actual.DestinationNamespace, task.Namespace))
+		if u.DestinationPeer == "" {
+			diff(field("destinationPartition"), orDefault(u.DestinationPartition, task.Partition), orDefault(actual.DestinationPartition, task.Partition))
+		}
+	}
+
+	var unexpected []string
+	for addr, u := range registered {
+		if !expected[addr] {
+			unexpected = append(unexpected, fmt.Sprintf("upstreams[%s]: expected none, got %s", addr, registeredUpstream(u)))
+		}
+	}
+	sort.Strings(unexpected)
+	return append(diffs, unexpected...)
+}
+
+func localBindAddr(address string, port int) string {
+	return net.JoinHostPort(orDefault(address, "127.0.0.1"), strconv.Itoa(port))
+}
+
+func registeredUpstream(u api.Upstream) Upstream {
+	return Upstream{
+		DestinationName:      u.DestinationName,
+		DestinationNamespace: u.DestinationNamespace,
+		DestinationPartition: u.DestinationPartition,
+		DestinationPeer:      u.DestinationPeer,
+	}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,159 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+func testProxyRegistration() *api.AgentService {
+	return &api.AgentService{
+		ID:      "client-task1-sidecar-proxy",
+		Service: "client-sidecar-proxy",
+		Kind:    api.ServiceKindConnectProxy,
+		Port:    20000,
+		Proxy: &api.AgentServiceConnectProxyConfig{
+			DestinationServiceName: "client",
+			Upstreams: []api.Upstream{
+				{DestinationName: "server", LocalBindPort: 1234},
+				{DestinationName: "server", DestinationPeer: "dc2", LocalBindAddress: "127.0.0.2", LocalBindPort: 1234, MeshGateway: api.MeshGatewayConfig{Mode: api.MeshGatewayModeLocal}},
+			},
+		},
+	}
+}
+
+func TestDiffProxyRegistration(t *testing.T) {
+	task := &MeshTask{MeshTaskConfig: MeshTaskConfig{Name: "client", Partition: "part1", Namespace: "ns1"}}
+	upstreams := []Upstream{
+		{DestinationName: "server", DestinationNamespace: "ns1", LocalBindPort: 1234},
+		{DestinationName: "server", DestinationPeer: "dc2", LocalBindAddress: "127.0.0.2", LocalBindPort: 1234, MeshGatewayMode: "local"},
+	}
+
+	cases := map[string]struct {
+		inputs ProxyInputs
+		modify func(svc *api.AgentService)
+		diffs  []string
+	}{
+		"matching registration": {
+			inputs: ProxyInputs{Upstreams: upstreams},
+		},
+		"public listener port": {
+			inputs: ProxyInputs{Upstreams: upstreams, PublicListenerPort: 21000},
+			diffs:  []string{`publicListenerPort: expected "21000", got "20000"`},
+		},
+		"upstream fields": {
+			inputs: ProxyInputs{Upstreams: upstreams},
+			modify: func(svc *api.AgentService) {
+				u := &svc.Proxy.Upstreams[0]
+				u.DestinationName = "other"
+				u.DestinationNamespace = "ns2"
+				u.DestinationPartition = "part2"
+				u.Datacenter = "dc3"
+			},
+			diffs: []string{
+				`upstreams[127.0.0.1:1234].destinationName: expected "server", got "other"`,
+				`upstreams[127.0.0.1:1234].datacenter: expected "", got "dc3"`,
+				`upstreams[127.0.0.1:1234].destinationNamespace: expected "ns1", got "ns2"`,
+				`upstreams[127.0.0.1:1234].destinationPartition: expected "part1", got "part2"`,
+			},
+		},
+		"missing and unexpected upstreams": {
+			inputs: ProxyInputs{Upstreams: []Upstream{
+				upstreams[0],
+				{DestinationName: "db", DestinationPartition: "part2", LocalBindPort: 5432},
+			}},
+			diffs: []string{
+				"upstreams[127.0.0.1:5432]: expected part2/db, got none",
+				"upstreams[127.0.0.2:1234]: expected none, got dc2/server",
+			},
+		},
+		"mesh gateway modes": {
+			inputs: ProxyInputs{Upstreams: upstreams, MeshGatewayMode: "local"},
+			modify: func(svc *api.AgentService) {
+				svc.Proxy.Upstreams[1].MeshGateway.Mode = api.MeshGatewayModeRemote
+			},
+			diffs: []string{
+				`meshGateway.mode: expected "local", got ""`,
+				`upstreams[127.0.0.2:1234].meshGateway.mode: expected "local", got "remote"`,
+			},
+		},
+		"not a proxy": {
+			inputs: ProxyInputs{Upstreams: upstreams},
+			modify: func(svc *api.AgentService) {
+				svc.Kind = api.ServiceKindTypical
+				svc.Proxy = nil
+			},
+			diffs: []string{
+				`kind: expected "connect-proxy", got ""`,
+				"proxy: expected the proxy configuration, got none",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			svc := testProxyRegistration()
+			if c.modify != nil {
+				c.modify(svc)
+			}
+			require.Equal(t, c.diffs, task.diffProxyRegistration(svc, c.inputs))
+		})
+	}
+}
+
+func TestCheckProxyRegistration(t *testing.T) {
+	registration := testProxyRegistration()
+	consul := fakes.NewConsul(t, fakes.ServiceInstance{
+		ID:        registration.ID,
+		Service:   registration.Service,
+		Namespace: "ns1",
+		Kind:      registration.Kind,
+		Port:      registration.Port,
+		Proxy:     registration.Proxy,
+	})
+	task := &MeshTask{MeshTaskConfig: MeshTaskConfig{ConsulClient: consul.Client(t), Name: "client", Partition: "default", Namespace: "ns1"}}
+	upstreams := []Upstream{
+		{DestinationName: "server", LocalBindPort: 1234},
+		{DestinationName: "server", DestinationPeer: "dc2", LocalBindAddress: "127.0.0.2", LocalBindPort: 1234, MeshGatewayMode: "local"},
+	}
+	envoy := fakeEnvoyAdmin(map[string]string{"/listeners?format=json": `{"listener_statuses": [
+  {"name": "public_listener:10.0.0.5:20000", "local_address": {"socket_address": {"address": "10.0.0.5", "port_value": 20000}}},
+  {"name": "envoy_ready_listener", "local_address": {"socket_address": {"address": "127.0.0.1", "port_value": 22000}}}
+]}`})
+
+	problems, err := task.checkProxyRegistration(ProxyInputs{Upstreams: upstreams[:1]}, envoy)
+	require.NoError(t, err)
+	require.Equal(t, []string{"client-task1-sidecar-proxy: upstreams[127.0.0.2:1234]: expected none, got dc2/server"}, problems)
+
+	problems, err = task.checkProxyRegistration(ProxyInputs{Upstreams: upstreams, ReadinessPort: 21000}, envoy)
+	require.NoError(t, err)
+	require.Equal(t, []string{`envoy: readinessPort: expected "21000", got "22000"`}, problems)
+
+	_, err = task.checkProxyRegistration(ProxyInputs{Upstreams: upstreams}, fakeEnvoyAdmin(nil))
+	require.ErrorContains(t, err, "failed to fetch /listeners?format=json from the Envoy admin API")
+
+	task.Namespace = "default"
+	_, err = task.checkProxyRegistration(ProxyInputs{}, envoy)
+	require.EqualError(t, err, "default/default/client-sidecar-proxy is not registered")
+
+	consul.FailNext(1, http.StatusForbidden)
+	_, err = task.checkProxyRegistration(ProxyInputs{}, envoy)
+	require.ErrorContains(t, err, "failed to read the registration of default/default/client-sidecar-proxy")
+}
+
+func TestDiffReadinessPort(t *testing.T) {
+	ready := func(port int) EnvoyListenerStatus {
+		return EnvoyListenerStatus{Name: "envoy_ready_listener", LocalAddress: EnvoyAddress{EnvoySocketAddress{Address: "127.0.0.1", PortValue: port}}}
+	}
+
+	require.Empty(t, diffReadinessPort([]EnvoyListenerStatus{ready(22000)}, 0))
+	require.Empty(t, diffReadinessPort([]EnvoyListenerStatus{ready(21000)}, 21000))
+	require.Equal(t, []string{`envoy: readinessPort: expected "22000", got "21000"`}, diffReadinessPort([]EnvoyListenerStatus{ready(21000)}, 0))
+	require.Equal(t, []string{`envoy: readinessPort: expected "22000", got no envoy_ready_listener`}, diffReadinessPort(nil, 0))
+}
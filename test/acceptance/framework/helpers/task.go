@@ -4,12 +4,13 @@
 package helpers
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os/exec"
 	"testing"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsexec"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,6 +22,9 @@ type MeshTaskConfig struct {
 	Namespace    string
 	Region       string
 	ClusterARN   string
+
+	// ECSClient defaults to a client of the ECS API of the Region.
+	ECSClient ecsapi.API
 }
 
 // MeshTask represents a Consul ECS mesh task and provides utility functions for interacting with it.
@@ -65,67 +69,60 @@ func (task *MeshTask) Healthy() bool {
 	return false
 }
 
-// ExecuteCommand runs the command in the given container for the task.
-func (task *MeshTask) ExecuteCommand(container, command string) (string, error) {
+// Exec runs the command in the given container for the task
+// and returns its output and exit code.
+func (task *MeshTask) Exec(container, command string) (*ecsexec.Result, error) {
 	taskARN, err := task.TaskARN()
 	if err != nil {
-		return "", fmt.Errorf("failed to get task ARN: %w", err)
-	}
-	args := []string{
-		"ecs",
-		"execute-command",
-		"--interactive",
-		"--region", task.Region,
-		"--cluster", task.ClusterARN,
-		"--task", taskARN,
-		"--container", container,
-		"--command", command,
+		return nil, fmt.Errorf("failed to get task ARN: %w", err)
 	}
-	cmd := exec.Command("aws", args...)
-	cmdOutput, err := cmd.CombinedOutput()
+	return ecsapi.Exec(context.Background(), task.ECSClient, task.ClusterARN, taskARN, container, command)
+}
+
+// ExecuteCommand runs the command in the given container for the task
+// and returns its output, whatever its exit code.
+func (task *MeshTask) ExecuteCommand(container, command string) (string, error) {
+	res, err := task.Exec(container, command)
 	if err != nil {
-		return "", fmt.Errorf("failed to exec `aws %v`: %w", args, err)
+		return "", err
 	}
-	return string(cmdOutput), nil
+	return res.Stdout + res.Stderr, nil
 }
 
 // TaskARN returns the ARN of the task instance for the service.
 // If the ARN is already known it is returned, otherwise it is
-// retrieved using the aws CLI using the properties of the service.
+// retrieved from the ECS API using the properties of the service.
 func (task *MeshTask) TaskARN() (string, error) {
 	// if the task ARN is already known then return it.
 	if task.taskARN != "" {
 		return task.taskARN, nil
 	}
 
-	args := []string{
-		"ecs",
-		"list-tasks",
-		"--region", task.Region,
-		"--cluster", task.ClusterARN,
-		"--family", task.Name,
+	ctx := context.Background()
+	if task.ECSClient == nil {
+		client, err := ecsapi.NewClient(ctx, task.Region)
+		if err != nil {
+			return "", err
+		}
+		task.ECSClient = client
 	}
-	cmd := exec.Command("aws", args...)
-	taskListOut, err := cmd.CombinedOutput()
+	taskARNs, err := ecsapi.ListTasks(ctx, task.ECSClient, task.ClusterARN, ecsapi.ListTasksFilter{Family: task.Name})
 	if err != nil {
-		return "", fmt.Errorf("failed to exec `aws %v`: %w", args, err)
-	}
-
-	type listTasksResponse struct {
-		TaskARNs []string `json:"taskArns"`
+		return "", err
 	}
-	var tasks listTasksResponse
-	if err = json.Unmarshal([]byte(taskListOut), &tasks); err != nil {
-		return "", fmt.Errorf("failed to unmarshal list-task response: %w", err)
-	}
-	if len(tasks.TaskARNs) < 1 {
+	if len(taskARNs) < 1 {
 		return "", fmt.Errorf("failed to find task ARN for %s", task.Name)
 	}
 
-	task.taskARN = tasks.TaskARNs[0]
+	task.taskARN = taskARNs[0]
 	return task.taskARN, nil
 }
 
+// String returns the partition, namespace and name of the task's service.
+func (task *MeshTask) String() string {
+	return fmt.Sprintf("%s/%s/%s", task.Partition, task.Namespace, task.Name)
+}
+
 // QueryOpts returns the Consul API query options for the task.
 func (task *MeshTask) QueryOpts() *api.QueryOptions {
 	return &api.QueryOptions{
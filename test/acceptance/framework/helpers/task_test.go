@@ -0,0 +1,46 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/fakes"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeshTaskRegisteredAndHealthy(t *testing.T) {
+	consul := fakes.NewConsul(t, fakes.ServiceInstance{ID: "server-1", Service: "server", Partition: "part1", Namespace: "ns1", Status: api.HealthCritical})
+	task := NewMeshTask(t, MeshTaskConfig{
+		ConsulClient: consul.Client(t),
+		Name:         "server",
+		Partition:    "part1",
+		Namespace:    "ns1",
+		Region:       "us-east-1",
+		ClusterARN:   "arn:aws:ecs:us-east-1:123456789012:cluster/cluster",
+	})
+	require.True(t, task.Registered())
+	require.False(t, task.Healthy())
+
+	consul.SetStatus("server-1", api.HealthPassing)
+	require.True(t, task.Healthy())
+
+	// Errors are reported as the task not being registered or healthy.
+	consul.FailNext(2, http.StatusInternalServerError)
+	require.False(t, task.Registered())
+	require.False(t, task.Healthy())
+
+	// Instances of other namespaces do not count.
+	other := NewMeshTask(t, MeshTaskConfig{
+		ConsulClient: consul.Client(t),
+		Name:         "server",
+		Partition:    "part1",
+		Namespace:    "ns2",
+		Region:       "us-east-1",
+		ClusterARN:   "arn:aws:ecs:us-east-1:123456789012:cluster/cluster",
+	})
+	require.False(t, other.Registered())
+}
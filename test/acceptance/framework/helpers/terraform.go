@@ -0,0 +1,161 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	terratestLogger "github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulstate"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	defaultStateFile = "terraform.tfstate"
+
+	// defaultTokenGracePeriod is the time within which the controller
+	// must delete the ACL tokens of stopped tasks.
+	defaultTokenGracePeriod = 2 * time.Minute
+)
+
+// TokenCheck checks that the ACL tokens that the tasks of the ECS services
+// of a deployment obtained by logging in are deleted once the services are
+// destroyed.
+type TokenCheck struct {
+	// Targets are the addresses of the ECS services in the Terraform
+	// configuration, e.g. aws_ecs_service.test_client. They are destroyed
+	// before the rest of the deployment, while the controller that deletes
+	// the tokens of stopped tasks still runs.
+	Targets []string
+
+	// Services are the names of the Consul services of the tasks.
+	Services []string
+
+	// Region is the region of the ECS clusters of the tasks.
+	Region string
+
+	// Grace is the time within which the tokens must be deleted once
+	// their task stopped. It defaults to two minutes.
+	Grace time.Duration
+
+	// ListTokens lists the login tokens of the Consul cluster.
+	ListTokens consulstate.LoginTokenLister
+}
+
+// Destroy destroys the deployment of tfOpts. Given a token check, the ECS
+// services are destroyed first and the ACL tokens of their tasks are checked
+// to be deleted, unless the services fail to be destroyed. The rest of the
+// deployment is destroyed in any case. The returned error joins the errors
+// of every step.
+func Destroy(t *testing.T, tfOpts *terraform.Options, check *TokenCheck) error {
+	var errs []error
+	if check != nil {
+		errs = append(errs, check.destroyServices(t, tfOpts))
+	}
+	if _, err := terraform.DestroyE(t, tfOpts); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *TokenCheck) destroyServices(t *testing.T, tfOpts *terraform.Options) error {
+	servicesOpts := *tfOpts
+	servicesOpts.Targets = c.Targets
+	if _, err := terraform.DestroyE(t, &servicesOpts); err != nil {
+		return fmt.Errorf("failed to destroy the ECS services, skipping the token check: %w", err)
+	}
+
+	logger.Log(t, "checking that the ACL tokens of the destroyed tasks are deleted")
+	// The context of the test is canceled before its cleanups run.
+	ctx := context.Background()
+	ecsClient, err := ecsapi.NewClient(ctx, c.Region)
+	if err != nil {
+		return err
+	}
+
+	grace := c.Grace
+	if grace == 0 {
+		grace = defaultTokenGracePeriod
+	}
+	check := consulstate.TokenLeakCheck{
+		Services: c.Services,
+		Grace:    grace,
+		TaskStopTime: func(cluster, taskID string) (time.Time, error) {
+			return ecsapi.TaskStoppedAt(ctx, ecsClient, cluster, taskID)
+		},
+	}
+	return check.WaitWith(t, c.ListTokens)
+}
+
+// ExistingStateOutputs returns the outputs recorded in the terraform state file
+// of terraformDir so that tests can validate an existing deployment instead of
+// applying it again. The state file defaults to terraform.tfstate when empty.
+// It returns an error if the state file is missing or holds no outputs.
+func ExistingStateOutputs(t *testing.T, terraformDir, stateFile string) (map[string]interface{}, error) {
+	if stateFile == "" {
+		stateFile = defaultStateFile
+	}
+
+	if _, err := os.Stat(filepath.Join(terraformDir, stateFile)); err != nil {
+		return nil, fmt.Errorf("cannot reuse the terraform state in %s: %w", terraformDir, err)
+	}
+
+	outputs, err := terraform.OutputAllE(t, &terraform.Options{
+		TerraformDir: terraformDir,
+		NoColor:      true,
+		Logger:       terratestLogger.Default,
+		EnvVars: map[string]string{
+			"TF_CLI_ARGS_output": fmt.Sprintf("-state=%s", stateFile),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading outputs from the terraform state in %s: %w", terraformDir, err)
+	}
+
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("the terraform state in %s has no outputs, nothing seems to be deployed", terraformDir)
+	}
+
+	return outputs, nil
+}
+
+// ReuseState returns the outputs of the existing deployment that the test
+// validates instead of applying terraformDir, when reuseState, the value of
+// -reuse-state, is that working directory. The test is skipped when
+// reuseState is another directory, since it holds the deployment of a single
+// test, and fails when the state or any of the required outputs is missing.
+// It returns nil if reuseState is empty.
+func ReuseState(t *testing.T, reuseState, terraformDir string, required ...string) map[string]interface{} {
+	t.Helper()
+	if reuseState == "" {
+		return nil
+	}
+
+	reuseDir, err := filepath.Abs(reuseState)
+	require.NoError(t, err)
+	testDir, err := filepath.Abs(terraformDir)
+	require.NoError(t, err)
+	if reuseDir != testDir {
+		t.Skipf("-reuse-state holds the deployment in %s, not the one in %s", reuseState, terraformDir)
+	}
+
+	outputs, err := ExistingStateOutputs(t, terraformDir, "")
+	require.NoError(t, err)
+	for _, name := range required {
+		_, ok := outputs[name]
+		require.True(t, ok, "the terraform state in %s has no %s output, it does not belong to %s", terraformDir, name, t.Name())
+	}
+
+	logger.Log(t, fmt.Sprintf("reusing the deployment in %s, skipping terraform apply and destroy", terraformDir))
+	return outputs
+}
@@ -0,0 +1,34 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReuseState(t *testing.T) {
+	cases := map[string]struct {
+		reuseState  string
+		wantSkipped bool
+	}{
+		"no reuse": {},
+		"deployment of another test": {
+			reuseState:  "./terraform/ns",
+			wantSkipped: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var skipped bool
+			t.Run("test", func(t *testing.T) {
+				t.Cleanup(func() { skipped = t.Skipped() })
+				require.Nil(t, ReuseState(t, c.reuseState, "./terraform/hcp-install", "suffix"))
+			})
+			require.Equal(t, c.wantSkipped, skipped)
+		})
+	}
+}
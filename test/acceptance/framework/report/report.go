@@ -0,0 +1,313 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPassed  Status = "passed"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+
+	// artifactsDir is the directory, relative to the report's
+	// directory, holding the artifacts collected by the tests.
+	artifactsDir = "artifacts"
+)
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Report records the outcome and the timings of tests and writes them to
+// a directory as JUnit XML and JSON. A nil *Report is valid and records
+// nothing, so that tests do not need to check if reporting is enabled.
+type Report struct {
+	name string
+	dir  string
+
+	mu    sync.Mutex
+	cases []*Case
+}
+
+// Case is a single test tracked by a report, made of the steps it ran.
+type Case struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	StartTime time.Time `json:"start_time"`
+	Duration  Duration  `json:"duration_seconds"`
+	Failure   string    `json:"failure,omitempty"`
+	Steps     []*Step   `json:"steps,omitempty"`
+	Artifacts []string  `json:"artifacts,omitempty"`
+
+	report  *Report
+	t       testing.TB
+	mu      sync.Mutex
+	current *Step
+}
+
+// Step is a timed part of a test, e.g. terraform apply or a scenario's validation.
+type Step struct {
+	Name      string   `json:"name"`
+	Status    Status   `json:"status"`
+	Duration  Duration `json:"duration_seconds"`
+	Failure   string   `json:"failure,omitempty"`
+	Artifacts []string `json:"artifacts,omitempty"`
+}
+
+// Duration is a time.Duration that is encoded as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).Seconds())
+}
+
+func (d Duration) seconds() string {
+	return fmt.Sprintf("%.3f", time.Duration(d).Seconds())
+}
+
+// New returns a report named name that is written to dir. It
+// returns nil, i.e. reporting is disabled, if dir is empty.
+func New(name, dir string) *Report {
+	if dir == "" {
+		return nil
+	}
+	return &Report{name: name, dir: dir}
+}
+
+// Track starts recording the given test. The status and the duration of the
+// test are recorded once the test and all of its cleanup functions complete.
+func (r *Report) Track(t testing.TB) *Case {
+	if r == nil {
+		return nil
+	}
+
+	c := &Case{
+		Name:      t.Name(),
+		StartTime: time.Now(),
+		report:    r,
+		t:         t,
+	}
+
+	r.mu.Lock()
+	r.cases = append(r.cases, c)
+	r.mu.Unlock()
+
+	// Cleanup functions run in the reverse order of their registration
+	// so this runs after the ones registered by the test itself.
+	t.Cleanup(c.finish)
+
+	return c
+}
+
+// Step runs fn as a step of the case named name and records its duration
+// and outcome. The test is failed with the error returned by fn, if any.
+// The step is also recorded as failed if fn fails the test itself, e.g.
+// by using require.
+func (c *Case) Step(t testing.TB, name string, fn func() error) {
+	step := &Step{Name: name, Status: StatusPassed}
+	if c != nil {
+		c.mu.Lock()
+		c.Steps = append(c.Steps, step)
+		c.current = step
+		c.mu.Unlock()
+	}
+
+	failedBefore := t.Failed()
+	start := time.Now()
+
+	defer func() {
+		if c != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.current = nil
+		}
+
+		step.Duration = Duration(time.Since(start))
+		switch {
+		case step.Failure != "" || (t.Failed() && !failedBefore):
+			step.Status = StatusFailed
+			if step.Failure == "" {
+				step.Failure = fmt.Sprintf("%s failed, see the output of %s", name, t.Name())
+			}
+		case t.Skipped():
+			step.Status = StatusSkipped
+		}
+	}()
+
+	if err := fn(); err != nil {
+		step.Failure = err.Error()
+		t.Fatal(err)
+	}
+}
+
+// WriteArtifact stores contents as an artifact of the case and links it to
+// the step in progress, if any, or to the case itself.
+func (c *Case) WriteArtifact(name string, contents []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	rel := filepath.Join(artifactsDir, unsafePathChars.ReplaceAllString(c.Name, "_"), name)
+	path := filepath.Join(c.report.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, contents, 0644); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil {
+		c.current.Artifacts = append(c.current.Artifacts, rel)
+	} else {
+		c.Artifacts = append(c.Artifacts, rel)
+	}
+	return nil
+}
+
+func (c *Case) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Duration = Duration(time.Since(c.StartTime))
+	c.Status = StatusPassed
+	switch {
+	case c.t.Failed():
+		c.Status = StatusFailed
+		c.Failure = fmt.Sprintf("%s failed", c.Name)
+		for _, step := range c.Steps {
+			if step.Status == StatusFailed {
+				c.Failure = step.Failure
+				break
+			}
+		}
+	case c.t.Skipped():
+		c.Status = StatusSkipped
+	}
+}
+
+// Write writes the report to its directory as <name>.json and <name>.xml.
+func (r *Report) Write() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+
+	jsonReport, err := json.MarshalIndent(struct {
+		Name  string  `json:"name"`
+		Cases []*Case `json:"cases"`
+	}{r.name, r.cases}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.dir, r.name+".json"), jsonReport, 0644); err != nil {
+		return err
+	}
+
+	xmlReport, err := xml.MarshalIndent(r.junit(), "", "  ")
+	if err != nil {
+		return err
+	}
+	xmlReport = append([]byte(xml.Header), xmlReport...)
+	return os.WriteFile(filepath.Join(r.dir, r.name+".xml"), xmlReport, 0644)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Name    string           `xml:"name,attr"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junit converts the report to JUnit XML. Every case becomes a test suite
+// and its steps become the suite's test cases. Cases without steps are
+// reported as a single test case. Artifacts are linked from the test
+// cases' output using the [[ATTACHMENT|path]] convention.
+func (r *Report) junit() junitTestSuites {
+	suites := junitTestSuites{Name: r.name}
+	for _, c := range r.cases {
+		suite := junitTestSuite{
+			Name:      c.Name,
+			Time:      c.Duration.seconds(),
+			Timestamp: c.StartTime.Format(time.RFC3339),
+		}
+
+		steps := c.Steps
+		if len(steps) == 0 {
+			steps = []*Step{{Name: c.Name, Status: c.Status, Duration: c.Duration, Failure: c.Failure}}
+		}
+
+		for i, step := range steps {
+			artifacts := step.Artifacts
+			if i == len(steps)-1 {
+				artifacts = slices.Concat(artifacts, c.Artifacts)
+			}
+
+			tc := junitTestCase{
+				Name:      step.Name,
+				Classname: c.Name,
+				Time:      step.Duration.seconds(),
+			}
+			for _, artifact := range artifacts {
+				tc.SystemOut += fmt.Sprintf("[[ATTACHMENT|%s]]\n", artifact)
+			}
+
+			switch step.Status {
+			case StatusFailed:
+				suite.Failures++
+				tc.Failure = &junitFailure{Message: step.Failure, Text: step.Failure}
+			case StatusSkipped:
+				suite.Skipped++
+				tc.Skipped = &struct{}{}
+			}
+
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		suites.Suites = append(suites.Suites, suite)
+	}
+	return suites
+}
@@ -0,0 +1,164 @@
+// Copyright IBM Corp. 2021, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the outcome of a test without failing the real one.
+type fakeT struct {
+	testing.TB
+	name     string
+	failed   bool
+	cleanups []func()
+}
+
+func (f *fakeT) Name() string                      { return f.name }
+func (f *fakeT) Failed() bool                      { return f.failed }
+func (f *fakeT) Skipped() bool                     { return false }
+func (f *fakeT) Helper()                           {}
+func (f *fakeT) Cleanup(fn func())                 { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeT) Errorf(format string, args ...any) { f.failed = true }
+func (f *fakeT) Fatal(args ...any)                 { f.FailNow() }
+
+func (f *fakeT) FailNow() {
+	f.failed = true
+	runtime.Goexit()
+}
+
+// run calls fn in its own goroutine, like the testing package
+// does, so that fn can stop with FailNow. The cleanup functions
+// registered by fn run once it returns.
+func (f *fakeT) run(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+	r := New("scenarios", dir)
+
+	passing := &fakeT{name: "TestRunScenario/FARGATE"}
+	passing.run(func() {
+		c := r.Track(passing)
+		c.Step(passing, "init", func() error { return nil })
+		c.Step(passing, "apply", func() error {
+			return c.WriteArtifact("apply.log", []byte("Apply complete!"))
+		})
+		require.NoError(t, c.WriteArtifact("outputs.json", []byte("{}")))
+	})
+
+	failing := &fakeT{name: "TestRunScenario/EC2"}
+	failing.run(func() {
+		c := r.Track(failing)
+		c.Step(failing, "apply", func() error { return nil })
+		c.Step(failing, "validate", func() error {
+			require.Equal(failing, 1, 2)
+			return nil
+		})
+		c.Step(failing, "never reached", func() error { return nil })
+	})
+
+	erroring := &fakeT{name: "TestRunScenario/HCP"}
+	erroring.run(func() {
+		c := r.Track(erroring)
+		c.Step(erroring, "apply", func() error { return errors.New("apply failed") })
+	})
+
+	require.NoError(t, r.Write())
+
+	// JSON report
+	contents, err := os.ReadFile(filepath.Join(dir, "scenarios.json"))
+	require.NoError(t, err)
+
+	var jsonReport struct {
+		Name  string `json:"name"`
+		Cases []struct {
+			Name      string   `json:"name"`
+			Status    Status   `json:"status"`
+			Failure   string   `json:"failure"`
+			Artifacts []string `json:"artifacts"`
+			Steps     []struct {
+				Name      string   `json:"name"`
+				Status    Status   `json:"status"`
+				Failure   string   `json:"failure"`
+				Artifacts []string `json:"artifacts"`
+			} `json:"steps"`
+		} `json:"cases"`
+	}
+	require.NoError(t, json.Unmarshal(contents, &jsonReport))
+	require.Equal(t, "scenarios", jsonReport.Name)
+	require.Len(t, jsonReport.Cases, 3)
+
+	fargate := jsonReport.Cases[0]
+	require.Equal(t, StatusPassed, fargate.Status)
+	require.Len(t, fargate.Steps, 2)
+	require.Equal(t, []string{"artifacts/TestRunScenario_FARGATE/apply.log"}, fargate.Steps[1].Artifacts)
+	require.Equal(t, []string{"artifacts/TestRunScenario_FARGATE/outputs.json"}, fargate.Artifacts)
+	artifact, err := os.ReadFile(filepath.Join(dir, fargate.Steps[1].Artifacts[0]))
+	require.NoError(t, err)
+	require.Equal(t, "Apply complete!", string(artifact))
+
+	ec2 := jsonReport.Cases[1]
+	require.Equal(t, StatusFailed, ec2.Status)
+	require.Len(t, ec2.Steps, 2)
+	require.Equal(t, StatusPassed, ec2.Steps[0].Status)
+	require.Equal(t, StatusFailed, ec2.Steps[1].Status)
+	require.Equal(t, "validate failed, see the output of TestRunScenario/EC2", ec2.Steps[1].Failure)
+	require.Equal(t, ec2.Steps[1].Failure, ec2.Failure)
+
+	hcp := jsonReport.Cases[2]
+	require.Equal(t, StatusFailed, hcp.Status)
+	require.Equal(t, "apply failed", hcp.Failure)
+
+	// JUnit XML report
+	contents, err = os.ReadFile(filepath.Join(dir, "scenarios.xml"))
+	require.NoError(t, err)
+
+	var junit junitTestSuites
+	require.NoError(t, xml.Unmarshal(contents, &junit))
+	require.Len(t, junit.Suites, 3)
+	require.Equal(t, "TestRunScenario/FARGATE", junit.Suites[0].Name)
+	require.Equal(t, 2, junit.Suites[0].Tests)
+	require.Equal(t, 0, junit.Suites[0].Failures)
+	require.Equal(t, "[[ATTACHMENT|artifacts/TestRunScenario_FARGATE/apply.log]]\n[[ATTACHMENT|artifacts/TestRunScenario_FARGATE/outputs.json]]\n",
+		junit.Suites[0].Cases[1].SystemOut)
+	require.Equal(t, 1, junit.Suites[1].Failures)
+	require.NotNil(t, junit.Suites[1].Cases[1].Failure)
+	require.Equal(t, "apply failed", junit.Suites[2].Cases[0].Failure.Message)
+}
+
+func TestNilReport(t *testing.T) {
+	r := New("scenarios", "")
+	require.Nil(t, r)
+
+	c := r.Track(t)
+	require.Nil(t, c)
+
+	called := false
+	c.Step(t, "apply", func() error {
+		called = true
+		return nil
+	})
+	require.True(t, called)
+	require.NoError(t, c.WriteArtifact("apply.log", nil))
+	require.NoError(t, r.Write())
+}
@@ -6,32 +6,38 @@ package suite
 import (
 	"flag"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/config"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/flags"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/report"
 )
 
 // DefaultExecs holds the default external executables that are required to
 // run the tests. They can be overridden or customized per test as needed.
 var DefaultExecs = []string{
-	"aws",
 	"ecs-cli",
-	"session-manager-plugin",
 	"terraform",
 }
 
 type suite struct {
-	m     *testing.M
-	cfg   *config.TestConfig
-	flags *flags.TestFlags
-	execs []string
+	m      *testing.M
+	cfg    *config.TestConfig
+	flags  *flags.TestFlags
+	execs  []string
+	report *report.Report
 }
 
 type Suite interface {
 	Run() int
 	Config() *config.TestConfig
+
+	// Report returns the report that tests record their outcome and
+	// timings in. It is nil, and records nothing, unless -report-dir is set.
+	Report() *report.Report
 }
 
 func NewSuite(m *testing.M, execs ...string) Suite {
@@ -65,13 +71,32 @@ func (s *suite) Run() int {
 	}
 	s.cfg = testConfig
 
-	return s.m.Run()
+	// The report is named after the package holding the tests.
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Failed to determine the test package: %s\n", err)
+		return 1
+	}
+	s.report = report.New(filepath.Base(wd), testConfig.ReportDir)
+
+	code := s.m.Run()
+
+	if err := s.report.Write(); err != nil {
+		fmt.Printf("Failed to write the test report: %s\n", err)
+		return 1
+	}
+
+	return code
 }
 
 func (s *suite) Config() *config.TestConfig {
 	return s.cfg
 }
 
+func (s *suite) Report() *report.Report {
+	return s.report
+}
+
 // Vet ensures that the test suite is in a state that it can run.
 // It returns a non-nil error if there are failures.
 func (s *suite) Vet() error {
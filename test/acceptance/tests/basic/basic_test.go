@@ -11,9 +11,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulstate"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/ecsapi"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/helpers"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
 	"github.com/stretchr/testify/require"
@@ -35,6 +38,11 @@ func TestBasic(t *testing.T) {
 	}
 
 	cfg := suite.Config()
+	if cfg.ReuseState != "" {
+		// The test stops tasks and creates intentions that are not
+		// removed afterwards, so its deployments cannot be validated again.
+		t.Skip("TestBasic does not support -reuse-state")
+	}
 	require.GreaterOrEqual(t, len(cfg.ECSClusterARNs), len(cases),
 		"TestBasic requires %d ECS clusters. Update setup-terraform and re-run.", len(cases),
 	)
@@ -60,6 +68,8 @@ func TestBasic(t *testing.T) {
 		t.Run(fmt.Sprintf("secure: %t,enterprise: %t", c.secure, c.enterprise), func(t *testing.T) {
 			t.Parallel()
 
+			reportCase := suite.Report().Track(t)
+
 			randomSuffix := strings.ToLower(random.UniqueId())
 
 			tfEnvVars := map[string]string{
@@ -99,37 +109,60 @@ func TestBasic(t *testing.T) {
 				EnvVars:      tfEnvVars,
 			})
 
+			var consulServerTaskARN string
 			t.Cleanup(func() {
 				if cfg.NoCleanupOnFailure && t.Failed() {
 					logger.Log(t, "skipping resource cleanup because -no-cleanup-on-failure=true")
-				} else {
-					terraform.Destroy(t, applyOptions)
+					return
+				}
+
+				// Tasks only log in with ACLs enabled, and their tokens can only
+				// be listed once the Consul server is up.
+				var tokenCheck *helpers.TokenCheck
+				if c.secure && consulServerTaskARN != "" {
+					tokenCheck = &helpers.TokenCheck{
+						Targets: []string{"aws_ecs_service.test_client", "aws_ecs_service.test_server"},
+						Services: []string{
+							fmt.Sprintf("%s_%s", clientServiceName, randomSuffix),
+							fmt.Sprintf("%s_%s", serverServiceName, randomSuffix),
+						},
+						Region: cfg.Region,
+						ListTokens: func() ([]consulstate.LoginToken, error) {
+							return helpers.ListServerLoginTokens(t, cfg, c.ecsClusterARN, consulServerTaskARN)
+						},
+					}
 				}
+				reportCase.Step(t, "destroy", func() error {
+					return helpers.Destroy(t, applyOptions, tokenCheck)
+				})
 			})
 
-			terraform.Apply(t, applyOptions)
+			reportCase.Step(t, "apply", func() error {
+				_, err := terraform.ApplyE(t, applyOptions)
+				return err
+			})
+
+			ecsClient, err := ecsapi.NewClient(t.Context(), cfg.Region)
+			require.NoError(t, err)
 
 			// Wait for consul server to be up.
-			var consulServerTaskARN string
 			retry.RunWith(&retry.Timer{Timeout: 10 * time.Minute, Wait: 30 * time.Second}, t, func(r *retry.R) {
-				tasks, err := helpers.ListTasks(t, c.ecsClusterARN, cfg.Region, fmt.Sprintf("consul-server-%s", randomSuffix))
+				taskARNs, err := ecsapi.ListTasks(t.Context(), ecsClient, c.ecsClusterARN, ecsapi.ListTasksFilter{Family: fmt.Sprintf("consul-server-%s", randomSuffix)})
 
 				r.Check(err)
-				require.NotNil(r, tasks)
-				require.Len(r, tasks.TaskARNs, 1)
-				consulServerTaskARN = tasks.TaskARNs[0]
+				require.Len(r, taskARNs, 1)
+				consulServerTaskARN = taskARNs[0]
 			})
 
 			var controllerTaskID string
 			if c.secure {
 				retry.RunWith(&retry.Timer{Timeout: 8 * time.Minute, Wait: 30 * time.Second}, t, func(r *retry.R) {
-					tasks, err := helpers.ListTasks(t, c.ecsClusterARN, cfg.Region, fmt.Sprintf("%s-consul-ecs-controller", randomSuffix))
+					taskARNs, err := ecsapi.ListTasks(t.Context(), ecsClient, c.ecsClusterARN, ecsapi.ListTasksFilter{Family: fmt.Sprintf("%s-consul-ecs-controller", randomSuffix)})
 
 					r.Check(err)
-					require.NotNil(r, tasks)
-					require.Len(r, tasks.TaskARNs, 1)
+					require.Len(r, taskARNs, 1)
 
-					controllerTaskID = helpers.GetTaskIDFromARN(tasks.TaskARNs[0])
+					controllerTaskID = helpers.GetTaskIDFromARN(taskARNs[0])
 				})
 
 				// Check controller logs to see if the anonymous token gets configured. This should
@@ -192,13 +225,13 @@ func TestBasic(t *testing.T) {
 			})
 
 			// Use aws exec to curl between the apps.
-			tasks, err := helpers.ListTasks(t, c.ecsClusterARN, cfg.Region, fmt.Sprintf("Test_Client_%s", randomSuffix))
+			taskARNs, err := ecsapi.ListTasks(t.Context(), ecsClient, c.ecsClusterARN, ecsapi.ListTasksFilter{Family: fmt.Sprintf("Test_Client_%s", randomSuffix)})
 
 			require.NoError(t, err)
-			require.Len(t, tasks.TaskARNs, 1)
+			require.Len(t, taskARNs, 1)
 
-			testClientTaskARN := tasks.TaskARNs[0]
-			testClientTaskID := helpers.GetTaskIDFromARN(tasks.TaskARNs[0])
+			testClientTaskARN := taskARNs[0]
+			testClientTaskID := helpers.GetTaskIDFromARN(taskARNs[0])
 
 			// Create an intention.
 			if c.secure {
@@ -230,16 +263,16 @@ func TestBasic(t *testing.T) {
 			// * a custom entrypoint for the client app that keeps it running for 10s into Task shutdown, and
 			// * an additional "shutdown-monitor" container that makes requests to the client app
 			// Since this is timing dependent, we check logs after the fact to validate when the containers exited.
-			helpers.StopTask(t, c.ecsClusterARN, cfg.Region, testClientTaskARN, "Stopped to validate graceful shutdown in acceptance tests")
+			require.NoError(t, ecsapi.StopTask(t.Context(), ecsClient, c.ecsClusterARN, testClientTaskARN, "Stopped to validate graceful shutdown in acceptance tests"))
 
 			// Wait for the task to stop (~30 seconds)
 			retry.RunWith(&retry.Timer{Timeout: 1 * time.Minute, Wait: 10 * time.Second}, t, func(r *retry.R) {
-				describeTasks, err := helpers.DescribeTasks(t, c.ecsClusterARN, cfg.Region, testClientTaskARN)
+				describeTasks, err := ecsapi.DescribeTasks(t.Context(), ecsClient, c.ecsClusterARN, testClientTaskARN)
 
 				r.Check(err)
 				require.NotNil(r, describeTasks)
 				require.Len(r, describeTasks.Tasks, 1)
-				require.NotEqual(r, "RUNNING", describeTasks.Tasks[0].LastStatus)
+				require.NotEqual(r, "RUNNING", aws.ToString(describeTasks.Tasks[0].LastStatus))
 			})
 
 			// Check logs to see that the application ignored the TERM signal and exited about 10s later.
@@ -4,7 +4,6 @@
 package hcp
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -16,6 +15,9 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/config"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/configentries"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulstate"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/helpers"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
 	"github.com/stretchr/testify/require"
@@ -23,6 +25,16 @@ import (
 
 const setupDir = "../../setup-terraform"
 
+// Outputs of curl in the client task when the connection to the server is denied.
+const (
+	// The proxy of the server closes connections without an intention.
+	emptyReply = `curl: (52) Empty reply from server`
+	// The proxy of the client refuses connections to upstreams that were not exported.
+	connectionRefused = `Connection refused`
+	// The transparent proxy of the client resets connections to services that were not exported.
+	connectionReset = `curl: (56) Recv failure: Connection reset by peer`
+)
+
 var (
 	// Timeout and polling interval for ECS mesh tasks to start and register with Consul.
 	registrationTimeout = &retry.Timer{Timeout: 6 * time.Minute, Wait: 20 * time.Second}
@@ -78,21 +90,20 @@ func parseHCPTestConfig(t *testing.T) HCPTestConfig {
 }
 
 func TestHCP(t *testing.T) {
+	suite.Report().Track(t)
+
 	cfg := parseHCPTestConfig(t)
 
+	tfDir := "./terraform/hcp-install"
+	randomSuffix := suffixes(t, tfDir, "suffix")[0]
+
 	// generate input variables to the test terraform using the config.
 	ignoreVars := []string{"token", "enable_hcp", "consul_version", "retry_join", "consul_public_endpoint_url"}
 	tfVars := TFVars(cfg, ignoreVars...)
 
-	consulClient, initialConsulState, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
+	consulClient, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		if err := restoreConsulState(t, consulClient, initialConsulState); err != nil {
-			logger.Log(t, "failed to restore Consul state:", err)
-		}
-	})
-
-	randomSuffix := strings.ToLower(random.UniqueId())
+	configEntries := configentries.NewManager(t, consulClient, consulwait.DefaultRetryPolicy())
 
 	taskConfig := helpers.MeshTaskConfig{
 		Partition:    "default",
@@ -113,48 +124,38 @@ func TestHCP(t *testing.T) {
 
 	tfVars["consul_server_address"] = cfg.getServerAddress()
 
-	terraformOptions, _ := terraformInitAndApply(t, "./terraform/hcp-install", tfVars)
-	t.Cleanup(func() { terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure) })
+	terraformOptions, _ := terraformInitAndApply(t, tfDir, tfVars)
+	t.Cleanup(func() {
+		terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure, clientTask, serverTask)
+	})
 
 	// Wait for both tasks to be registered in Consul.
 	waitForTasks(t, clientTask, serverTask)
 
+	// Check that the proxies were registered with the inputs of the module.
+	logger.Log(t, "checking the registrations of the proxies")
+	expectProxyRegistration(t, clientTask, helpers.ProxyInputs{
+		Upstreams: []helpers.Upstream{{DestinationName: serverTask.Name, LocalBindPort: 1234}},
+	})
+	expectProxyRegistration(t, serverTask, helpers.ProxyInputs{})
+
+	clientToServer := helpers.Connection{Source: clientTask, Destination: serverTask}
+	upstreams := helpers.StaticTargets(map[helpers.Connection]string{clientToServer: "localhost:1234"})
+
 	// Check that the connection between apps is unsuccessful without an intention.
 	logger.Log(t, "checking that the connection between apps is unsuccessful without an intention")
-	expectCurlOutput(t, clientTask, "", `curl: (52) Empty reply from server`)
+	expectConnectivity(t, upstreams, nil, map[helpers.Connection]string{clientToServer: emptyReply}, clientTask, serverTask)
 
 	// Create an intention.
-	upsertIntention(t, consulClient, api.IntentionActionAllow, clientTask, serverTask)
-	t.Cleanup(func() { deleteIntention(t, consulClient, serverTask) })
+	configEntries.WriteServiceIntentions(allowIntentions(clientTask, serverTask))
 
 	// Now check that the connection succeeds.
 	logger.Log(t, "checking that the connection succeeds with an intention")
-	expectCurlOutput(t, clientTask, "", `"code": 200`)
-
-	// Check that the ACL tokens for services are deleted
-	// when services are destroyed.
-
-	// First, destroy just the service mesh services.
-	// tfOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-	// 	TerraformDir: "./terraform/basic-install",
-	// 	Vars:         tfVars,
-	// 	NoColor:      true,
-	// 	Targets: []string{
-	// 		"aws_ecs_service.test_server",
-	// 		"aws_ecs_service.test_client",
-	// 		"module.test_server",
-	// 		"module.test_client",
-	// 	},
-	// })
-	// terraform.Destroy(t, tfOptions)
-
-	// // Check that the ACL tokens are deleted from Consul.
-	// retry.RunWith(&retry.Timer{Timeout: 5 * time.Minute, Wait: 20 * time.Second}, t, func(r *retry.R) {
-	// 	out, err := executeRemoteCommand(t, consulServerTaskARN, "consul-server", `/bin/sh -c "consul acl token list"`)
-	// 	require.NoError(r, err)
-	// 	require.NotContains(r, out, fmt.Sprintf("test_client_%s", randomSuffix))
-	// 	require.NotContains(r, out, fmt.Sprintf("test_server_%s", randomSuffix))
-	// })
+	expectConnectivity(t, upstreams, []helpers.Connection{clientToServer}, nil, clientTask, serverTask)
+
+	// Check that the proxies got the upstreams input of the module and the intentions from Consul.
+	logger.Log(t, "checking the configuration of the proxies")
+	expectProxyConfig(t, clientTask, serverTask, helpers.Upstream{DestinationName: serverTask.Name, LocalBindPort: 1234})
 
 	logger.Log(t, "Test successful!")
 }
@@ -162,21 +163,20 @@ func TestHCP(t *testing.T) {
 // TestNamespaces ensures that services in different namespaces can be
 // can be configured to communicate.
 func TestNamespaces(t *testing.T) {
+	suite.Report().Track(t)
+
 	cfg := parseHCPTestConfig(t)
 
+	tfDir := "./terraform/ns"
+	randomSuffix := suffixes(t, tfDir, "suffix")[0]
+
 	// generate input variables to the test terraform using the config.
 	ignoreVars := []string{"token", "enable_hcp", "consul_version", "retry_join", "consul_public_endpoint_url"}
 	tfVars := TFVars(cfg, ignoreVars...)
 
-	consulClient, initialConsulState, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
+	consulClient, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		if err := restoreConsulState(t, consulClient, initialConsulState); err != nil {
-			logger.Log(t, "failed to restore Consul state:", err)
-		}
-	})
-
-	randomSuffix := strings.ToLower(random.UniqueId())
+	configEntries := configentries.NewManager(t, consulClient, consulwait.DefaultRetryPolicy())
 
 	taskConfig := helpers.MeshTaskConfig{
 		ConsulClient: consulClient,
@@ -199,23 +199,46 @@ func TestNamespaces(t *testing.T) {
 	tfVars["consul_image"] = cfg.ConsulImageURI(true)
 	tfVars["consul_server_address"] = cfg.getServerAddress()
 
-	terraformOptions, _ := terraformInitAndApply(t, "./terraform/ns", tfVars)
-	t.Cleanup(func() { terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure) })
+	terraformOptions, _ := terraformInitAndApply(t, tfDir, tfVars)
+	t.Cleanup(func() {
+		terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure, clientTask, serverTask)
+	})
 
 	// Wait for both tasks to be registered in Consul.
 	waitForTasks(t, clientTask, serverTask)
 
+	// Check that the proxies were registered with the inputs of the module.
+	logger.Log(t, "checking the registrations of the proxies")
+	expectProxyRegistration(t, clientTask, helpers.ProxyInputs{
+		Upstreams: []helpers.Upstream{{
+			DestinationName:      serverTask.Name,
+			DestinationNamespace: serverTask.Namespace,
+			LocalBindPort:        1234,
+		}},
+	})
+	expectProxyRegistration(t, serverTask, helpers.ProxyInputs{})
+
+	clientToServer := helpers.Connection{Source: clientTask, Destination: serverTask}
+	upstreams := helpers.StaticTargets(map[helpers.Connection]string{clientToServer: "localhost:1234"})
+
 	// Check that the connection between apps is unsuccessful without an intention.
 	logger.Log(t, "checking that the connection between apps is unsuccessful without an intention")
-	expectCurlOutput(t, clientTask, "", `curl: (52) Empty reply from server`)
+	expectConnectivity(t, upstreams, nil, map[helpers.Connection]string{clientToServer: emptyReply}, clientTask, serverTask)
 
 	// Create an intention.
-	upsertIntention(t, consulClient, api.IntentionActionAllow, clientTask, serverTask)
-	t.Cleanup(func() { deleteIntention(t, consulClient, serverTask) })
+	configEntries.WriteServiceIntentions(allowIntentions(clientTask, serverTask))
 
 	// Now check that the connection succeeds.
 	logger.Log(t, "checking that the connection succeeds with an intention")
-	expectCurlOutput(t, clientTask, "", `"code": 200`)
+	expectConnectivity(t, upstreams, []helpers.Connection{clientToServer}, nil, clientTask, serverTask)
+
+	// Check that the proxies got the upstreams input of the module and the intentions from Consul.
+	logger.Log(t, "checking the configuration of the proxies")
+	expectProxyConfig(t, clientTask, serverTask, helpers.Upstream{
+		DestinationName:      serverTask.Name,
+		DestinationNamespace: serverTask.Namespace,
+		LocalBindPort:        1234,
+	})
 
 	logger.Log(t, "Test successful!")
 }
@@ -223,22 +246,21 @@ func TestNamespaces(t *testing.T) {
 // TestAdminPartitions ensures that services in different admin partitions and namespaces can be
 // can be configured to communicate.
 func TestAdminPartitions(t *testing.T) {
+	suite.Report().Track(t)
+
 	cfg := parseHCPTestConfig(t)
 
+	tfDir := "./terraform/ap"
+	names := suffixes(t, tfDir, "suffix_1", "suffix_2")
+	clientSuffix, serverSuffix := names[0], names[1]
+
 	// generate input variables to the test terraform using the config.
 	ignoreVars := []string{"ecs_cluster_arn", "token", "enable_hcp", "consul_version", "retry_join", "consul_public_endpoint_url"}
 	tfVars := TFVars(cfg, ignoreVars...)
 
-	consulClient, initialConsulState, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
+	consulClient, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		if err := restoreConsulState(t, consulClient, initialConsulState); err != nil {
-			logger.Log(t, "failed to restore Consul state:", err)
-		}
-	})
-
-	clientSuffix := strings.ToLower(random.UniqueId())
-	serverSuffix := strings.ToLower(random.UniqueId())
+	configEntries := configentries.NewManager(t, consulClient, consulwait.DefaultRetryPolicy())
 
 	taskConfig := helpers.MeshTaskConfig{
 		ConsulClient: consulClient,
@@ -266,33 +288,76 @@ func TestAdminPartitions(t *testing.T) {
 	tfVars["consul_image"] = cfg.ConsulImageURI(true)
 	tfVars["consul_server_address"] = cfg.getServerAddress()
 
-	terraformOptions, _ := terraformInitAndApply(t, "./terraform/ap", tfVars)
-	t.Cleanup(func() { terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure) })
+	terraformOptions, _ := terraformInitAndApply(t, tfDir, tfVars)
+	t.Cleanup(func() {
+		terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure, clientTask, serverTask)
+	})
 
 	// Wait for both tasks to be registered in Consul.
 	waitForTasks(t, clientTask, serverTask)
 
+	// Check that the proxies were registered with the inputs of the module.
+	logger.Log(t, "checking the registrations of the proxies")
+	expectProxyRegistration(t, clientTask, helpers.ProxyInputs{
+		Upstreams: []helpers.Upstream{{
+			DestinationName:      serverTask.Name,
+			DestinationPartition: serverTask.Partition,
+			DestinationNamespace: serverTask.Namespace,
+			LocalBindPort:        1234,
+		}},
+	})
+	expectProxyRegistration(t, serverTask, helpers.ProxyInputs{})
+
+	clientToServer := helpers.Connection{Source: clientTask, Destination: serverTask}
+	upstreams := helpers.StaticTargets(map[helpers.Connection]string{clientToServer: "localhost:1234"})
+
 	logger.Log(t, "checking that the connection is refused without an `exported-services` config entry")
-	expectCurlOutput(t, clientTask, "", `Connection refused`)
+	expectConnectivity(t, upstreams, nil, map[helpers.Connection]string{clientToServer: connectionRefused}, clientTask, serverTask)
 
 	// Create an exported-services config entry for the server
-	upsertExportedServices(t, consulClient, clientTask, serverTask)
-	t.Cleanup(func() { deleteExportedServices(t, consulClient, serverTask) })
+	configEntries.Write(exportedServices(clientTask, serverTask))
 
 	logger.Log(t, "checking that the connection between apps is unsuccessful without an intention")
-	expectCurlOutput(t, clientTask, "", `curl: (52) Empty reply from server`)
+	expectConnectivity(t, upstreams, nil, map[helpers.Connection]string{clientToServer: emptyReply}, clientTask, serverTask)
 
 	// Create an intention.
 	logger.Log(t, "upserting intention")
-	upsertIntention(t, consulClient, api.IntentionActionAllow, clientTask, serverTask)
-	t.Cleanup(func() { deleteIntention(t, consulClient, serverTask) })
+	configEntries.WriteServiceIntentions(allowIntentions(clientTask, serverTask))
 
 	logger.Log(t, "checking that the connection succeeds with an exported-services and intention")
-	expectCurlOutput(t, clientTask, "", `"code": 200`)
+	expectConnectivity(t, upstreams, []helpers.Connection{clientToServer}, nil, clientTask, serverTask)
+
+	// Check that the proxies got the upstreams input of the module and the intentions from Consul.
+	logger.Log(t, "checking the configuration of the proxies")
+	expectProxyConfig(t, clientTask, serverTask, helpers.Upstream{
+		DestinationName:      serverTask.Name,
+		DestinationPartition: serverTask.Partition,
+		DestinationNamespace: serverTask.Namespace,
+		LocalBindPort:        1234,
+	})
 
 	logger.Log(t, "Test successful!")
 }
 
+// suffixes returns new random suffixes for the names of the resources of the
+// test, or the suffixes of the existing deployment in tfDir with -reuse-state,
+// which are read from the terraform outputs of the given names.
+func suffixes(t *testing.T, tfDir string, outputs ...string) []string {
+	reused := helpers.ReuseState(t, suite.Config().ReuseState, tfDir, outputs...)
+
+	suffixes := make([]string, 0, len(outputs))
+	for _, name := range outputs {
+		if reused != nil {
+			suffixes = append(suffixes, fmt.Sprint(reused[name]))
+		} else {
+			suffixes = append(suffixes, strings.ToLower(random.UniqueId()))
+		}
+	}
+	return suffixes
+}
+
+// terraformInitAndApply deploys tfDir with the input variables, unless the
+// test validates the existing deployment in tfDir with -reuse-state.
 func terraformInitAndApply(t *testing.T, tfDir string, tfVars map[string]interface{}) (*terraform.Options, map[string]interface{}) {
 	tfOptions := &terraform.Options{
 		TerraformDir: tfDir,
@@ -301,7 +366,9 @@ func terraformInitAndApply(t *testing.T, tfDir string, tfVars map[string]interfa
 	}
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, tfOptions)
 
-	terraform.InitAndApply(t, terraformOptions)
+	if suite.Config().ReuseState == "" {
+		terraform.InitAndApply(t, terraformOptions)
+	}
 
 	outputs := terraform.OutputAll(t, &terraform.Options{
 		TerraformDir: tfDir,
@@ -311,11 +378,32 @@ func terraformInitAndApply(t *testing.T, tfDir string, tfVars map[string]interfa
 	return terraformOptions, outputs
 }
 
-func terraformDestroy(t *testing.T, tfOpts *terraform.Options, noCleanupOnFailure bool) {
+// terraformDestroy destroys the ECS services of the tasks first, while the
+// controllers still run, and checks that the ACL tokens of the tasks are
+// deleted before destroying the remaining resources. An existing deployment
+// validated with -reuse-state is left in place.
+func terraformDestroy(t *testing.T, tfOpts *terraform.Options, noCleanupOnFailure bool, tasks ...*helpers.MeshTask) {
+	if suite.Config().ReuseState != "" {
+		logger.Log(t, fmt.Sprintf("leaving the deployment in %s in place because of -reuse-state", tfOpts.TerraformDir))
+		return
+	}
 	if noCleanupOnFailure && t.Failed() {
 		logger.Log(t, "skipping resource cleanup because -no-cleanup-on-failure=true")
-	} else {
-		terraform.Destroy(t, tfOpts)
+		return
+	}
+
+	check := &helpers.TokenCheck{
+		Targets: []string{"aws_ecs_service.test_client", "aws_ecs_service.test_server"},
+		Region:  tasks[0].Region,
+		ListTokens: func() ([]consulstate.LoginToken, error) {
+			return consulstate.ListLoginTokens(tasks[0].ConsulClient)
+		},
+	}
+	for _, task := range tasks {
+		check.Services = append(check.Services, task.Name)
+	}
+	if err := helpers.Destroy(t, tfOpts, check); err != nil {
+		t.Error(err)
 	}
 }
 
@@ -343,262 +431,78 @@ func waitForTasks(t *testing.T, tasks ...*helpers.MeshTask) {
 	}))
 }
 
-func expectCurlOutput(t *testing.T, task *helpers.MeshTask, curlArgs, expected string) {
-	command := `/bin/sh -c "curl localhost:1234"`
-	if curlArgs != "" {
-		command = curlArgs
-	}
-
-	require.NoError(t, retryFunc(meshTaskTimeout, t, func() error {
-		curlOut, err := task.ExecuteCommand("basic", command)
-		if err != nil {
-			return fmt.Errorf("failed to execute command: %w", err)
-		}
-		if !strings.Contains(curlOut, expected) {
-			return fmt.Errorf("unexpected response: %s", curlOut)
-		}
-		logger.Log(t, "observed expected response:", expected)
-		return nil
-	}))
+// expectConnectivity probes every pair of tasks until the allowed
+// connections are the only ones that succeed and the denied ones
+// fail with the given output.
+func expectConnectivity(t *testing.T, target helpers.ConnectivityTarget, allowed []helpers.Connection, denied map[helpers.Connection]string, tasks ...*helpers.MeshTask) {
+	matrix := helpers.ConnectivityMatrix{Tasks: tasks, Allowed: allowed, DeniedOutput: denied, Target: target}
+	diff := matrix.Verify(t, meshTaskTimeout)
+	require.True(t, diff.Empty(), "unexpected connectivity between the tasks:\n%s", diff)
 }
 
-func upsertIntention(t *testing.T, consulClient *api.Client, action api.IntentionAction, src, dst *helpers.MeshTask) {
-	require.NoError(t, retryFunc(consulTimeout, t, func() error {
-		_, _, err := consulClient.ConfigEntries().Set(&api.ServiceIntentionsConfigEntry{
-			Kind:      api.ServiceIntentions,
-			Name:      dst.Name,
-			Partition: dst.Partition,
-			Namespace: dst.Namespace,
-			Sources: []*api.SourceIntention{
-				{
-					Name:      src.Name,
-					Partition: src.Partition,
-					Namespace: src.Namespace,
-					Action:    action,
-				},
-			},
-		}, dst.WriteOpts())
-		if err != nil {
-			return fmt.Errorf("failed to upsert intention: %w", err)
-		}
-		return nil
-	}))
+// expectProxyRegistration checks that every sidecar proxy of the task
+// is registered with the inputs of the mesh-task module.
+func expectProxyRegistration(t *testing.T, task *helpers.MeshTask, inputs helpers.ProxyInputs) {
+	problems, err := task.CheckProxyRegistration(inputs)
+	require.NoError(t, err)
+	require.Empty(t, problems, "the proxy registration of %s does not match the inputs of the module:\n%s", task, strings.Join(problems, "\n"))
 }
 
-func deleteIntention(t *testing.T, consulClient *api.Client, dst *helpers.MeshTask) {
-	require.NoError(t, retryFunc(consulTimeout, t, func() error {
-		_, err := consulClient.ConfigEntries().Delete(api.ServiceIntentions, dst.Name, dst.WriteOpts())
+// expectProxyConfig waits for the proxy of src to be configured with the
+// upstreams and checks that the proxy of dst enforces intentions.
+func expectProxyConfig(t *testing.T, src, dst *helpers.MeshTask, upstreams ...helpers.Upstream) {
+	require.NoError(t, retryFunc(meshTaskTimeout, t, func() error {
+		problems, err := src.EnvoyAdmin().CheckUpstreams(upstreams...)
 		if err != nil {
-			return fmt.Errorf("failed to delete intention: %w", err)
+			return err
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("the proxy of %s does not match its upstreams:\n%s", src, strings.Join(problems, "\n"))
 		}
 		return nil
 	}))
+
+	dump, err := dst.EnvoyAdmin().ConfigDump()
+	require.NoError(t, err)
+	public, ok := dump.PublicListener()
+	require.True(t, ok, "the proxy of %s has no public listener", dst)
+	require.True(t, public.HasRBAC(), "the public listener of %s does not enforce intentions", dst)
 }
 
-func upsertExportedServices(t *testing.T, consulClient *api.Client, src, dst *helpers.MeshTask) {
-	require.NoError(t, retryFunc(consulTimeout, t, func() error {
-		_, _, err := consulClient.ConfigEntries().Set(&api.ExportedServicesConfigEntry{
-			Name:      dst.Partition,
-			Partition: dst.Partition,
-			Services: []api.ExportedService{{
-				Name:      dst.Name,
-				Namespace: dst.Namespace,
-				Consumers: []api.ServiceConsumer{{Partition: src.Partition}},
-			}},
-		}, dst.WriteOpts())
-		if err != nil {
-			return fmt.Errorf("failed to upsert exported-services for %s/%s/%s: %w", dst.Partition, dst.Namespace, dst.Name, err)
-		}
-		return nil
-	}))
+// allowIntentions returns the intentions allowing src to call dst.
+func allowIntentions(src, dst *helpers.MeshTask) *configentries.ServiceIntentions {
+	return configentries.NewServiceIntentions(dst.Name, dst.Partition, dst.Namespace).
+		Allow(configentries.IntentionSource{Name: src.Name, Partition: src.Partition, Namespace: src.Namespace})
 }
 
-func deleteExportedServices(t *testing.T, consulClient *api.Client, dst *helpers.MeshTask) {
-	require.NoError(t, retryFunc(consulTimeout, t, func() error {
-		_, err := consulClient.ConfigEntries().Delete(api.ExportedServices, dst.Partition, dst.WriteOpts())
-		if err != nil {
-			return fmt.Errorf("failed to delete exported-services for %s: %w", dst.Partition, err)
-		}
-		return nil
-	}))
+// exportedServices returns the exported-services config entry
+// that exports the dst service to the partition of src.
+func exportedServices(src, dst *helpers.MeshTask) *api.ExportedServicesConfigEntry {
+	return &api.ExportedServicesConfigEntry{
+		Name:      dst.Partition,
+		Partition: dst.Partition,
+		Services: []api.ExportedService{{
+			Name:      dst.Name,
+			Namespace: dst.Namespace,
+			Consumers: []api.ServiceConsumer{{Partition: src.Partition}},
+		}},
+	}
 }
 
-func consulClient(t *testing.T, addr, token string) (*api.Client, ConsulState, error) {
+// consulClient returns a client of the Consul cluster and restores
+// the state of the cluster when the test completes.
+func consulClient(t *testing.T, addr, token string) (*api.Client, error) {
 	cfg := api.DefaultConfig()
 	cfg.Address = addr
 	cfg.Token = token
 	client, err := api.NewClient(cfg)
 	if err != nil {
-		return nil, ConsulState{}, fmt.Errorf("failed to create Consul client: %w", err)
-	}
-	state, err := recordConsulState(t, client)
-	if err != nil {
-		return nil, ConsulState{}, fmt.Errorf("failed to establish Consul state: %w", err)
-	}
-	return client, state, err
-}
-
-type ConsulState struct {
-	Partitions map[string]PartitionState
-}
-
-type PartitionState struct {
-	Name       string
-	Namespaces map[string]NamespaceState
-}
-
-type NamespaceState struct {
-	Name        string
-	Tokens      map[string]struct{}
-	Policies    map[string]struct{}
-	Roles       map[string]struct{}
-	AuthMethods map[string]struct{}
-}
-
-func recordConsulState(t *testing.T, consul *api.Client) (ConsulState, error) {
-	// TODO: not sure how to handle OSS vs Enterprise cases.. these tests currently use Enterprise.
-	parts, _, err := consul.Partitions().List(context.Background(), nil)
-	if err != nil {
-		return ConsulState{}, err
-	}
-
-	state := ConsulState{Partitions: make(map[string]PartitionState)}
-	for _, part := range parts {
-		t.Logf("recording partition state for %s", part.Name)
-		nss, _, err := consul.Namespaces().List(&api.QueryOptions{Partition: part.Name})
-		if err != nil {
-			return ConsulState{}, err
-		}
-		partState := PartitionState{Name: part.Name, Namespaces: make(map[string]NamespaceState)}
-		for _, ns := range nss {
-
-			t.Logf("  recording namespace state for %s/%s", part.Name, ns.Name)
-			opts := &api.QueryOptions{Partition: part.Name, Namespace: ns.Name}
-			nsState := NamespaceState{Name: ns.Name}
-
-			// Tokens
-			nsState.Tokens = map[string]struct{}{}
-			tokens, _, err := consul.ACL().TokenList(opts)
-			if err != nil {
-				return ConsulState{}, err
-			}
-			for _, tok := range tokens {
-				t.Logf("    recording token %s in %s/%s", tok.AccessorID, part.Name, ns.Name)
-				nsState.Tokens[tok.AccessorID] = struct{}{}
-			}
-
-			// Policies
-			nsState.Policies = map[string]struct{}{}
-			policies, _, err := consul.ACL().PolicyList(opts)
-			if err != nil {
-				return ConsulState{}, err
-			}
-			for _, p := range policies {
-				t.Logf("    recording policy %s (%s) in %s/%s", p.Name, p.ID, part.Name, ns.Name)
-				nsState.Policies[p.ID] = struct{}{}
-			}
-
-			// Auth methods
-			nsState.AuthMethods = map[string]struct{}{}
-			methods, _, err := consul.ACL().AuthMethodList(opts)
-			if err != nil {
-				return ConsulState{}, err
-			}
-			for _, method := range methods {
-				t.Logf("    recording auth method %s in %s/%s", method.Name, part.Name, ns.Name)
-				nsState.AuthMethods[method.Name] = struct{}{}
-			}
-
-			partState.Namespaces[ns.Name] = nsState
-		}
-		state.Partitions[part.Name] = partState
-	}
-	return state, nil
-}
-
-func restoreConsulState(t *testing.T, consul *api.Client, state ConsulState) error {
-	parts, _, err := consul.Partitions().List(context.Background(), nil)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to create Consul client: %w", err)
 	}
-	for _, part := range parts {
-		partState, existingPart := state.Partitions[part.Name]
-		// if the partition is not a pre-existing one, then delete it and continue.
-		if !existingPart {
-			t.Logf("deleting partition %s", part.Name)
-			_, err = consul.Partitions().Delete(context.Background(), part.Name, nil)
-			if err != nil {
-				return err
-			}
-			continue
-		}
-
-		nss, _, err := consul.Namespaces().List(&api.QueryOptions{Partition: part.Name})
-		if err != nil {
-			return err
-		}
-		for _, ns := range nss {
-			nsState, existingNS := partState.Namespaces[ns.Name]
-
-			// if the namespace is not a pre-existing one, then delete it and continue.
-			if !existingNS {
-				t.Logf("  deleting namespace %s/%s", part.Name, ns.Name)
-				_, err = consul.Namespaces().Delete(ns.Name, &api.WriteOptions{Partition: part.Name})
-				if err != nil {
-					return err
-				}
-				continue
-			}
-
-			// if we're here then this is an existing partition/namespace.
-			// restore the ACL state.
-
-			qopts := &api.QueryOptions{Partition: part.Name, Namespace: ns.Name}
-			wopts := &api.WriteOptions{Partition: part.Name, Namespace: ns.Name}
-
-			// Tokens
-			tokens, _, err := consul.ACL().TokenList(qopts)
-			if err != nil {
-				return err
-			}
-			for _, tok := range tokens {
-				if _, existing := nsState.Tokens[tok.AccessorID]; !existing {
-					t.Logf("    deleting token %s from %s/%s", tok.AccessorID, part.Name, ns.Name)
-					if _, err = consul.ACL().TokenDelete(tok.AccessorID, wopts); err != nil {
-						return err
-					}
-				}
-			}
-
-			// Policies
-			policies, _, err := consul.ACL().PolicyList(qopts)
-			if err != nil {
-				return err
-			}
-			for _, p := range policies {
-				if _, existing := nsState.Policies[p.ID]; !existing {
-					t.Logf("    deleting policy %s (%s) from %s/%s", p.Name, p.ID, part.Name, ns.Name)
-					if _, err = consul.ACL().PolicyDelete(p.ID, wopts); err != nil {
-						return err
-					}
-				}
-			}
-
-			methods, _, err := consul.ACL().AuthMethodList(qopts)
-			if err != nil {
-				return err
-			}
-			for _, method := range methods {
-				if _, existing := nsState.AuthMethods[method.Name]; !existing {
-					t.Logf("    deleting auth method %s from %s/%s", method.Name, part.Name, ns.Name)
-					if _, err = consul.ACL().AuthMethodDelete(method.Name, wopts); err != nil {
-						return err
-					}
-				}
-			}
-		}
+	if err := consulstate.Preserve(t, client); err != nil {
+		return nil, err
 	}
-	return nil
+	return client, nil
 }
 
 func (c *HCPTestConfig) getServerAddress() string {
@@ -223,3 +223,11 @@ resource "aws_iam_policy" "execute_command" {
 EOF
 
 }
+
+output "suffix_1" {
+  value = var.suffix_1
+}
+
+output "suffix_2" {
+  value = var.suffix_2
+}
@@ -187,3 +187,7 @@ resource "aws_iam_policy" "execute_command" {
 EOF
 
 }
+
+output "suffix" {
+  value = var.suffix
+}
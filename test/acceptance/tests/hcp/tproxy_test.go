@@ -5,11 +5,10 @@ package hcp
 
 import (
 	"fmt"
-	"strings"
 	"testing"
 
-	"github.com/gruntwork-io/terratest/modules/random"
-	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/configentries"
+	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/consulwait"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/helpers"
 	"github.com/hashicorp/terraform-aws-consul-ecs/test/acceptance/framework/logger"
 	"github.com/stretchr/testify/require"
@@ -18,22 +17,21 @@ import (
 // TestNamespacesTProxy ensures that services in different namespaces can be
 // can be configured to communicate with transparent proxy enabled
 func TestNamespacesTProxy(t *testing.T) {
+	suite.Report().Track(t)
+
 	cfg := parseHCPTestConfig(t)
 	checkAndSkipTest(t, cfg.LaunchType)
 
+	tfDir := "./terraform/ns-tproxy"
+	randomSuffix := suffixes(t, tfDir, "suffix")[0]
+
 	// generate input variables to the test terraform using the config.
 	ignoreVars := []string{"token", "enable_hcp", "consul_version", "retry_join", "consul_public_endpoint_url"}
 	tfVars := TFVars(cfg, ignoreVars...)
 
-	consulClient, initialConsulState, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
+	consulClient, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		if err := restoreConsulState(t, consulClient, initialConsulState); err != nil {
-			logger.Log(t, "failed to restore Consul state:", err)
-		}
-	})
-
-	randomSuffix := strings.ToLower(random.UniqueId())
+	configEntries := configentries.NewManager(t, consulClient, consulwait.DefaultRetryPolicy())
 
 	taskConfig := helpers.MeshTaskConfig{
 		ConsulClient: consulClient,
@@ -56,25 +54,29 @@ func TestNamespacesTProxy(t *testing.T) {
 	tfVars["consul_image"] = cfg.ConsulImageURI(true)
 	tfVars["consul_server_address"] = cfg.getServerAddress()
 
-	terraformOptions, _ := terraformInitAndApply(t, "./terraform/ns-tproxy", tfVars)
-	t.Cleanup(func() { terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure) })
+	terraformOptions, _ := terraformInitAndApply(t, tfDir, tfVars)
+	t.Cleanup(func() {
+		terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure, clientTask, serverTask)
+	})
 
 	// Wait for both tasks to be registered in Consul.
 	waitForTasks(t, clientTask, serverTask)
 
-	curlCommand := fmt.Sprintf(`/bin/sh -c "curl http://%s.service.%s.ns.consul"`, serverTask.Name, serverTask.Namespace)
+	clientToServer := helpers.Connection{Source: clientTask, Destination: serverTask}
+	targets := helpers.StaticTargets(map[helpers.Connection]string{
+		clientToServer: fmt.Sprintf("http://%s.service.%s.ns.consul", serverTask.Name, serverTask.Namespace),
+	})
 
 	// Check that the connection between apps is unsuccessful without an intention.
 	logger.Log(t, "checking that the connection between apps is unsuccessful without an intention")
-	expectCurlOutput(t, clientTask, curlCommand, `curl: (52) Empty reply from server`)
+	expectConnectivity(t, targets, nil, map[helpers.Connection]string{clientToServer: emptyReply}, clientTask, serverTask)
 
 	// Create an intention.
-	upsertIntention(t, consulClient, api.IntentionActionAllow, clientTask, serverTask)
-	t.Cleanup(func() { deleteIntention(t, consulClient, serverTask) })
+	configEntries.WriteServiceIntentions(allowIntentions(clientTask, serverTask))
 
 	// Now check that the connection succeeds.
 	logger.Log(t, "checking that the connection succeeds with an intention")
-	expectCurlOutput(t, clientTask, curlCommand, `"code": 200`)
+	expectConnectivity(t, targets, []helpers.Connection{clientToServer}, nil, clientTask, serverTask)
 
 	logger.Log(t, "Test successful!")
 }
@@ -82,23 +84,22 @@ func TestNamespacesTProxy(t *testing.T) {
 // TestAdminPartitionsTProxy ensures that services in different admin partitions and namespaces can be
 // can be configured to communicate with transparent proxy enabled.
 func TestAdminPartitionsTProxy(t *testing.T) {
+	suite.Report().Track(t)
+
 	cfg := parseHCPTestConfig(t)
 	checkAndSkipTest(t, cfg.LaunchType)
 
+	tfDir := "./terraform/ap-tproxy"
+	names := suffixes(t, tfDir, "suffix_1", "suffix_2")
+	clientSuffix, serverSuffix := names[0], names[1]
+
 	// generate input variables to the test terraform using the config.
 	ignoreVars := []string{"ecs_cluster_arn", "token", "enable_hcp", "consul_version", "retry_join", "consul_public_endpoint_url"}
 	tfVars := TFVars(cfg, ignoreVars...)
 
-	consulClient, initialConsulState, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
+	consulClient, err := consulClient(t, cfg.ConsulAddr, cfg.ConsulToken)
 	require.NoError(t, err)
-	t.Cleanup(func() {
-		if err := restoreConsulState(t, consulClient, initialConsulState); err != nil {
-			logger.Log(t, "failed to restore Consul state:", err)
-		}
-	})
-
-	clientSuffix := strings.ToLower(random.UniqueId())
-	serverSuffix := strings.ToLower(random.UniqueId())
+	configEntries := configentries.NewManager(t, consulClient, consulwait.DefaultRetryPolicy())
 
 	taskConfig := helpers.MeshTaskConfig{
 		ConsulClient: consulClient,
@@ -126,30 +127,31 @@ func TestAdminPartitionsTProxy(t *testing.T) {
 	tfVars["consul_image"] = cfg.ConsulImageURI(true)
 	tfVars["consul_server_address"] = cfg.getServerAddress()
 
-	terraformOptions, _ := terraformInitAndApply(t, "./terraform/ap-tproxy", tfVars)
-	t.Cleanup(func() { terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure) })
+	terraformOptions, _ := terraformInitAndApply(t, tfDir, tfVars)
+	t.Cleanup(func() {
+		terraformDestroy(t, terraformOptions, suite.Config().NoCleanupOnFailure, clientTask, serverTask)
+	})
 
 	// Wait for both tasks to be registered in Consul.
 	waitForTasks(t, clientTask, serverTask)
 
-	curlCommand := fmt.Sprintf(`/bin/sh -c "curl http://%s.virtual.%s.ns.%s.ap.consul"`, serverTask.Name, serverTask.Namespace, serverTask.Partition)
+	clientToServer := helpers.Connection{Source: clientTask, Destination: serverTask}
+	targets := helpers.StaticTargets(map[helpers.Connection]string{clientToServer: helpers.VirtualTarget(clientTask, serverTask)})
 	logger.Log(t, "checking that the connection is refused without an `exported-services` config entry")
-	expectCurlOutput(t, clientTask, curlCommand, `curl: (56) Recv failure: Connection reset by peer`)
+	expectConnectivity(t, targets, nil, map[helpers.Connection]string{clientToServer: connectionReset}, clientTask, serverTask)
 
 	// Create an exported-services config entry for the server
-	upsertExportedServices(t, consulClient, clientTask, serverTask)
-	t.Cleanup(func() { deleteExportedServices(t, consulClient, serverTask) })
+	configEntries.Write(exportedServices(clientTask, serverTask))
 
 	logger.Log(t, "checking that the connection between apps is unsuccessful without an intention")
-	expectCurlOutput(t, clientTask, curlCommand, `curl: (56) Recv failure: Connection reset by peer`)
+	expectConnectivity(t, targets, nil, map[helpers.Connection]string{clientToServer: connectionReset}, clientTask, serverTask)
 
 	// Create an intention.
 	logger.Log(t, "upserting intention")
-	upsertIntention(t, consulClient, api.IntentionActionAllow, clientTask, serverTask)
-	t.Cleanup(func() { deleteIntention(t, consulClient, serverTask) })
+	configEntries.WriteServiceIntentions(allowIntentions(clientTask, serverTask))
 
 	logger.Log(t, "checking that the connection succeeds with an exported-services and intention")
-	expectCurlOutput(t, clientTask, curlCommand, `"code": 200`)
+	expectConnectivity(t, targets, []helpers.Connection{clientToServer}, nil, clientTask, serverTask)
 
 	logger.Log(t, "Test successful!")
 }